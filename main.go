@@ -2,23 +2,47 @@ package main
 
 import (
 	"database/sql"
-	"sync"
+	"log"
+	"time"
 	"user-service/src/handlers/cart"
 	"user-service/src/handlers/order"
+	"user-service/src/util/client"
 	"user-service/src/util/config"
+	"user-service/src/util/helper"
+	"user-service/src/util/helper/jwt"
+	"user-service/src/util/helper/mailer"
+	"user-service/src/util/middleware"
 	"user-service/src/util/routes"
+	"user-service/src/util/scheduler"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/thedevsaddam/renderer"
 
+	attemptUsecase "user-service/src/app/dto/attempts"
+	checkoutUsecase "user-service/src/app/dto/checkout"
+	idempotencyUsecase "user-service/src/app/dto/idempotency"
+	reservationUsecase "user-service/src/app/dto/reservations"
+	tokenUsecase "user-service/src/app/dto/tokens"
 	userUsecase "user-service/src/app/dto/users"
 	userHandler "user-service/src/handlers/users"
+	attemptStore "user-service/src/util/repository/attempts"
+	checkoutStore "user-service/src/util/repository/checkout"
+	idempotencyStore "user-service/src/util/repository/idempotency"
+	locksStore "user-service/src/util/repository/locks"
+	mfaStore "user-service/src/util/repository/mfa"
+	reservationStore "user-service/src/util/repository/reservations"
+	sessionStore "user-service/src/util/repository/sessions"
+	tokenStore "user-service/src/util/repository/tokens"
 	userStore "user-service/src/util/repository/users"
 
 	productHandler "user-service/src/handlers/products"
 
 	shopHandler "user-service/src/handlers/shop"
 
+	wellKnownHandler "user-service/src/handlers/wellknown"
+
+	adminHandler "user-service/src/handlers/admin"
+
 	integrationUseCase "user-service/src/app/dto/users/integrations"
 	integrationHandler "user-service/src/handlers/users/integrations"
 )
@@ -26,6 +50,7 @@ import (
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
+		log.Printf("[CONFIG] %v", err)
 		return
 	}
 
@@ -41,35 +66,91 @@ func main() {
 	}
 	defer sqlDb.Close()
 
-	mutex := &sync.Mutex{}
+	if cfg.JWTSigningKeyPath != "" {
+		err = jwt.LoadKeys(cfg.JWTSigningKeyPath, cfg.JWTSigningKeyID, cfg.JWTVerificationKeyPaths)
+	} else {
+		log.Println("[JWT] JWT_EPHEMERAL_KEY is set, signing with a key that is lost on restart")
+		err = jwt.GenerateKeys()
+	}
+	if err != nil {
+		log.Printf("[JWT] %v", err)
+		return
+	}
+
+	if err := helper.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("[CONFIG] %v", err)
+		return
+	}
+
 	validator := validator.New()
+	if err := helper.RegisterValidations(validator); err != nil {
+		return
+	}
+
 	render := renderer.New()
-	routes := setupRoutes(render, sqlDb, validator, cfg, mutex)
+	jobs := scheduler.NewScheduler()
+	routes := setupRoutes(render, sqlDb, validator, cfg, jobs)
+
+	jobs.Start()
+	defer jobs.Stop()
+
 	routes.Run(cfg.AppPort)
 }
 
-func setupRoutes(render *renderer.Render, myDb *sql.DB, validator *validator.Validate, config *config.Config, mutex *sync.Mutex) *routes.Routes {
+func setupRoutes(render *renderer.Render, myDb *sql.DB, validator *validator.Validate, config *config.Config, jobs *scheduler.Scheduler) *routes.Routes {
 	userStore := userStore.NewStore(myDb)
-	userUsecase := userUsecase.NewUserUsecase(userStore)
-	userHandler := userHandler.NewUserHandler(userUsecase, render)
+	tokenStore := tokenStore.NewStore(myDb)
+	sessionStore := sessionStore.NewStore(myDb)
+	mfaStore := mfaStore.NewStore(myDb)
+	attemptStore := attemptStore.NewStore(myDb)
+	attemptUsecase := attemptUsecase.NewAttemptUsecase(attemptStore)
+	tokenUsecase := tokenUsecase.NewTokenUsecase(tokenStore, sessionStore)
+	jobs.Every("purge revoked tokens", time.Hour, tokenUsecase.PurgeExpired)
+	userUsecase := userUsecase.NewUserUsecase(userStore, tokenStore, sessionStore, mfaStore, attemptUsecase, tokenUsecase, client.NewOrderClient(client.NetClient, config.OrderServiceURL), setupMailer(config), config.AppURL, config.PasswordResetURL)
+	jobs.Every("anonymize users", time.Hour, userUsecase.AnonymizeUsers)
+	userHandler := userHandler.NewUserHandler(userUsecase, render, validator)
 
 	integrationUseCase := integrationUseCase.NewUserUsecase(userStore)
-	integrationHandler := integrationHandler.NewHandler(render, userUsecase, integrationUseCase)
+	integrationHandler := integrationHandler.NewHandler(render, userUsecase, integrationUseCase, attemptUsecase)
+
+	productHandler := productHandler.NewHandler(render, validator)
+
+	shopHandler := shopHandler.NewHandler(render, validator)
+
+	cartHandler := cart.NewHandler(render, validator)
 
-	productHandler := productHandler.NewHandler(render)
+	wellKnownHandler := wellKnownHandler.NewHandler(render)
 
-	shopHandler := shopHandler.NewHandler(render)
+	checkoutClient := client.NewCheckoutClient(client.NetClient, config.ServerKey)
+	reservationUsecase := reservationUsecase.NewReservationUsecase(reservationStore.NewStore(myDb), checkoutClient, locksStore.NewStore(myDb))
+	jobs.Every("release expired reservations", time.Minute, reservationUsecase.ReleaseExpired)
 
-	cartHandler := cart.NewHandler(render)
+	checkoutUsecase := checkoutUsecase.NewCheckoutUsecase(checkoutStore.NewStore(myDb), checkoutClient, reservationUsecase)
+	jobs.Every("resume checkouts", time.Minute, checkoutUsecase.ResumeStale)
 
-	orderHandler := order.NewHandler(render, validator, mutex, config.ClientKey, config.ServerKey)
+	adminHandler := adminHandler.NewHandler(render, validator, attemptUsecase, userUsecase, checkoutUsecase)
+
+	idempotencyUsecase := idempotencyUsecase.NewIdempotencyUsecase(idempotencyStore.NewStore(myDb))
+	jobs.Every("purge idempotency keys", time.Hour, idempotencyUsecase.PurgeExpired)
+	orderHandler := order.NewHandler(render, validator, checkoutUsecase, idempotencyUsecase, reservationUsecase, config.ServerKey)
 
 	return &routes.Routes{
+		Auth:        middleware.NewAuthenticator(tokenUsecase),
+		Admin:       adminHandler,
 		Integration: integrationHandler,
 		User:        userHandler,
 		Product:     productHandler,
 		Shop:        shopHandler,
 		Cart:        cartHandler,
 		Order:       orderHandler,
+		WellKnown:   wellKnownHandler,
 	}
 }
+
+func setupMailer(config *config.Config) mailer.Mailer {
+	if config.MailDriver == "smtp" {
+		return mailer.NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	}
+
+	return mailer.NewLogMailer(config.MailOutputDir, config.MailFrom)
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleted accounts keep their email, the address can be registered again.
+-- Emails are matched case-insensitively, lookups compare LOWER(email) too
+CREATE UNIQUE INDEX idx_users_email_active ON users (LOWER(email)) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_email_active;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN anonymize_after TIMESTAMP,
+    ADD COLUMN anonymized_at TIMESTAMP;
+
+CREATE INDEX idx_users_anonymize_after ON users (anonymize_after) WHERE anonymize_after IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_anonymize_after;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS anonymized_at,
+    DROP COLUMN IF EXISTS anonymize_after;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The expressions must match the ones in the users store for the planner to
+-- use these indexes
+CREATE INDEX idx_users_search_document ON users
+    USING GIN (to_tsvector('simple', email || ' ' || username || ' ' || role));
+
+CREATE INDEX idx_users_email_trgm ON users USING GIN (email gin_trgm_ops);
+CREATE INDEX idx_users_username_trgm ON users USING GIN (username gin_trgm_ops);
+
+CREATE INDEX idx_users_category_preferences ON users USING GIN (category_preferences);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_category_preferences;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_search_document;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE checkout_sagas (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    step VARCHAR(32) NOT NULL,
+    state JSONB NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_checkout_sagas_status_updated_at ON checkout_sagas (status, updated_at);
+
+CREATE TABLE checkout_saga_steps (
+    id BIGSERIAL PRIMARY KEY,
+    saga_id UUID NOT NULL REFERENCES checkout_sagas(id) ON DELETE CASCADE,
+    step VARCHAR(32) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_checkout_saga_steps_saga_id ON checkout_saga_steps (saga_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS checkout_saga_steps;
+DROP TABLE IF EXISTS checkout_sagas;
+-- +goose StatementEnd
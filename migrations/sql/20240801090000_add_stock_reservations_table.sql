@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE stock_reservations (
+    id UUID PRIMARY KEY,
+    saga_id UUID NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    product_id VARCHAR(64) NOT NULL,
+    qty INT NOT NULL CHECK (qty > 0),
+    status VARCHAR(16) NOT NULL,
+    stock_before INT,
+    stock_after INT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (saga_id, product_id)
+);
+
+CREATE INDEX idx_stock_reservations_order_id ON stock_reservations (order_id);
+CREATE INDEX idx_stock_reservations_held_product ON stock_reservations (product_id) WHERE status IN ('reserved', 'confirming');
+CREATE INDEX idx_stock_reservations_reserved_expires_at ON stock_reservations (expires_at) WHERE status = 'reserved';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_reservations;
+-- +goose StatementEnd
@@ -0,0 +1,222 @@
+package attempts
+
+import (
+	"math"
+	"strings"
+	"time"
+	"user-service/src/util/repository/model/attempts"
+)
+
+type attemptRepository interface {
+	GetAttempt(scope string, identifier string) (*attempts.Attempt, error)
+	CountAttempt(scope string, identifier string, window time.Duration, limit int) (int, bool, error)
+	RefundAttempt(scope string, identifier string) error
+	Block(scope string, identifier string, blockedUntil *time.Time, lockedUntil *time.Time) error
+	ClearAttempts(scope string, identifier string) error
+	GetLockouts() (*[]attempts.Attempt, error)
+}
+
+// policy is the throttling of one scope. The first freeAttempts failures are
+// not slowed down, every further failure doubles the wait starting at
+// baseBackoff. An account is locked once it reaches lockoutThreshold.
+type policy struct {
+	freeAttempts     int
+	lockoutThreshold int
+	lockoutDuration  time.Duration
+}
+
+var policies = map[string]policy{
+	attempts.ScopeAccount: {
+		freeAttempts:     3,
+		lockoutThreshold: 10,
+		lockoutDuration:  15 * time.Minute,
+	},
+	// Many users can share an address behind a NAT, so an IP address is only
+	// slowed down and never locked
+	attempts.ScopeIP: {
+		freeAttempts: 20,
+	},
+}
+
+const (
+	// attemptWindow is how long a failure is remembered
+	attemptWindow = time.Hour
+	baseBackoff   = time.Second
+	maxBackoff    = 5 * time.Minute
+)
+
+// AttemptUsecase throttles sign ins per account and per IP address. The
+// counters live in Postgres so a restart does not reset them.
+type AttemptUsecase struct {
+	attempt attemptRepository
+}
+
+func NewAttemptUsecase(attempt attemptRepository) *AttemptUsecase {
+	return &AttemptUsecase{
+		attempt: attempt,
+	}
+}
+
+// Begin counts a sign in attempt before the password or code is checked and
+// returns a LockoutError when the account or the IP address may not try right
+// now. The count and the lockout threshold are checked in one statement, so
+// parallel guesses can not all get through before the first of them failed.
+// The attempt is then settled with Fail or Refund.
+func (u *AttemptUsecase) Begin(email string, ipAddress string) error {
+	timeNow := time.Now()
+	var counted []attemptKey
+	for _, key := range keys(email, ipAddress) {
+		_, ok, err := u.attempt.CountAttempt(key.scope, key.identifier, attemptWindow, policies[key.scope].lockoutThreshold)
+		if err == nil && !ok {
+			err = u.refused(key, timeNow)
+		}
+		if err != nil {
+			if refundErr := u.refund(counted); refundErr != nil {
+				return refundErr
+			}
+			return err
+		}
+		counted = append(counted, key)
+	}
+
+	return nil
+}
+
+// Fail blocks the account and the IP address after an attempt counted by
+// Begin failed.
+func (u *AttemptUsecase) Fail(email string, ipAddress string) error {
+	timeNow := time.Now()
+	for _, key := range keys(email, ipAddress) {
+		attempt, err := u.attempt.GetAttempt(key.scope, key.identifier)
+		if err != nil {
+			return err
+		}
+
+		if err := u.block(key, attempt.Failures, timeNow); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Refund takes back the attempt counted by Begin when it was not a failed
+// guess, either because the credentials were valid or because it could not
+// be checked.
+func (u *AttemptUsecase) Refund(email string, ipAddress string) error {
+	return u.refund(keys(email, ipAddress))
+}
+
+func (u *AttemptUsecase) refund(keys []attemptKey) error {
+	for _, key := range keys {
+		if err := u.attempt.RefundAttempt(key.scope, key.identifier); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refused explains why CountAttempt did not count an attempt. The key can
+// already have reached the lockout threshold while the attempt that reached
+// it is still being checked and has not written the lock yet.
+func (u *AttemptUsecase) refused(key attemptKey, timeNow time.Time) error {
+	attempt, err := u.attempt.GetAttempt(key.scope, key.identifier)
+	if err != nil {
+		return err
+	}
+
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(timeNow) {
+		return &attempts.LockoutError{
+			Err:        attempts.ErrAccountLocked,
+			RetryAfter: attempt.LockedUntil.Sub(timeNow),
+		}
+	}
+
+	if attempt.BlockedUntil != nil && attempt.BlockedUntil.After(timeNow) {
+		return &attempts.LockoutError{
+			Err:        attempts.ErrTooManyAttempts,
+			RetryAfter: attempt.BlockedUntil.Sub(timeNow),
+		}
+	}
+
+	return &attempts.LockoutError{
+		Err:        attempts.ErrAccountLocked,
+		RetryAfter: policies[key.scope].lockoutDuration,
+	}
+}
+
+func (u *AttemptUsecase) block(key attemptKey, failures int, timeNow time.Time) error {
+	p := policies[key.scope]
+	blockedUntil := backoffUntil(timeNow, failures, p.freeAttempts)
+
+	var lockedUntil *time.Time
+	if p.lockoutThreshold > 0 && failures >= p.lockoutThreshold {
+		until := timeNow.Add(p.lockoutDuration)
+		lockedUntil = &until
+	}
+
+	if blockedUntil == nil && lockedUntil == nil {
+		return nil
+	}
+
+	return u.attempt.Block(key.scope, key.identifier, blockedUntil, lockedUntil)
+}
+
+// RecordSuccess resets the account counter. The IP counter is kept, one valid
+// account must not clear the failures made against others.
+func (u *AttemptUsecase) RecordSuccess(email string) error {
+	return u.attempt.ClearAttempts(attempts.ScopeAccount, normalizeEmail(email))
+}
+
+func (u *AttemptUsecase) GetLockouts() (*[]attempts.Attempt, error) {
+	return u.attempt.GetLockouts()
+}
+
+func (u *AttemptUsecase) ClearLockout(scope string, identifier string) error {
+	if _, ok := policies[scope]; !ok {
+		return attempts.ErrInvalidScope
+	}
+
+	if scope == attempts.ScopeAccount {
+		identifier = normalizeEmail(identifier)
+	}
+
+	return u.attempt.ClearAttempts(scope, identifier)
+}
+
+type attemptKey struct {
+	scope      string
+	identifier string
+}
+
+func keys(email string, ipAddress string) []attemptKey {
+	var result []attemptKey
+	if email != "" {
+		result = append(result, attemptKey{scope: attempts.ScopeAccount, identifier: normalizeEmail(email)})
+	}
+
+	if ipAddress != "" {
+		result = append(result, attemptKey{scope: attempts.ScopeIP, identifier: ipAddress})
+	}
+
+	return result
+}
+
+func backoffUntil(timeNow time.Time, failures int, freeAttempts int) *time.Time {
+	if failures <= freeAttempts {
+		return nil
+	}
+
+	backoff := maxBackoff
+	if exponent := failures - freeAttempts - 1; exponent < 32 {
+		backoff = time.Duration(math.Min(float64(baseBackoff)*math.Pow(2, float64(exponent)), float64(maxBackoff)))
+	}
+
+	until := timeNow.Add(backoff)
+	return &until
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,190 @@
+package attempts
+
+import (
+	"testing"
+	"time"
+	"user-service/src/util/repository/model/attempts"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAttemptRepository struct {
+	attempts map[attemptKey]*attempts.Attempt
+}
+
+func newFakeAttemptRepository() *fakeAttemptRepository {
+	return &fakeAttemptRepository{
+		attempts: make(map[attemptKey]*attempts.Attempt),
+	}
+}
+
+func (f *fakeAttemptRepository) GetAttempt(scope string, identifier string) (*attempts.Attempt, error) {
+	if attempt, ok := f.attempts[attemptKey{scope, identifier}]; ok {
+		result := *attempt
+		return &result, nil
+	}
+
+	return &attempts.Attempt{}, nil
+}
+
+func (f *fakeAttemptRepository) CountAttempt(scope string, identifier string, window time.Duration, limit int) (int, bool, error) {
+	timeNow := time.Now()
+	key := attemptKey{scope, identifier}
+	attempt, ok := f.attempts[key]
+	if !ok {
+		attempt = &attempts.Attempt{Scope: scope, Identifier: identifier}
+		f.attempts[key] = attempt
+	}
+
+	if attempt.BlockedUntil != nil && attempt.BlockedUntil.After(timeNow) ||
+		attempt.LockedUntil != nil && attempt.LockedUntil.After(timeNow) ||
+		limit > 0 && attempt.Failures >= limit {
+		return 0, false, nil
+	}
+
+	attempt.Failures++
+	return attempt.Failures, true, nil
+}
+
+func (f *fakeAttemptRepository) RefundAttempt(scope string, identifier string) error {
+	if attempt, ok := f.attempts[attemptKey{scope, identifier}]; ok && attempt.Failures > 0 {
+		attempt.Failures--
+	}
+
+	return nil
+}
+
+func (f *fakeAttemptRepository) Block(scope string, identifier string, blockedUntil *time.Time, lockedUntil *time.Time) error {
+	attempt := f.attempts[attemptKey{scope, identifier}]
+	attempt.BlockedUntil = blockedUntil
+	if lockedUntil != nil {
+		attempt.LockedUntil = lockedUntil
+	}
+
+	return nil
+}
+
+func (f *fakeAttemptRepository) ClearAttempts(scope string, identifier string) error {
+	delete(f.attempts, attemptKey{scope, identifier})
+	return nil
+}
+
+func (f *fakeAttemptRepository) GetLockouts() (*[]attempts.Attempt, error) {
+	return &[]attempts.Attempt{}, nil
+}
+
+func TestAttemptUsecase_Backoff(t *testing.T) {
+	repo := newFakeAttemptRepository()
+	u := NewAttemptUsecase(repo)
+
+	for i := 0; i < policies[attempts.ScopeAccount].freeAttempts; i++ {
+		assert.NoError(t, u.Begin("User@Example.com", "10.0.0.1"))
+		assert.NoError(t, u.Fail("User@Example.com", "10.0.0.1"))
+	}
+	assert.NoError(t, u.Begin("user@example.com", "10.0.0.1"), "free attempts are not slowed down")
+
+	assert.NoError(t, u.Fail("user@example.com", "10.0.0.1"))
+	err := u.Begin("user@example.com", "10.0.0.2")
+	assert.ErrorIs(t, err, attempts.ErrTooManyAttempts)
+
+	var lockoutErr *attempts.LockoutError
+	assert.ErrorAs(t, err, &lockoutErr)
+	assert.InDelta(t, baseBackoff, lockoutErr.RetryAfter, float64(100*time.Millisecond))
+
+	assert.NoError(t, u.Begin("other@example.com", "10.0.0.1"), "the address is below its own limit")
+	assert.NoError(t, u.Refund("other@example.com", "10.0.0.1"))
+
+	// The next failure after the wait doubles it
+	repo.attempts[attemptKey{attempts.ScopeAccount, "user@example.com"}].BlockedUntil = nil
+	assert.NoError(t, u.Begin("user@example.com", ""))
+	assert.NoError(t, u.Fail("user@example.com", ""))
+	assert.ErrorAs(t, u.Begin("user@example.com", ""), &lockoutErr)
+	assert.InDelta(t, 2*baseBackoff, lockoutErr.RetryAfter, float64(100*time.Millisecond))
+}
+
+func TestAttemptUsecase_Lockout(t *testing.T) {
+	repo := newFakeAttemptRepository()
+	u := NewAttemptUsecase(repo)
+
+	for i := 0; i < policies[attempts.ScopeAccount].lockoutThreshold; i++ {
+		assert.NoError(t, u.Begin("user@example.com", ""))
+	}
+	assert.NoError(t, u.Fail("user@example.com", ""))
+
+	err := u.Begin("user@example.com", "")
+	assert.ErrorIs(t, err, attempts.ErrAccountLocked)
+
+	assert.NoError(t, u.ClearLockout(attempts.ScopeAccount, "USER@example.com"))
+	assert.NoError(t, u.Begin("user@example.com", ""))
+
+	assert.ErrorIs(t, u.ClearLockout("device", "x"), attempts.ErrInvalidScope)
+}
+
+func TestAttemptUsecase_BeginCountsBeforeTheCheck(t *testing.T) {
+	repo := newFakeAttemptRepository()
+	u := NewAttemptUsecase(repo)
+
+	// None of these guesses has failed yet, they still use up the attempts
+	// before the lock is written
+	threshold := policies[attempts.ScopeAccount].lockoutThreshold
+	for i := 0; i < threshold; i++ {
+		assert.NoError(t, u.Begin("user@example.com", "10.0.0.1"))
+	}
+
+	err := u.Begin("user@example.com", "10.0.0.2")
+	assert.ErrorIs(t, err, attempts.ErrAccountLocked)
+
+	ip, _ := repo.GetAttempt(attempts.ScopeIP, "10.0.0.2")
+	assert.Zero(t, ip.Failures, "a refused attempt is not counted against the address")
+
+	assert.NoError(t, u.Fail("user@example.com", "10.0.0.1"))
+	account, _ := repo.GetAttempt(attempts.ScopeAccount, "user@example.com")
+	assert.NotNil(t, account.LockedUntil)
+}
+
+func TestAttemptUsecase_Refund(t *testing.T) {
+	repo := newFakeAttemptRepository()
+	u := NewAttemptUsecase(repo)
+
+	for i := 0; i < policies[attempts.ScopeAccount].freeAttempts; i++ {
+		assert.NoError(t, u.Begin("user@example.com", "10.0.0.1"))
+		assert.NoError(t, u.Fail("user@example.com", "10.0.0.1"))
+	}
+
+	// The next attempt had the right password
+	assert.NoError(t, u.Begin("user@example.com", "10.0.0.1"))
+	assert.NoError(t, u.Refund("user@example.com", "10.0.0.1"))
+
+	account, _ := repo.GetAttempt(attempts.ScopeAccount, "user@example.com")
+	assert.Equal(t, policies[attempts.ScopeAccount].freeAttempts, account.Failures)
+
+	assert.NoError(t, u.Begin("user@example.com", "10.0.0.1"))
+	assert.NoError(t, u.Fail("user@example.com", "10.0.0.1"))
+	assert.ErrorIs(t, u.Begin("user@example.com", "10.0.0.1"), attempts.ErrTooManyAttempts)
+}
+
+func TestAttemptUsecase_RecordSuccess(t *testing.T) {
+	repo := newFakeAttemptRepository()
+	u := NewAttemptUsecase(repo)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, u.Begin("user@example.com", "10.0.0.1"))
+	}
+
+	assert.NoError(t, u.RecordSuccess("user@example.com"))
+
+	account, _ := repo.GetAttempt(attempts.ScopeAccount, "user@example.com")
+	assert.Zero(t, account.Failures)
+
+	ip, _ := repo.GetAttempt(attempts.ScopeIP, "10.0.0.1")
+	assert.Equal(t, 5, ip.Failures, "a success does not clear the address")
+}
+
+func TestBackoffUntil(t *testing.T) {
+	timeNow := time.Now()
+
+	assert.Nil(t, backoffUntil(timeNow, 3, 3))
+	assert.Equal(t, timeNow.Add(time.Second), *backoffUntil(timeNow, 4, 3))
+	assert.Equal(t, timeNow.Add(8*time.Second), *backoffUntil(timeNow, 7, 3))
+	assert.Equal(t, timeNow.Add(maxBackoff), *backoffUntil(timeNow, 100, 3))
+}
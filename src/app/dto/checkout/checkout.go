@@ -0,0 +1,450 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	"user-service/src/util/repository/model"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/payment"
+	"user-service/src/util/repository/model/products"
+	"user-service/src/util/repository/model/reservations"
+
+	"github.com/google/uuid"
+)
+
+type checkoutRepository interface {
+	CreateSaga(saga *checkout.Saga) error
+	UpdateSaga(saga *checkout.Saga) error
+	SaveStep(saga *checkout.Saga, entry checkout.StepLog) error
+	GetSaga(id uuid.UUID) (*checkout.Saga, error)
+	GetSagas(bReq checkout.RequestSagas) (*[]checkout.Saga, int, error)
+	ClaimStaleSagas(before time.Time, now time.Time) ([]checkout.Saga, error)
+	ClaimFailedSaga(id uuid.UUID, now time.Time) (*checkout.Saga, error)
+}
+
+type checkoutClient interface {
+	GetProducts(ctx context.Context, productIDs []string, limit string) ([]products.Product, error)
+	CreateOrder(bReq order.CreateOrderRequest) (string, error)
+	CancelOrder(userID uuid.UUID, orderID string) error
+	CreatePayment(bReq order.CreateOrderRequest) (*payment.CreatePaymentResponse, error)
+}
+
+type stockReserver interface {
+	Reserve(sagaID uuid.UUID, orderID string, items []reservations.Item) error
+	ReleaseSaga(sagaID uuid.UUID) error
+}
+
+// errOutcomeUnknown marks a remote step that failed without a definite
+// answer. A timeout or a broken connection can hide an order or a payment
+// that was created anyway, so the saga waits for an admin instead of being
+// compensated.
+var errOutcomeUnknown = errors.New("outcome unknown")
+
+// staleAfter is how long a running saga can go without an update before it
+// counts as interrupted. It has to be well above the time the steps take.
+const staleAfter = 5 * time.Minute
+
+// CheckoutUsecase runs a checkout as a saga: every step is persisted before
+// and after it runs, and a failed step compensates the steps before it in
+// reverse order. Sagas interrupted by a crash are picked up by ResumeStale.
+// The stock is reserved, it is taken once the payment settles.
+type CheckoutUsecase struct {
+	checkout     checkoutRepository
+	client       checkoutClient
+	reservations stockReserver
+}
+
+func NewCheckoutUsecase(checkout checkoutRepository, client checkoutClient, reservations stockReserver) *CheckoutUsecase {
+	return &CheckoutUsecase{
+		checkout:     checkout,
+		client:       client,
+		reservations: reservations,
+	}
+}
+
+// Checkout prices the order, creates it, reserves the stock and opens the
+// payment in the saga id. The caller picks the id so it can record it before
+// anything happens. On error the returned saga tells whether it was
+// rolled back (StatusCompensated) or needs an admin (StatusFailed). The saga
+// is nil when it could not even be stored.
+func (u *CheckoutUsecase) Checkout(id uuid.UUID, bReq order.CreateOrderRequest) (*checkout.Saga, error) {
+	timeNow := time.Now()
+	saga := &checkout.Saga{
+		Id:        id,
+		UserID:    bReq.UserID,
+		Status:    checkout.StatusRunning,
+		Step:      checkout.StepPriceOrder,
+		State:     checkout.State{Request: bReq},
+		CreatedAt: &timeNow,
+		UpdatedAt: &timeNow,
+	}
+
+	if err := u.checkout.CreateSaga(saga); err != nil {
+		return nil, err
+	}
+
+	steps := []struct {
+		name string
+		run  func(saga *checkout.Saga) error
+	}{
+		{checkout.StepPriceOrder, u.priceOrder},
+		{checkout.StepCreateOrder, u.createOrder},
+		{checkout.StepReserveStock, u.reserveStock},
+		{checkout.StepCreatePayment, u.createPayment},
+	}
+
+	for _, step := range steps {
+		if err := u.runStep(saga, step.name, step.run); err != nil {
+			if errors.Is(err, errOutcomeUnknown) {
+				u.fail(saga, err)
+				return saga, err
+			}
+
+			u.compensate(saga)
+			return saga, err
+		}
+	}
+
+	saga.Status = checkout.StatusCompleted
+	if err := u.update(saga); err != nil {
+		// The payment exists, a resumed saga completes instead of compensating
+		log.Printf("[CHECKOUT] failed to complete saga %s: %v", saga.Id, err)
+	}
+
+	return saga, nil
+}
+
+func (u *CheckoutUsecase) GetSaga(id uuid.UUID) (*checkout.Saga, error) {
+	return u.checkout.GetSaga(id)
+}
+
+func (u *CheckoutUsecase) GetSagas(bReq checkout.RequestSagas) (*model.BaseModel, error) {
+	if bReq.Status != "" && !validStatus(bReq.Status) {
+		return nil, checkout.ErrInvalidStatus
+	}
+
+	if bReq.Page < 1 {
+		bReq.Page = 1
+	}
+
+	if bReq.Limit < 1 {
+		bReq.Limit = checkout.DefaultLimit
+	}
+
+	if bReq.Limit > checkout.MaxLimit {
+		bReq.Limit = checkout.MaxLimit
+	}
+
+	result, totalData, err := u.checkout.GetSagas(bReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.BaseModel{
+		Items:        result,
+		TotalItem:    totalData,
+		TotalPage:    int(math.Ceil(float64(totalData) / float64(bReq.Limit))),
+		FilteredItem: len(*result),
+		FilteredPage: bReq.Page,
+	}, nil
+}
+
+// ResumeFailed compensates a failed saga again, once an admin fixed what made
+// it fail. Only the steps recorded as succeeded are compensated.
+func (u *CheckoutUsecase) ResumeFailed(id uuid.UUID) (*checkout.Saga, error) {
+	saga, err := u.checkout.ClaimFailedSaga(id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	u.compensate(saga)
+	return saga, nil
+}
+
+// ResumeStale finishes the sagas of a process that stopped in the middle of a
+// checkout. The client got no answer, so a running saga is rolled back unless
+// the payment was already opened.
+func (u *CheckoutUsecase) ResumeStale() error {
+	timeNow := time.Now()
+	sagas, err := u.checkout.ClaimStaleSagas(timeNow.Add(-staleAfter), timeNow)
+	if err != nil {
+		return err
+	}
+
+	for i := range sagas {
+		saga := &sagas[i]
+		u.resume(saga)
+		log.Printf("[CHECKOUT] resumed saga %s, now %s", saga.Id, saga.Status)
+	}
+
+	return nil
+}
+
+func (u *CheckoutUsecase) resume(saga *checkout.Saga) {
+	state := &saga.State
+	if saga.Status == checkout.StatusRunning && state.Payment != nil {
+		saga.Status = checkout.StatusCompleted
+		if err := u.update(saga); err != nil {
+			log.Printf("[CHECKOUT] failed to complete saga %s: %v", saga.Id, err)
+		}
+		return
+	}
+
+	// A remote call that started but never recorded its result may or may
+	// not have happened, guessing could cancel a paid order. Reserving and
+	// releasing stock are transactions here and safe to compensate.
+	var interrupted bool
+	if saga.Status == checkout.StatusRunning {
+		switch saga.Step {
+		case checkout.StepCreateOrder:
+			interrupted = state.OrderID == ""
+		case checkout.StepCreatePayment:
+			interrupted = state.Payment == nil
+		}
+	}
+
+	if interrupted {
+		u.fail(saga, fmt.Errorf("interrupted during %s, its outcome is unknown", saga.Step))
+		return
+	}
+
+	u.compensate(saga)
+}
+
+// runStep persists that the step started, runs it and persists its outcome.
+// A failed step switches the saga to compensating, or to failed when its
+// outcome is unknown, in the same write, so a crash right after it is not
+// mistaken for an interrupted step.
+func (u *CheckoutUsecase) runStep(saga *checkout.Saga, step string, run func(saga *checkout.Saga) error) error {
+	saga.Step = step
+	if err := u.saveStep(saga, checkout.StepStarted, nil); err != nil {
+		return err
+	}
+
+	if err := run(saga); err != nil {
+		if saga.Status == checkout.StatusRunning {
+			saga.Status = checkout.StatusCompensating
+			if errors.Is(err, errOutcomeUnknown) {
+				saga.Status = checkout.StatusFailed
+			}
+		}
+		u.setError(saga, err)
+
+		if saveErr := u.saveStep(saga, checkout.StepFailed, err); saveErr != nil {
+			log.Printf("[CHECKOUT] failed to save step %s of saga %s: %v", step, saga.Id, saveErr)
+		}
+		return err
+	}
+
+	return u.saveStep(saga, checkout.StepSucceeded, nil)
+}
+
+// compensate undoes the steps that succeeded, newest first. A compensation
+// that fails leaves the saga failed for an admin to resume.
+func (u *CheckoutUsecase) compensate(saga *checkout.Saga) {
+	saga.Status = checkout.StatusCompensating
+	state := &saga.State
+
+	// Releasing is a no-op when nothing was reserved, an interrupted
+	// reservation is released as well
+	if state.OrderID != "" && !state.IsCompensated(checkout.StepReleaseStock) {
+		if err := u.runStep(saga, checkout.StepReleaseStock, u.releaseStock); err != nil {
+			u.fail(saga, err)
+			return
+		}
+	}
+
+	if state.OrderID != "" && !state.IsCompensated(checkout.StepCancelOrder) {
+		if err := u.runStep(saga, checkout.StepCancelOrder, u.cancelOrder); err != nil {
+			u.fail(saga, err)
+			return
+		}
+	}
+
+	saga.Status = checkout.StatusCompensated
+	if err := u.update(saga); err != nil {
+		log.Printf("[CHECKOUT] failed to mark saga %s compensated: %v", saga.Id, err)
+	}
+}
+
+func (u *CheckoutUsecase) fail(saga *checkout.Saga, err error) {
+	saga.Status = checkout.StatusFailed
+	u.setError(saga, err)
+	if err := u.update(saga); err != nil {
+		log.Printf("[CHECKOUT] failed to mark saga %s failed: %v", saga.Id, err)
+	}
+	log.Printf("[CHECKOUT] saga %s needs attention: %s", saga.Id, *saga.Error)
+}
+
+// priceOrder fills in the prices and turns away orders the stock on hand
+// cannot cover. Reservations of other checkouts are only counted when
+// reserving.
+func (u *CheckoutUsecase) priceOrder(saga *checkout.Saga) error {
+	request := &saga.State.Request
+
+	var productIDs []string
+	for _, product := range request.ProductOrder {
+		productIDs = append(productIDs, product.ProductID)
+	}
+
+	items, err := u.client.GetProducts(context.Background(), productIDs, request.Limit)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]products.Product, len(items))
+	for _, item := range items {
+		byID[item.Id] = item
+	}
+
+	// A product can appear on several lines, the stock has to cover all
+	ordered := map[string]int{}
+	var total float64
+	for i, orderProd := range request.ProductOrder {
+		product, ok := byID[orderProd.ProductID]
+		if !ok {
+			return checkout.ErrProductNotFound
+		}
+
+		ordered[product.Id] += orderProd.Qty
+		if product.Stock < ordered[product.Id] {
+			return checkout.ErrOutOfStock
+		}
+
+		request.ProductOrder[i].Price = product.Price
+		request.ProductOrder[i].SubtotalPrice = product.Price * float64(orderProd.Qty)
+		total += request.ProductOrder[i].SubtotalPrice
+	}
+	request.TotalPrice = total
+
+	return nil
+}
+
+func (u *CheckoutUsecase) createOrder(saga *checkout.Saga) error {
+	orderID, err := u.client.CreateOrder(saga.State.Request)
+	if err != nil {
+		return remoteError(err)
+	}
+
+	saga.State.OrderID = orderID
+	return nil
+}
+
+func (u *CheckoutUsecase) reserveStock(saga *checkout.Saga) error {
+	items := reservationItems(saga.State.Request.ProductOrder)
+	if err := u.reservations.Reserve(saga.Id, saga.State.OrderID, items); err != nil {
+		return err
+	}
+
+	saga.State.Stock = items
+	return nil
+}
+
+func (u *CheckoutUsecase) createPayment(saga *checkout.Saga) error {
+	bReq := saga.State.Request
+	bReq.TransactionDetails.OrderID = saga.State.OrderID
+	bReq.TransactionDetails.GrossAmount = bReq.TotalPrice
+	bReq.CustomExpiry = &order.CustomExpiry{
+		ExpiryDuration: int(reservations.PaymentExpiry / time.Minute),
+		Unit:           "minute",
+	}
+
+	bResp, err := u.client.CreatePayment(bReq)
+	if err != nil {
+		return remoteError(err)
+	}
+
+	saga.State.Payment = bResp
+	return nil
+}
+
+func (u *CheckoutUsecase) releaseStock(saga *checkout.Saga) error {
+	if err := u.reservations.ReleaseSaga(saga.Id); err != nil {
+		return err
+	}
+
+	saga.State.Compensated = append(saga.State.Compensated, checkout.StepReleaseStock)
+	return nil
+}
+
+func (u *CheckoutUsecase) cancelOrder(saga *checkout.Saga) error {
+	state := &saga.State
+	if err := u.client.CancelOrder(state.Request.UserID, state.OrderID); err != nil {
+		return err
+	}
+
+	state.Compensated = append(state.Compensated, checkout.StepCancelOrder)
+	return nil
+}
+
+func (u *CheckoutUsecase) saveStep(saga *checkout.Saga, status string, err error) error {
+	timeNow := time.Now()
+	saga.UpdatedAt = &timeNow
+
+	entry := checkout.StepLog{
+		Step:      saga.Step,
+		Status:    status,
+		CreatedAt: &timeNow,
+	}
+	if err != nil {
+		message := err.Error()
+		entry.Error = &message
+	}
+
+	return u.checkout.SaveStep(saga, entry)
+}
+
+func (u *CheckoutUsecase) update(saga *checkout.Saga) error {
+	timeNow := time.Now()
+	saga.UpdatedAt = &timeNow
+	return u.checkout.UpdateSaga(saga)
+}
+
+func (u *CheckoutUsecase) setError(saga *checkout.Saga, err error) {
+	message := err.Error()
+	saga.Error = &message
+}
+
+// remoteError keeps the error of a service that turned the request down and
+// marks every other error, like a timeout, as an unknown outcome.
+func remoteError(err error) error {
+	var rejection interface{ Rejected() bool }
+	if errors.As(err, &rejection) && rejection.Rejected() {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", errOutcomeUnknown, err)
+}
+
+// reservationItems sums the quantities per product, in the order the
+// products first appear.
+func reservationItems(lines []order.ProductOrder) []reservations.Item {
+	var items []reservations.Item
+	index := map[string]int{}
+	for _, line := range lines {
+		if i, ok := index[line.ProductID]; ok {
+			items[i].Qty += line.Qty
+			continue
+		}
+
+		index[line.ProductID] = len(items)
+		items = append(items, reservations.Item{ProductID: line.ProductID, Qty: line.Qty})
+	}
+
+	return items
+}
+
+func validStatus(status string) bool {
+	for _, valid := range checkout.Statuses {
+		if status == valid {
+			return true
+		}
+	}
+
+	return false
+}
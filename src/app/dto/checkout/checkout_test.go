@@ -0,0 +1,425 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	serviceClient "user-service/src/util/client"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/payment"
+	"user-service/src/util/repository/model/products"
+	"user-service/src/util/repository/model/reservations"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCheckoutRepository struct {
+	sagas map[uuid.UUID]checkout.Saga
+	steps map[uuid.UUID][]checkout.StepLog
+}
+
+func newFakeCheckoutRepository() *fakeCheckoutRepository {
+	return &fakeCheckoutRepository{
+		sagas: make(map[uuid.UUID]checkout.Saga),
+		steps: make(map[uuid.UUID][]checkout.StepLog),
+	}
+}
+
+func (f *fakeCheckoutRepository) CreateSaga(saga *checkout.Saga) error {
+	f.sagas[saga.Id] = copySaga(saga)
+	return nil
+}
+
+func (f *fakeCheckoutRepository) UpdateSaga(saga *checkout.Saga) error {
+	f.sagas[saga.Id] = copySaga(saga)
+	return nil
+}
+
+func (f *fakeCheckoutRepository) SaveStep(saga *checkout.Saga, entry checkout.StepLog) error {
+	f.sagas[saga.Id] = copySaga(saga)
+	f.steps[saga.Id] = append(f.steps[saga.Id], entry)
+	return nil
+}
+
+func (f *fakeCheckoutRepository) GetSaga(id uuid.UUID) (*checkout.Saga, error) {
+	saga, ok := f.sagas[id]
+	if !ok {
+		return nil, checkout.ErrSagaNotFound
+	}
+
+	saga.Steps = f.steps[id]
+	return &saga, nil
+}
+
+func (f *fakeCheckoutRepository) GetSagas(bReq checkout.RequestSagas) (*[]checkout.Saga, int, error) {
+	sagas := []checkout.Saga{}
+	for _, saga := range f.sagas {
+		if bReq.Status == "" || saga.Status == bReq.Status {
+			sagas = append(sagas, saga)
+		}
+	}
+
+	return &sagas, len(sagas), nil
+}
+
+func (f *fakeCheckoutRepository) ClaimStaleSagas(before time.Time, now time.Time) ([]checkout.Saga, error) {
+	var sagas []checkout.Saga
+	for id, saga := range f.sagas {
+		if (saga.Status == checkout.StatusRunning || saga.Status == checkout.StatusCompensating) && saga.UpdatedAt.Before(before) {
+			saga.UpdatedAt = &now
+			f.sagas[id] = saga
+			sagas = append(sagas, saga)
+		}
+	}
+
+	return sagas, nil
+}
+
+func (f *fakeCheckoutRepository) ClaimFailedSaga(id uuid.UUID, now time.Time) (*checkout.Saga, error) {
+	saga, ok := f.sagas[id]
+	if !ok {
+		return nil, checkout.ErrSagaNotFound
+	}
+
+	if saga.Status != checkout.StatusFailed {
+		return nil, checkout.ErrNotResumable
+	}
+
+	saga.Status = checkout.StatusCompensating
+	saga.UpdatedAt = &now
+	f.sagas[id] = saga
+	return &saga, nil
+}
+
+// copySaga keeps the stored saga apart from the one the usecase keeps
+// changing, like a database row would be.
+func copySaga(saga *checkout.Saga) checkout.Saga {
+	result := *saga
+	result.State.Request.ProductOrder = append([]order.ProductOrder(nil), saga.State.Request.ProductOrder...)
+	result.State.Stock = append([]reservations.Item(nil), saga.State.Stock...)
+	result.State.Compensated = append([]string(nil), saga.State.Compensated...)
+	return result
+}
+
+var errPaymentRejected = &serviceClient.StatusError{Service: "payment", StatusCode: http.StatusBadRequest}
+
+type fakeCheckoutClient struct {
+	stock    map[string]int
+	prices   map[string]float64
+	orders   map[string]string
+	failOn   map[string]error
+	payments []order.CreateOrderRequest
+}
+
+func newFakeCheckoutClient() *fakeCheckoutClient {
+	return &fakeCheckoutClient{
+		stock:  map[string]int{"p1": 5, "p2": 1},
+		prices: map[string]float64{"p1": 10, "p2": 25},
+		orders: map[string]string{},
+		failOn: map[string]error{},
+	}
+}
+
+func (f *fakeCheckoutClient) GetProducts(ctx context.Context, productIDs []string, limit string) ([]products.Product, error) {
+	if err := f.failOn["get_products"]; err != nil {
+		return nil, err
+	}
+
+	var items []products.Product
+	for _, id := range productIDs {
+		if stock, ok := f.stock[id]; ok {
+			items = append(items, products.Product{Id: id, Stock: stock, Price: f.prices[id]})
+		}
+	}
+
+	return items, nil
+}
+
+func (f *fakeCheckoutClient) CreateOrder(bReq order.CreateOrderRequest) (string, error) {
+	if err := f.failOn["create_order"]; err != nil {
+		return "", err
+	}
+
+	orderID := uuid.NewString()
+	f.orders[orderID] = "Pending"
+	return orderID, nil
+}
+
+func (f *fakeCheckoutClient) CancelOrder(userID uuid.UUID, orderID string) error {
+	if err := f.failOn["cancel_order"]; err != nil {
+		return err
+	}
+
+	f.orders[orderID] = checkout.OrderCancelled
+	return nil
+}
+
+func (f *fakeCheckoutClient) CreatePayment(bReq order.CreateOrderRequest) (*payment.CreatePaymentResponse, error) {
+	if err := f.failOn["create_payment"]; err != nil {
+		return nil, err
+	}
+
+	f.payments = append(f.payments, bReq)
+	return &payment.CreatePaymentResponse{OrderId: bReq.TransactionDetails.OrderID, TxStatus: "pending"}, nil
+}
+
+// fakeStockReserver keeps the reservations of each saga, released ones are
+// removed.
+type fakeStockReserver struct {
+	reserved map[uuid.UUID][]reservations.Item
+	releases int
+	err      error
+}
+
+func newFakeStockReserver() *fakeStockReserver {
+	return &fakeStockReserver{reserved: map[uuid.UUID][]reservations.Item{}}
+}
+
+func (f *fakeStockReserver) Reserve(sagaID uuid.UUID, orderID string, items []reservations.Item) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	f.reserved[sagaID] = items
+	return nil
+}
+
+func (f *fakeStockReserver) ReleaseSaga(sagaID uuid.UUID) error {
+	f.releases++
+	delete(f.reserved, sagaID)
+	return nil
+}
+
+func newOrderRequest(lines ...order.ProductOrder) order.CreateOrderRequest {
+	return order.CreateOrderRequest{
+		UserID:       uuid.New(),
+		OrderNumber:  "ORD-1",
+		Status:       "Pending",
+		ProductOrder: lines,
+	}
+}
+
+func stepNames(entries []checkout.StepLog) []string {
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Step+":"+entry.Status)
+	}
+
+	return names
+}
+
+func TestCheckoutUsecase_Checkout(t *testing.T) {
+	t.Run("successful checkout", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(
+			order.ProductOrder{ProductID: "p1", Qty: 2},
+			order.ProductOrder{ProductID: "p2", Qty: 1},
+			order.ProductOrder{ProductID: "p1", Qty: 1},
+		))
+
+		assert.NoError(t, err)
+		assert.Equal(t, checkout.StatusCompleted, saga.Status)
+		assert.Equal(t, 55.0, saga.State.Request.TotalPrice)
+		assert.Equal(t, []reservations.Item{{ProductID: "p1", Qty: 3}, {ProductID: "p2", Qty: 1}}, reserver.reserved[saga.Id])
+		assert.Equal(t, map[string]int{"p1": 5, "p2": 1}, client.stock)
+		assert.Equal(t, saga.State.OrderID, saga.State.Payment.OrderId)
+		assert.Equal(t, &order.CustomExpiry{ExpiryDuration: 23 * 60, Unit: "minute"}, client.payments[0].CustomExpiry)
+		assert.Equal(t, checkout.StatusCompleted, repo.sagas[saga.Id].Status)
+		assert.Equal(t, []string{
+			"price_order:started", "price_order:succeeded",
+			"create_order:started", "create_order:succeeded",
+			"reserve_stock:started", "reserve_stock:succeeded",
+			"create_payment:started", "create_payment:succeeded",
+		}, stepNames(repo.steps[saga.Id]))
+	})
+
+	t.Run("out of stock", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(
+			order.ProductOrder{ProductID: "p2", Qty: 1},
+			order.ProductOrder{ProductID: "p2", Qty: 1},
+		))
+
+		assert.ErrorIs(t, err, checkout.ErrOutOfStock)
+		assert.Equal(t, checkout.StatusCompensated, saga.Status)
+		assert.Empty(t, client.orders)
+		assert.Equal(t, 0, reserver.releases)
+	})
+
+	t.Run("stock reserved by other checkouts", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		reserver.err = reservations.ErrOutOfStock
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(order.ProductOrder{ProductID: "p1", Qty: 1}))
+
+		assert.ErrorIs(t, err, checkout.ErrOutOfStock)
+		assert.Equal(t, checkout.StatusCompensated, saga.Status)
+		assert.Equal(t, checkout.OrderCancelled, client.orders[saga.State.OrderID])
+	})
+
+	t.Run("unknown product", func(t *testing.T) {
+		u := NewCheckoutUsecase(newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver())
+
+		_, err := u.Checkout(uuid.New(), newOrderRequest(order.ProductOrder{ProductID: "p9", Qty: 1}))
+
+		assert.ErrorIs(t, err, checkout.ErrProductNotFound)
+	})
+
+	t.Run("rejected payment releases the stock and cancels the order", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		client.failOn["create_payment"] = errPaymentRejected
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(order.ProductOrder{ProductID: "p1", Qty: 2}))
+
+		assert.ErrorIs(t, err, errPaymentRejected)
+		assert.Equal(t, checkout.StatusCompensated, saga.Status)
+		assert.Empty(t, reserver.reserved)
+		assert.Equal(t, checkout.OrderCancelled, client.orders[saga.State.OrderID])
+		assert.Equal(t, errPaymentRejected.Error(), *repo.sagas[saga.Id].Error)
+		assert.Equal(t, []string{
+			"price_order:started", "price_order:succeeded",
+			"create_order:started", "create_order:succeeded",
+			"reserve_stock:started", "reserve_stock:succeeded",
+			"create_payment:started", "create_payment:failed",
+			"release_stock:started", "release_stock:succeeded",
+			"cancel_order:started", "cancel_order:succeeded",
+		}, stepNames(repo.steps[saga.Id]))
+	})
+
+	t.Run("failed compensation waits for an admin", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		client.failOn["create_payment"] = errPaymentRejected
+		client.failOn["cancel_order"] = errors.New("order service down")
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(order.ProductOrder{ProductID: "p1", Qty: 2}))
+
+		assert.ErrorIs(t, err, errPaymentRejected)
+		assert.Equal(t, checkout.StatusFailed, repo.sagas[saga.Id].Status)
+		assert.Empty(t, reserver.reserved)
+
+		delete(client.failOn, "cancel_order")
+		resumed, err := u.ResumeFailed(saga.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, checkout.StatusCompensated, resumed.Status)
+		assert.Equal(t, checkout.OrderCancelled, client.orders[saga.State.OrderID])
+		assert.Equal(t, 1, reserver.releases)
+
+		_, err = u.ResumeFailed(saga.Id)
+		assert.ErrorIs(t, err, checkout.ErrNotResumable)
+	})
+
+	t.Run("payment timeout waits for an admin", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		client.failOn["create_payment"] = errors.New("context deadline exceeded")
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(order.ProductOrder{ProductID: "p1", Qty: 2}))
+
+		assert.ErrorIs(t, err, errOutcomeUnknown)
+		assert.Equal(t, checkout.StatusFailed, repo.sagas[saga.Id].Status)
+		assert.NotEmpty(t, reserver.reserved, "the payment may exist, its stock stays reserved")
+		assert.Equal(t, "Pending", client.orders[saga.State.OrderID])
+		assert.Equal(t, []string{
+			"price_order:started", "price_order:succeeded",
+			"create_order:started", "create_order:succeeded",
+			"reserve_stock:started", "reserve_stock:succeeded",
+			"create_payment:started", "create_payment:failed",
+		}, stepNames(repo.steps[saga.Id]))
+	})
+
+	t.Run("order service error waits for an admin", func(t *testing.T) {
+		repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+		client.failOn["create_order"] = &serviceClient.StatusError{Service: "order", StatusCode: 502}
+		u := NewCheckoutUsecase(repo, client, reserver)
+
+		saga, err := u.Checkout(uuid.New(), newOrderRequest(order.ProductOrder{ProductID: "p1", Qty: 2}))
+
+		assert.ErrorIs(t, err, errOutcomeUnknown)
+		assert.Equal(t, checkout.StatusFailed, repo.sagas[saga.Id].Status)
+		assert.Equal(t, 0, reserver.releases)
+	})
+}
+
+func TestCheckoutUsecase_ResumeStale(t *testing.T) {
+	staleAt := time.Now().Add(-staleAfter - time.Minute)
+	newSaga := func(status string, step string, state checkout.State) checkout.Saga {
+		return checkout.Saga{
+			Id:        uuid.New(),
+			UserID:    uuid.New(),
+			Status:    status,
+			Step:      step,
+			State:     state,
+			CreatedAt: &staleAt,
+			UpdatedAt: &staleAt,
+		}
+	}
+
+	repo, client, reserver := newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver()
+	u := NewCheckoutUsecase(repo, client, reserver)
+
+	orderID := uuid.NewString()
+	client.orders[orderID] = "Pending"
+
+	// Crashed after reserving, before the payment started
+	betweenSteps := newSaga(checkout.StatusRunning, checkout.StepReserveStock, checkout.State{
+		OrderID: orderID,
+		Stock:   []reservations.Item{{ProductID: "p1", Qty: 2}},
+	})
+	reserver.reserved[betweenSteps.Id] = betweenSteps.State.Stock
+	// Crashed while reserving, the reservation may be stored
+	duringReserve := newSaga(checkout.StatusRunning, checkout.StepReserveStock, checkout.State{OrderID: uuid.NewString()})
+	reserver.reserved[duringReserve.Id] = []reservations.Item{{ProductID: "p1", Qty: 1}}
+	// Crashed while the order service was creating the order
+	duringStep := newSaga(checkout.StatusRunning, checkout.StepCreateOrder, checkout.State{})
+	// Crashed right after the payment was opened
+	paid := newSaga(checkout.StatusRunning, checkout.StepCreatePayment, checkout.State{
+		OrderID: uuid.NewString(),
+		Payment: &payment.CreatePaymentResponse{TxStatus: "pending"},
+	})
+	reserver.reserved[paid.Id] = []reservations.Item{{ProductID: "p2", Qty: 1}}
+	// Still running in another process
+	recent := newSaga(checkout.StatusRunning, checkout.StepCreateOrder, checkout.State{})
+	timeNow := time.Now()
+	recent.UpdatedAt = &timeNow
+
+	for _, saga := range []checkout.Saga{betweenSteps, duringReserve, duringStep, paid, recent} {
+		repo.sagas[saga.Id] = saga
+	}
+
+	assert.NoError(t, u.ResumeStale())
+
+	assert.Equal(t, checkout.StatusCompensated, repo.sagas[betweenSteps.Id].Status)
+	assert.Equal(t, checkout.OrderCancelled, client.orders[orderID])
+
+	assert.Equal(t, checkout.StatusCompensated, repo.sagas[duringReserve.Id].Status)
+	assert.Equal(t, map[uuid.UUID][]reservations.Item{paid.Id: {{ProductID: "p2", Qty: 1}}}, reserver.reserved)
+
+	assert.Equal(t, checkout.StatusFailed, repo.sagas[duringStep.Id].Status)
+	assert.Contains(t, *repo.sagas[duringStep.Id].Error, "create_order")
+
+	assert.Equal(t, checkout.StatusCompleted, repo.sagas[paid.Id].Status)
+	assert.Equal(t, checkout.StatusRunning, repo.sagas[recent.Id].Status)
+}
+
+func TestCheckoutUsecase_GetSagas(t *testing.T) {
+	u := NewCheckoutUsecase(newFakeCheckoutRepository(), newFakeCheckoutClient(), newFakeStockReserver())
+
+	_, err := u.GetSagas(checkout.RequestSagas{Status: "done"})
+	assert.ErrorIs(t, err, checkout.ErrInvalidStatus)
+
+	bResp, err := u.GetSagas(checkout.RequestSagas{Status: checkout.StatusFailed})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bResp.FilteredPage)
+}
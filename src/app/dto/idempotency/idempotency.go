@@ -0,0 +1,108 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+	"user-service/src/util/repository/model/idempotency"
+
+	"github.com/google/uuid"
+)
+
+type idempotencyRepository interface {
+	Begin(userID uuid.UUID, key string, requestHash string, now time.Time, expiredBefore time.Time, lockedUntil time.Time) (*idempotency.Record, bool, error)
+	SetSaga(userID uuid.UUID, key string, sagaID uuid.UUID) error
+	Complete(userID uuid.UUID, key string, statusCode int, response []byte, now time.Time) error
+	Release(userID uuid.UUID, key string) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+// keyTTL is how long a key is remembered. A retry after that runs again.
+const keyTTL = 24 * time.Hour
+
+// claimLease is how long a request holds its key. It has to be well above the
+// time the longest request takes, a checkout makes at most six calls of 10s.
+// A retry after that takes the key over, the request that held it is taken
+// to have crashed or hung.
+const claimLease = 2 * time.Minute
+
+// IdempotencyUsecase makes retried requests return the response of the first
+// attempt instead of running again. Keys are scoped per user.
+type IdempotencyUsecase struct {
+	idempotency idempotencyRepository
+}
+
+func NewIdempotencyUsecase(idempotency idempotencyRepository) *IdempotencyUsecase {
+	return &IdempotencyUsecase{
+		idempotency: idempotency,
+	}
+}
+
+// Begin claims key for a request. It returns nil when the request has to run
+// and the completed record when it is a replay. A retry that took over the
+// claim of a request which already started a checkout gets the claimed
+// record, its SagaID is the checkout to answer with. Reusing a key for another
+// request is ErrKeyReused, retrying while the first attempt still holds the
+// key is ErrRequestInProgress.
+func (u *IdempotencyUsecase) Begin(userID uuid.UUID, key string, request []byte) (*idempotency.Record, error) {
+	if key == "" || len(key) > idempotency.MaxKeyLength {
+		return nil, idempotency.ErrInvalidKey
+	}
+
+	sum := sha256.Sum256(request)
+	requestHash := hex.EncodeToString(sum[:])
+
+	timeNow := time.Now()
+	record, created, err := u.idempotency.Begin(userID, key, requestHash, timeNow, timeNow.Add(-keyTTL), timeNow.Add(claimLease))
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		if record.SagaID != nil {
+			return record, nil
+		}
+		return nil, nil
+	}
+
+	if record.RequestHash != requestHash {
+		return nil, idempotency.ErrKeyReused
+	}
+
+	if !record.Completed() {
+		return nil, idempotency.ErrRequestInProgress
+	}
+
+	return record, nil
+}
+
+// SetSaga records the saga the request holding key started, before it makes
+// any change a retry must not repeat.
+func (u *IdempotencyUsecase) SetSaga(userID uuid.UUID, key string, sagaID uuid.UUID) error {
+	return u.idempotency.SetSaga(userID, key, sagaID)
+}
+
+// Complete stores the final response so replays of key return it.
+func (u *IdempotencyUsecase) Complete(userID uuid.UUID, key string, statusCode int, response []byte) error {
+	return u.idempotency.Complete(userID, key, statusCode, response, time.Now())
+}
+
+// Release frees key after a request that failed before changing anything, so
+// the client can retry it with the same key.
+func (u *IdempotencyUsecase) Release(userID uuid.UUID, key string) error {
+	return u.idempotency.Release(userID, key)
+}
+
+func (u *IdempotencyUsecase) PurgeExpired() error {
+	affected, err := u.idempotency.DeleteExpired(time.Now().Add(-keyTTL))
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		log.Printf("[IDEMPOTENCY] purged %d expired keys", affected)
+	}
+
+	return nil
+}
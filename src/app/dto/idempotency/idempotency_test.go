@@ -0,0 +1,217 @@
+package idempotency
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"user-service/src/util/repository/model/idempotency"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordKey struct {
+	userID uuid.UUID
+	key    string
+}
+
+type fakeIdempotencyRepository struct {
+	records map[recordKey]*idempotency.Record
+}
+
+func newFakeIdempotencyRepository() *fakeIdempotencyRepository {
+	return &fakeIdempotencyRepository{
+		records: make(map[recordKey]*idempotency.Record),
+	}
+}
+
+func (f *fakeIdempotencyRepository) Begin(userID uuid.UUID, key string, requestHash string, now time.Time, expiredBefore time.Time, lockedUntil time.Time) (*idempotency.Record, bool, error) {
+	if record, ok := f.records[recordKey{userID, key}]; ok && !record.CreatedAt.Before(expiredBefore) {
+		abandoned := !record.Completed() && record.LockedUntil.Before(now) && record.RequestHash == requestHash
+		if !abandoned {
+			result := *record
+			return &result, false, nil
+		}
+	}
+
+	record := &idempotency.Record{UserID: userID, Key: key, RequestHash: requestHash, CreatedAt: &now, LockedUntil: &lockedUntil}
+	if previous, ok := f.records[recordKey{userID, key}]; ok && !previous.CreatedAt.Before(expiredBefore) {
+		record.SagaID = previous.SagaID
+	}
+	f.records[recordKey{userID, key}] = record
+	result := *record
+	return &result, true, nil
+}
+
+func (f *fakeIdempotencyRepository) SetSaga(userID uuid.UUID, key string, sagaID uuid.UUID) error {
+	if record, ok := f.records[recordKey{userID, key}]; ok && !record.Completed() {
+		record.SagaID = &sagaID
+	}
+
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Complete(userID uuid.UUID, key string, statusCode int, response []byte, now time.Time) error {
+	if record, ok := f.records[recordKey{userID, key}]; ok && !record.Completed() {
+		record.StatusCode = &statusCode
+		record.Response = response
+		record.CompletedAt = &now
+	}
+
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Release(userID uuid.UUID, key string) error {
+	if record, ok := f.records[recordKey{userID, key}]; ok && !record.Completed() {
+		delete(f.records, recordKey{userID, key})
+	}
+
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) DeleteExpired(before time.Time) (int64, error) {
+	var affected int64
+	for key, record := range f.records {
+		if record.CreatedAt.Before(before) {
+			delete(f.records, key)
+			affected++
+		}
+	}
+
+	return affected, nil
+}
+
+func TestIdempotencyUsecase_Begin(t *testing.T) {
+	userID := uuid.New()
+	body := []byte(`{"product_order":[{"product_id":"p1","qty":1}]}`)
+
+	t.Run("first request runs", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+
+		record, err := u.Begin(userID, "key-1", body)
+
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("replay returns the stored response", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+		assert.NoError(t, u.Complete(userID, "key-1", http.StatusCreated, []byte(`{"message":"Success"}`)))
+
+		record, err := u.Begin(userID, "key-1", body)
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, record) {
+			assert.Equal(t, http.StatusCreated, *record.StatusCode)
+			assert.Equal(t, `{"message":"Success"}`, string(record.Response))
+		}
+	})
+
+	t.Run("key reused with another body", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+		assert.NoError(t, u.Complete(userID, "key-1", http.StatusCreated, nil))
+
+		_, err = u.Begin(userID, "key-1", []byte(`{"product_order":[{"product_id":"p1","qty":2}]}`))
+
+		assert.ErrorIs(t, err, idempotency.ErrKeyReused)
+	})
+
+	t.Run("retry while the first request runs", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+
+		_, err = u.Begin(userID, "key-1", body)
+
+		assert.ErrorIs(t, err, idempotency.ErrRequestInProgress)
+	})
+
+	t.Run("retry takes over an abandoned claim", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		u := NewIdempotencyUsecase(repo)
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+
+		lockedUntil := time.Now().Add(-time.Second)
+		repo.records[recordKey{userID, "key-1"}].LockedUntil = &lockedUntil
+
+		_, err = u.Begin(userID, "key-1", []byte(`{"product_order":[{"product_id":"p1","qty":2}]}`))
+		assert.ErrorIs(t, err, idempotency.ErrKeyReused, "only a retry of the same request takes over")
+
+		record, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+		assert.True(t, repo.records[recordKey{userID, "key-1"}].LockedUntil.After(time.Now()))
+	})
+
+	t.Run("retry takes over the saga of an abandoned claim", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		u := NewIdempotencyUsecase(repo)
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+		sagaID := uuid.New()
+		assert.NoError(t, u.SetSaga(userID, "key-1", sagaID))
+
+		lockedUntil := time.Now().Add(-time.Second)
+		repo.records[recordKey{userID, "key-1"}].LockedUntil = &lockedUntil
+
+		record, err := u.Begin(userID, "key-1", body)
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, record) {
+			assert.False(t, record.Completed())
+			assert.Equal(t, &sagaID, record.SagaID)
+		}
+	})
+
+	t.Run("released key runs again", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+		assert.NoError(t, u.Release(userID, "key-1"))
+
+		record, err := u.Begin(userID, "key-1", body)
+
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("keys are scoped per user", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+		_, err := u.Begin(userID, "key-1", body)
+		assert.NoError(t, err)
+
+		record, err := u.Begin(uuid.New(), "key-1", []byte(`{}`))
+
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		u := NewIdempotencyUsecase(newFakeIdempotencyRepository())
+
+		_, err := u.Begin(userID, "", body)
+		assert.ErrorIs(t, err, idempotency.ErrInvalidKey)
+
+		_, err = u.Begin(userID, strings.Repeat("k", idempotency.MaxKeyLength+1), body)
+		assert.ErrorIs(t, err, idempotency.ErrInvalidKey)
+	})
+}
+
+func TestIdempotencyUsecase_PurgeExpired(t *testing.T) {
+	repo := newFakeIdempotencyRepository()
+	u := NewIdempotencyUsecase(repo)
+
+	expired := time.Now().Add(-keyTTL - time.Minute)
+	repo.records[recordKey{uuid.New(), "old"}] = &idempotency.Record{Key: "old", CreatedAt: &expired}
+	_, err := u.Begin(uuid.New(), "new", []byte(`{}`))
+	assert.NoError(t, err)
+
+	assert.NoError(t, u.PurgeExpired())
+	assert.Len(t, repo.records, 1)
+}
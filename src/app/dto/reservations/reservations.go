@@ -0,0 +1,301 @@
+package reservations
+
+import (
+	"context"
+	"log"
+	"time"
+	"user-service/src/util/repository/model/locks"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/products"
+	"user-service/src/util/repository/model/reservations"
+
+	"github.com/google/uuid"
+)
+
+type reservationRepository interface {
+	Reserve(items []reservations.Reservation, stock map[string]int) error
+	GetOrder(orderID string) ([]reservations.Reservation, error)
+	GetHeld(productIDs []string) (map[string]int, error)
+	GetConfirming(productIDs []string) ([]reservations.Reservation, error)
+	MarkConfirming(items []reservations.Reservation, now time.Time) error
+	MarkConfirmed(ids []uuid.UUID, now time.Time) error
+	ReleaseOrder(orderID string, now time.Time) (int64, error)
+	ReleaseSaga(sagaID uuid.UUID, now time.Time) (int64, error)
+	ReleaseExpired(now time.Time) (int64, error)
+}
+
+type stockClient interface {
+	GetProducts(ctx context.Context, productIDs []string, limit string) ([]products.Product, error)
+	SetStock(ctx context.Context, updates []order.UpdateQtyRequest) error
+}
+
+// locker locks products. Calls to the product service made under the lock
+// use the returned context, so they cannot outlast it.
+type locker interface {
+	Lock(keys []string) (context.Context, func(), error)
+}
+
+// ReservationUsecase holds stock back for checkouts until they are paid. The
+// product service keeps the stock on hand, it is only written when a payment
+// settles, and only under the lock of the product.
+type ReservationUsecase struct {
+	reservations reservationRepository
+	client       stockClient
+	locker       locker
+}
+
+func NewReservationUsecase(reservations reservationRepository, client stockClient, locker locker) *ReservationUsecase {
+	return &ReservationUsecase{
+		reservations: reservations,
+		client:       client,
+		locker:       locker,
+	}
+}
+
+// Reserve holds items back for the order of a checkout, or returns
+// ErrOutOfStock when the stock not reserved yet does not cover them.
+func (u *ReservationUsecase) Reserve(sagaID uuid.UUID, orderID string, items []reservations.Item) error {
+	productIDs := make([]string, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+
+	ctx, unlock, err := u.lock(productIDs)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stock, err := u.getStock(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	timeNow := time.Now()
+	expiresAt := timeNow.Add(reservations.TTL)
+	result := make([]reservations.Reservation, len(items))
+	for i, item := range items {
+		result[i] = reservations.Reservation{
+			Id:        uuid.New(),
+			SagaID:    sagaID,
+			OrderID:   orderID,
+			ProductID: item.ProductID,
+			Qty:       item.Qty,
+			Status:    reservations.StatusReserved,
+			ExpiresAt: &expiresAt,
+			CreatedAt: &timeNow,
+			UpdatedAt: &timeNow,
+		}
+	}
+
+	return u.reservations.Reserve(result, stock)
+}
+
+// Confirm takes the reserved stock of a paid order from the product service.
+// An order confirmed before is left as it is, one interrupted in the middle
+// is finished without taking its stock twice. A payment settling after its
+// reservation was released takes the stock again if nobody reserved it
+// since, otherwise it fails with ErrStockUnavailable for an admin to sort
+// out.
+func (u *ReservationUsecase) Confirm(orderID string) error {
+	items, err := u.reservations.GetOrder(orderID)
+	if err != nil {
+		return err
+	}
+
+	if !hasStatus(items, reservations.StatusReserved, reservations.StatusConfirming, reservations.StatusReleased) {
+		return nil
+	}
+
+	productIDs := make([]string, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+
+	ctx, unlock, err := u.lock(productIDs)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := u.resolveConfirming(ctx, productIDs); err != nil {
+		return err
+	}
+
+	// Read again under the lock, a concurrent delivery may have confirmed it
+	items, err = u.reservations.GetOrder(orderID)
+	if err != nil {
+		return err
+	}
+
+	var open []reservations.Reservation
+	for _, item := range items {
+		if item.Status == reservations.StatusReserved || item.Status == reservations.StatusReleased {
+			open = append(open, item)
+		}
+	}
+
+	if len(open) == 0 {
+		return nil
+	}
+
+	stock, err := u.getStock(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	held, err := u.reservations.GetHeld(productIDs)
+	if err != nil {
+		return err
+	}
+
+	updates := make([]order.UpdateQtyRequest, len(open))
+	ids := make([]uuid.UUID, len(open))
+	for i, item := range open {
+		before := stock[item.ProductID]
+		after := before - item.Qty
+		if item.Status == reservations.StatusReleased && after < held[item.ProductID] {
+			log.Printf("[RESERVATION] order %s was paid after its reservation of product %s was released, the stock is reserved by others", orderID, item.ProductID)
+			return reservations.ErrStockUnavailable
+		}
+
+		if after < 0 {
+			log.Printf("[RESERVATION] order %s takes %d of product %s, only %d left", orderID, item.Qty, item.ProductID, before)
+			return reservations.ErrStockUnavailable
+		}
+
+		stock[item.ProductID] = after
+		open[i].StockBefore = &before
+		open[i].StockAfter = &after
+		updates[i] = order.UpdateQtyRequest{ProductId: item.ProductID, Stock: after}
+		ids[i] = item.Id
+	}
+
+	timeNow := time.Now()
+	if err := u.reservations.MarkConfirming(open, timeNow); err != nil {
+		return err
+	}
+
+	if err := u.client.SetStock(ctx, updates); err != nil {
+		return err
+	}
+
+	if err := u.reservations.MarkConfirmed(ids, timeNow); err != nil {
+		return err
+	}
+
+	log.Printf("[RESERVATION] confirmed %d reservations of order %s", len(open), orderID)
+	return nil
+}
+
+// resolveConfirming finishes the confirmations of the products that stopped
+// between recording the take and completing it. The stock tells whether the
+// product service got the write: it is either the recorded stock before or
+// after, stock writes only happen under the lock of the product.
+func (u *ReservationUsecase) resolveConfirming(ctx context.Context, productIDs []string) error {
+	pending, err := u.reservations.GetConfirming(productIDs)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	pendingIDs := make([]string, len(pending))
+	for i, item := range pending {
+		pendingIDs[i] = item.ProductID
+	}
+
+	stock, err := u.getStock(ctx, pendingIDs)
+	if err != nil {
+		return err
+	}
+
+	var redo []order.UpdateQtyRequest
+	ids := make([]uuid.UUID, len(pending))
+	for i, item := range pending {
+		switch stock[item.ProductID] {
+		case *item.StockAfter:
+		case *item.StockBefore:
+			redo = append(redo, order.UpdateQtyRequest{ProductId: item.ProductID, Stock: *item.StockAfter})
+		default:
+			log.Printf("[RESERVATION] stock of product %s is %d, confirming order %s expected %d or %d", item.ProductID, stock[item.ProductID], item.OrderID, *item.StockBefore, *item.StockAfter)
+			return reservations.ErrStockChanged
+		}
+		ids[i] = item.Id
+	}
+
+	if len(redo) > 0 {
+		if err := u.client.SetStock(ctx, redo); err != nil {
+			return err
+		}
+	}
+
+	return u.reservations.MarkConfirmed(ids, time.Now())
+}
+
+// Release gives the reserved stock of an unpaid order back.
+func (u *ReservationUsecase) Release(orderID string) error {
+	affected, err := u.reservations.ReleaseOrder(orderID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		log.Printf("[RESERVATION] released %d reservations of order %s", affected, orderID)
+	}
+
+	return nil
+}
+
+// ReleaseSaga gives the reserved stock of a compensated checkout back.
+func (u *ReservationUsecase) ReleaseSaga(sagaID uuid.UUID) error {
+	_, err := u.reservations.ReleaseSaga(sagaID, time.Now())
+	return err
+}
+
+// ReleaseExpired gives back the stock of the orders not paid in time.
+func (u *ReservationUsecase) ReleaseExpired() error {
+	affected, err := u.reservations.ReleaseExpired(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		log.Printf("[RESERVATION] released %d expired reservations", affected)
+	}
+
+	return nil
+}
+
+func hasStatus(items []reservations.Reservation, statuses ...string) bool {
+	for _, item := range items {
+		for _, status := range statuses {
+			if item.Status == status {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (u *ReservationUsecase) lock(productIDs []string) (context.Context, func(), error) {
+	keys := make([]string, len(productIDs))
+	for i, productID := range productIDs {
+		keys[i] = locks.ProductKey(productID)
+	}
+
+	return u.locker.Lock(keys)
+}
+
+func (u *ReservationUsecase) getStock(ctx context.Context, productIDs []string) (map[string]int, error) {
+	items, err := u.client.GetProducts(ctx, productIDs, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stock := make(map[string]int, len(items))
+	for _, item := range items {
+		stock[item.Id] = item.Stock
+	}
+
+	return stock, nil
+}
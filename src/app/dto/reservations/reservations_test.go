@@ -0,0 +1,521 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/products"
+	"user-service/src/util/repository/model/reservations"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeReservationRepository works like the store under read committed:
+// Reserve sums and inserts in separate statements, only the product locks
+// keep two checkouts from reserving the same stock.
+type fakeReservationRepository struct {
+	mu               sync.Mutex
+	items            []reservations.Reservation
+	markConfirmedErr error
+}
+
+func (f *fakeReservationRepository) Reserve(items []reservations.Reservation, stock map[string]int) error {
+	wanted := map[string]int{}
+	for _, item := range items {
+		wanted[item.ProductID] += item.Qty
+	}
+
+	for productID, qty := range wanted {
+		if f.held(productID)+qty > stock[productID] {
+			return reservations.ErrOutOfStock
+		}
+	}
+
+	// Widens the gap between the sum and the insert
+	time.Sleep(time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.items = append(f.items, items...)
+	return nil
+}
+
+func (f *fakeReservationRepository) GetOrder(orderID string) ([]reservations.Reservation, error) {
+	return f.filter(func(item reservations.Reservation) bool { return item.OrderID == orderID }), nil
+}
+
+func (f *fakeReservationRepository) GetHeld(productIDs []string) (map[string]int, error) {
+	held := map[string]int{}
+	for _, productID := range productIDs {
+		held[productID] = f.held(productID)
+	}
+
+	return held, nil
+}
+
+func (f *fakeReservationRepository) GetConfirming(productIDs []string) ([]reservations.Reservation, error) {
+	return f.filter(func(item reservations.Reservation) bool {
+		if item.Status != reservations.StatusConfirming {
+			return false
+		}
+
+		for _, productID := range productIDs {
+			if item.ProductID == productID {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (f *fakeReservationRepository) MarkConfirming(items []reservations.Reservation, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	indexes := make([]int, len(items))
+	for i, item := range items {
+		indexes[i] = f.index(item.Id)
+		if f.items[indexes[i]].Status != item.Status {
+			return reservations.ErrNotConfirmable
+		}
+	}
+
+	for i, item := range items {
+		stored := &f.items[indexes[i]]
+		stored.Status = reservations.StatusConfirming
+		stored.StockBefore = item.StockBefore
+		stored.StockAfter = item.StockAfter
+	}
+
+	return nil
+}
+
+func (f *fakeReservationRepository) MarkConfirmed(ids []uuid.UUID, now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.markConfirmedErr != nil {
+		return f.markConfirmedErr
+	}
+
+	for _, id := range ids {
+		if stored := &f.items[f.index(id)]; stored.Status == reservations.StatusConfirming {
+			stored.Status = reservations.StatusConfirmed
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeReservationRepository) ReleaseOrder(orderID string, now time.Time) (int64, error) {
+	return f.release(func(item reservations.Reservation) bool { return item.OrderID == orderID }), nil
+}
+
+func (f *fakeReservationRepository) ReleaseSaga(sagaID uuid.UUID, now time.Time) (int64, error) {
+	return f.release(func(item reservations.Reservation) bool { return item.SagaID == sagaID }), nil
+}
+
+func (f *fakeReservationRepository) ReleaseExpired(now time.Time) (int64, error) {
+	return f.release(func(item reservations.Reservation) bool { return item.ExpiresAt.Before(now) }), nil
+}
+
+func (f *fakeReservationRepository) release(match func(item reservations.Reservation) bool) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var affected int64
+	for i, item := range f.items {
+		if item.Status == reservations.StatusReserved && match(item) {
+			f.items[i].Status = reservations.StatusReleased
+			affected++
+		}
+	}
+
+	return affected
+}
+
+func (f *fakeReservationRepository) filter(match func(item reservations.Reservation) bool) []reservations.Reservation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []reservations.Reservation
+	for _, item := range f.items {
+		if match(item) {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func (f *fakeReservationRepository) index(id uuid.UUID) int {
+	for i, item := range f.items {
+		if item.Id == id {
+			return i
+		}
+	}
+
+	panic("unknown reservation " + id.String())
+}
+
+// held is the quantity of the product other checkouts cannot reserve.
+func (f *fakeReservationRepository) held(productID string) int {
+	var total int
+	for _, item := range f.filter(func(item reservations.Reservation) bool { return item.ProductID == productID }) {
+		if item.Status == reservations.StatusReserved || item.Status == reservations.StatusConfirming {
+			total += item.Qty
+		}
+	}
+
+	return total
+}
+
+// fakeStockClient reads and writes stock in separate calls like the product
+// service, only the locks keep concurrent updates from being lost.
+type fakeStockClient struct {
+	mu     sync.Mutex
+	stock  map[string]int
+	setErr error
+	// lostReply applies the write but returns setErr, like a timeout
+	lostReply bool
+	sets      int
+}
+
+func (f *fakeStockClient) GetProducts(ctx context.Context, productIDs []string, limit string) ([]products.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var items []products.Product
+	for _, id := range productIDs {
+		if stock, ok := f.stock[id]; ok {
+			items = append(items, products.Product{Id: id, Stock: stock})
+		}
+	}
+
+	return items, nil
+}
+
+func (f *fakeStockClient) SetStock(ctx context.Context, updates []order.UpdateQtyRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Widens the gap between reading and writing the stock
+	time.Sleep(time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.setErr != nil && !f.lostReply {
+		return f.setErr
+	}
+
+	f.sets++
+	for _, update := range updates {
+		f.stock[update.ProductId] = update.Stock
+	}
+
+	return f.setErr
+}
+
+func (f *fakeStockClient) get(productID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.stock[productID]
+}
+
+// fakeLocker is an in-process stand-in for the advisory locks.
+type fakeLocker struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (f *fakeLocker) Lock(keys []string) (context.Context, func(), error) {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+
+	var held []*sync.Mutex
+	for i, key := range keys {
+		if i > 0 && keys[i-1] == key {
+			continue
+		}
+
+		f.mu.Lock()
+		if f.locks == nil {
+			f.locks = map[string]*sync.Mutex{}
+		}
+		lock, ok := f.locks[key]
+		if !ok {
+			lock = &sync.Mutex{}
+			f.locks[key] = lock
+		}
+		f.mu.Unlock()
+
+		lock.Lock()
+		held = append(held, lock)
+	}
+
+	return context.Background(), func() {
+		for _, lock := range held {
+			lock.Unlock()
+		}
+	}, nil
+}
+
+// noLocker locks nothing, to show what the product locks prevent.
+type noLocker struct{}
+
+func (noLocker) Lock(keys []string) (context.Context, func(), error) {
+	return context.Background(), func() {}, nil
+}
+
+// expiredLocker hands out locks whose hold time is already over.
+type expiredLocker struct{}
+
+func (expiredLocker) Lock(keys []string) (context.Context, func(), error) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	return ctx, cancel, nil
+}
+
+func newTestUsecase(stock map[string]int) (*ReservationUsecase, *fakeReservationRepository, *fakeStockClient) {
+	repo := &fakeReservationRepository{}
+	client := &fakeStockClient{stock: stock}
+	return NewReservationUsecase(repo, client, &fakeLocker{}), repo, client
+}
+
+// reserveConcurrently runs checkouts of one p1 and two p2 at once and returns
+// the orders that got their reservation.
+func reserveConcurrently(t *testing.T, u *ReservationUsecase, checkouts int) []string {
+	var wg sync.WaitGroup
+	errs := make([]error, checkouts)
+	orderIDs := make([]string, checkouts)
+	for i := 0; i < checkouts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			orderIDs[i] = uuid.NewString()
+			errs[i] = u.Reserve(uuid.New(), orderIDs[i], []reservations.Item{
+				{ProductID: "p2", Qty: 2},
+				{ProductID: "p1", Qty: 1},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	var reserved []string
+	for i, err := range errs {
+		if err == nil {
+			reserved = append(reserved, orderIDs[i])
+			continue
+		}
+		assert.ErrorIs(t, err, reservations.ErrOutOfStock)
+	}
+
+	return reserved
+}
+
+func TestReservationUsecase_ConcurrentCheckouts(t *testing.T) {
+	u, repo, client := newTestUsecase(map[string]int{"p1": 5, "p2": 50})
+
+	paid := reserveConcurrently(t, u, 20)
+	assert.Len(t, paid, 5)
+	assert.Equal(t, 5, repo.held("p1"))
+	assert.Equal(t, 10, repo.held("p2"))
+
+	// Every payment is notified twice, each order takes its stock once
+	var wg sync.WaitGroup
+	for _, orderID := range append(paid, paid...) {
+		wg.Add(1)
+		go func(orderID string) {
+			defer wg.Done()
+			assert.NoError(t, u.Confirm(orderID))
+		}(orderID)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, client.get("p1"))
+	assert.Equal(t, 40, client.get("p2"))
+	assert.Equal(t, 0, repo.held("p1"))
+	assert.Equal(t, 0, repo.held("p2"))
+
+	err := u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 1}})
+	assert.ErrorIs(t, err, reservations.ErrOutOfStock)
+}
+
+func TestReservationUsecase_ConcurrentCheckoutsWithoutLocks(t *testing.T) {
+	repo := &fakeReservationRepository{}
+	u := NewReservationUsecase(repo, &fakeStockClient{stock: map[string]int{"p1": 5, "p2": 50}}, noLocker{})
+
+	// The sums all run before the inserts, the stock is reserved many times
+	reserved := reserveConcurrently(t, u, 20)
+	assert.Greater(t, len(reserved), 5)
+	assert.Greater(t, repo.held("p1"), 5)
+}
+
+func TestReservationUsecase_Release(t *testing.T) {
+	t.Run("failed payment", func(t *testing.T) {
+		u, repo, client := newTestUsecase(map[string]int{"p1": 2})
+		orderID := uuid.NewString()
+
+		assert.NoError(t, u.Reserve(uuid.New(), orderID, []reservations.Item{{ProductID: "p1", Qty: 2}}))
+		assert.ErrorIs(t, u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 1}}), reservations.ErrOutOfStock)
+
+		assert.NoError(t, u.Release(orderID))
+		assert.Equal(t, 0, repo.held("p1"))
+		assert.NoError(t, u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 2}}))
+		assert.Equal(t, 2, client.get("p1"))
+	})
+
+	t.Run("settled after expiry with the stock still free", func(t *testing.T) {
+		u, repo, client := newTestUsecase(map[string]int{"p1": 5})
+		orderID := uuid.NewString()
+		assert.NoError(t, u.Reserve(uuid.New(), orderID, []reservations.Item{{ProductID: "p1", Qty: 2}}))
+		assert.NoError(t, u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 1}}))
+
+		expired := time.Now().Add(-time.Minute)
+		repo.items[0].ExpiresAt = &expired
+		assert.NoError(t, u.ReleaseExpired())
+
+		assert.NoError(t, u.Confirm(orderID))
+		assert.Equal(t, 3, client.get("p1"))
+		assert.Equal(t, reservations.StatusConfirmed, repo.items[0].Status)
+		assert.Equal(t, 1, repo.held("p1"))
+	})
+
+	t.Run("settled after expiry with the stock reserved by others", func(t *testing.T) {
+		u, repo, client := newTestUsecase(map[string]int{"p1": 2})
+		orderID := uuid.NewString()
+		assert.NoError(t, u.Reserve(uuid.New(), orderID, []reservations.Item{{ProductID: "p1", Qty: 2}}))
+
+		expired := time.Now().Add(-time.Minute)
+		repo.items[0].ExpiresAt = &expired
+		assert.NoError(t, u.ReleaseExpired())
+		assert.NoError(t, u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 1}}))
+
+		assert.ErrorIs(t, u.Confirm(orderID), reservations.ErrStockUnavailable)
+		assert.Equal(t, 2, client.get("p1"))
+		assert.Equal(t, reservations.StatusReleased, repo.items[0].Status)
+	})
+
+	t.Run("compensated checkout", func(t *testing.T) {
+		u, repo, _ := newTestUsecase(map[string]int{"p1": 2})
+		sagaID := uuid.New()
+
+		assert.NoError(t, u.Reserve(sagaID, uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 2}}))
+		assert.NoError(t, u.ReleaseSaga(sagaID))
+		assert.NoError(t, u.ReleaseSaga(sagaID))
+		assert.Equal(t, 0, repo.held("p1"))
+	})
+
+	t.Run("expired reservations", func(t *testing.T) {
+		u, repo, _ := newTestUsecase(map[string]int{"p1": 5})
+
+		assert.NoError(t, u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 2}}))
+		assert.NoError(t, u.Reserve(uuid.New(), uuid.NewString(), []reservations.Item{{ProductID: "p1", Qty: 3}}))
+
+		expired := time.Now().Add(-time.Minute)
+		repo.items[0].ExpiresAt = &expired
+
+		assert.NoError(t, u.ReleaseExpired())
+		assert.Equal(t, 3, repo.held("p1"))
+		assert.Equal(t, reservations.StatusReleased, repo.items[0].Status)
+	})
+}
+
+func TestReservationUsecase_Confirm(t *testing.T) {
+	newReserved := func(t *testing.T) (*ReservationUsecase, *fakeReservationRepository, *fakeStockClient, string) {
+		u, repo, client := newTestUsecase(map[string]int{"p1": 5})
+		orderID := uuid.NewString()
+		assert.NoError(t, u.Reserve(uuid.New(), orderID, []reservations.Item{{ProductID: "p1", Qty: 2}}))
+		return u, repo, client, orderID
+	}
+
+	t.Run("no product service call outlasts the lock", func(t *testing.T) {
+		u, repo, client, orderID := newReserved(t)
+
+		u.locker = expiredLocker{}
+		assert.ErrorIs(t, u.Confirm(orderID), context.DeadlineExceeded)
+		assert.Equal(t, reservations.StatusReserved, repo.items[0].Status)
+		assert.Equal(t, 5, client.get("p1"))
+		assert.Zero(t, client.sets)
+	})
+
+	t.Run("stock write failed", func(t *testing.T) {
+		u, repo, client, orderID := newReserved(t)
+
+		client.setErr = errors.New("product service down")
+		assert.EqualError(t, u.Confirm(orderID), "product service down")
+		assert.Equal(t, reservations.StatusConfirming, repo.items[0].Status)
+		assert.Equal(t, 2, repo.held("p1"))
+		assert.Equal(t, 5, client.get("p1"))
+
+		client.setErr = nil
+		assert.NoError(t, u.Confirm(orderID))
+		assert.Equal(t, 3, client.get("p1"))
+		assert.Equal(t, reservations.StatusConfirmed, repo.items[0].Status)
+	})
+
+	t.Run("stock written but the reply lost", func(t *testing.T) {
+		u, repo, client, orderID := newReserved(t)
+
+		client.setErr, client.lostReply = errors.New("timeout"), true
+		assert.Error(t, u.Confirm(orderID))
+		assert.Equal(t, 3, client.get("p1"))
+
+		client.setErr, client.lostReply = nil, false
+		assert.NoError(t, u.Confirm(orderID))
+		assert.Equal(t, 3, client.get("p1"))
+		assert.Equal(t, 1, client.sets)
+		assert.Equal(t, reservations.StatusConfirmed, repo.items[0].Status)
+	})
+
+	t.Run("confirmation not stored", func(t *testing.T) {
+		u, repo, client, orderID := newReserved(t)
+
+		repo.markConfirmedErr = errors.New("connection reset")
+		assert.Error(t, u.Confirm(orderID))
+		assert.Equal(t, 3, client.get("p1"))
+
+		repo.markConfirmedErr = nil
+		assert.NoError(t, u.Confirm(orderID))
+		assert.Equal(t, 3, client.get("p1"))
+		assert.Equal(t, 1, client.sets)
+	})
+
+	t.Run("interrupted confirmation is finished by the next on the product", func(t *testing.T) {
+		u, repo, client, orderID := newReserved(t)
+		otherID := uuid.NewString()
+		assert.NoError(t, u.Reserve(uuid.New(), otherID, []reservations.Item{{ProductID: "p1", Qty: 1}}))
+
+		client.setErr = errors.New("product service down")
+		assert.Error(t, u.Confirm(orderID))
+
+		client.setErr = nil
+		assert.NoError(t, u.Confirm(otherID))
+		assert.Equal(t, 2, client.get("p1"))
+		assert.Equal(t, reservations.StatusConfirmed, repo.items[0].Status)
+	})
+
+	t.Run("stock changed elsewhere meanwhile", func(t *testing.T) {
+		u, _, client, orderID := newReserved(t)
+
+		client.setErr = errors.New("product service down")
+		assert.Error(t, u.Confirm(orderID))
+
+		client.setErr = nil
+		client.stock["p1"] = 9
+		assert.ErrorIs(t, u.Confirm(orderID), reservations.ErrStockChanged)
+		assert.Equal(t, 9, client.get("p1"))
+	})
+}
@@ -0,0 +1,289 @@
+package tokens
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"user-service/src/util/helper/jwt"
+	"user-service/src/util/repository/model/sessions"
+	"user-service/src/util/repository/model/tokens"
+
+	"github.com/google/uuid"
+)
+
+type tokenRepository interface {
+	RevokeToken(jti uuid.UUID, userID uuid.UUID, expiresAt time.Time) error
+	IsTokenRevoked(jti uuid.UUID) (bool, error)
+	RevokeUserTokens(userID uuid.UUID) (*time.Time, error)
+	GetRevokedBefore(userID uuid.UUID) (*time.Time, error)
+	RevokeFamily(familyID uuid.UUID) error
+	DeleteExpiredTokens(before time.Time) (int64, error)
+}
+
+type sessionRepository interface {
+	GetSession(id uuid.UUID) (*sessions.Session, error)
+	RevokeSession(id uuid.UUID, userID uuid.UUID) error
+	RevokeUserSessions(userID uuid.UUID) error
+	TouchSession(id uuid.UUID, lastSeenAt time.Time) error
+}
+
+// revocationCacheTTL bounds how long a revocation made by another instance can
+// go unnoticed. Revocations made by this instance are visible immediately.
+const revocationCacheTTL = 30 * time.Second
+
+// lastSeenInterval throttles the last seen updates of a session to one write
+// per interval instead of one per request.
+const lastSeenInterval = time.Minute
+
+const maxCacheEntries = 100000
+
+type cacheEntry struct {
+	revoked   bool
+	cutoff    *time.Time
+	expiresAt time.Time
+}
+
+// TokenUsecase is the access token denylist and session check. The Postgres
+// tables are the source of truth, lookups are cached in process because they
+// run on every authenticated request.
+type TokenUsecase struct {
+	token   tokenRepository
+	session sessionRepository
+
+	mu              sync.RWMutex
+	revoked         map[string]cacheEntry
+	revokedBefore   map[string]cacheEntry
+	revokedSessions map[string]cacheEntry
+	lastSeen        map[string]cacheEntry
+}
+
+func NewTokenUsecase(token tokenRepository, session sessionRepository) *TokenUsecase {
+	return &TokenUsecase{
+		token:           token,
+		session:         session,
+		revoked:         make(map[string]cacheEntry),
+		revokedBefore:   make(map[string]cacheEntry),
+		revokedSessions: make(map[string]cacheEntry),
+		lastSeen:        make(map[string]cacheEntry),
+	}
+}
+
+func (u *TokenUsecase) Revoke(payload *jwt.Payload) error {
+	jti, err := uuid.Parse(payload.ID)
+	if err != nil {
+		return tokens.ErrTokenRevoked
+	}
+
+	userID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		return tokens.ErrTokenRevoked
+	}
+
+	if err := u.token.RevokeToken(jti, userID, payload.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	// A revoked token stays revoked, keep it cached until it expires anyway
+	u.set(u.revoked, payload.ID, cacheEntry{revoked: true, expiresAt: payload.ExpiresAt.Time})
+
+	return nil
+}
+
+// RevokeSession ends a session of the user: its refresh tokens can no longer
+// be exchanged and its access tokens are rejected by ValidateAccessToken.
+func (u *TokenUsecase) RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error {
+	if err := u.session.RevokeSession(sessionID, userID); err != nil {
+		return err
+	}
+
+	if err := u.token.RevokeFamily(sessionID); err != nil {
+		return err
+	}
+
+	u.set(u.revokedSessions, sessionID.String(), cacheEntry{revoked: true, expiresAt: time.Now().Add(time.Hour)})
+
+	return nil
+}
+
+// PurgeExpired removes the denylisted tokens that expired on their own.
+func (u *TokenUsecase) PurgeExpired() error {
+	affected, err := u.token.DeleteExpiredTokens(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		log.Printf("[TOKENS] purged %d expired revoked tokens", affected)
+	}
+
+	return nil
+}
+
+func (u *TokenUsecase) RevokeAll(userID uuid.UUID) error {
+	if err := u.session.RevokeUserSessions(userID); err != nil {
+		return err
+	}
+
+	revokedBefore, err := u.token.RevokeUserTokens(userID)
+	if err != nil {
+		return err
+	}
+
+	u.set(u.revokedBefore, userID.String(), cacheEntry{cutoff: revokedBefore, expiresAt: time.Now().Add(revocationCacheTTL)})
+
+	return nil
+}
+
+// ValidateAccessToken returns tokens.ErrTokenRevoked when the token itself was
+// revoked, its session was ended or it was issued before the user logged out
+// everywhere. Valid tokens bump the last seen time of their session.
+func (u *TokenUsecase) ValidateAccessToken(payload *jwt.Payload) error {
+	revoked, err := u.isRevoked(payload.ID)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return tokens.ErrTokenRevoked
+	}
+
+	revoked, err = u.isSessionRevoked(payload.SessionID, payload.UserID)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return tokens.ErrTokenRevoked
+	}
+
+	revokedBefore, err := u.getRevokedBefore(payload.UserID)
+	if err != nil {
+		return err
+	}
+
+	// iat has whole seconds, a token issued in the second of the cut-off was
+	// issued after it
+	if revokedBefore != nil && payload.IssuedAt != nil && payload.IssuedAt.Time.Unix() < revokedBefore.Unix() {
+		return tokens.ErrTokenRevoked
+	}
+
+	return u.touchSession(payload.SessionID)
+}
+
+func (u *TokenUsecase) isSessionRevoked(sessionID string, userID string) (bool, error) {
+	if entry, ok := u.get(u.revokedSessions, sessionID); ok {
+		return entry.revoked, nil
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return true, nil
+	}
+
+	session, err := u.session.GetSession(id)
+	if err != nil {
+		if errors.Is(err, sessions.ErrSessionNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	revoked := session.RevokedAt != nil || session.UserId.String() != userID
+	u.set(u.revokedSessions, sessionID, cacheEntry{revoked: revoked, expiresAt: time.Now().Add(revocationCacheTTL)})
+
+	return revoked, nil
+}
+
+func (u *TokenUsecase) touchSession(sessionID string) error {
+	if _, ok := u.get(u.lastSeen, sessionID); ok {
+		return nil
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return tokens.ErrTokenRevoked
+	}
+
+	timeNow := time.Now()
+	if err := u.session.TouchSession(id, timeNow); err != nil {
+		return err
+	}
+
+	u.set(u.lastSeen, sessionID, cacheEntry{expiresAt: timeNow.Add(lastSeenInterval)})
+
+	return nil
+}
+
+func (u *TokenUsecase) isRevoked(jti string) (bool, error) {
+	if entry, ok := u.get(u.revoked, jti); ok {
+		return entry.revoked, nil
+	}
+
+	id, err := uuid.Parse(jti)
+	if err != nil {
+		return true, nil
+	}
+
+	revoked, err := u.token.IsTokenRevoked(id)
+	if err != nil {
+		return false, err
+	}
+
+	u.set(u.revoked, jti, cacheEntry{revoked: revoked, expiresAt: time.Now().Add(revocationCacheTTL)})
+
+	return revoked, nil
+}
+
+func (u *TokenUsecase) getRevokedBefore(userID string) (*time.Time, error) {
+	if entry, ok := u.get(u.revokedBefore, userID); ok {
+		return entry.cutoff, nil
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, tokens.ErrTokenRevoked
+	}
+
+	revokedBefore, err := u.token.GetRevokedBefore(id)
+	if err != nil {
+		return nil, err
+	}
+
+	u.set(u.revokedBefore, userID, cacheEntry{cutoff: revokedBefore, expiresAt: time.Now().Add(revocationCacheTTL)})
+
+	return revokedBefore, nil
+}
+
+func (u *TokenUsecase) get(cache map[string]cacheEntry, key string) (cacheEntry, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	entry, ok := cache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (u *TokenUsecase) set(cache map[string]cacheEntry, key string, entry cacheEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(cache) >= maxCacheEntries {
+		timeNow := time.Now()
+		for k, v := range cache {
+			if timeNow.After(v.expiresAt) {
+				delete(cache, k)
+			}
+		}
+
+		// Everything is still live, the database remains the source of truth
+		if len(cache) >= maxCacheEntries {
+			clear(cache)
+		}
+	}
+
+	cache[key] = entry
+}
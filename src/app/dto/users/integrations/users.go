@@ -3,7 +3,6 @@ package integrations
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"user-service/src/util/helper/integrations"
 	"user-service/src/util/repository/model/users"
@@ -37,17 +36,17 @@ func (u *UserUsecase) GetUsers(bReq users.RequestUsers) (*[]users.Users, int, er
 
 func (u *UserUsecase) UserDataSignUp(state, code string) (*users.OauthUserData, error) {
 	if state != integrations.RandomString {
-		return nil, errors.New("invalid user state")
+		return nil, users.ErrInvalidOAuthState
 	}
 
 	token, err := integrations.SSOSignup.Exchange(context.Background(), code)
 	if err != nil {
-		return nil, errors.New("cannot retrieve token")
+		return nil, users.ErrOAuthExchange
 	}
 
 	provider, err := oidc.NewProvider(context.Background(), integrations.Provider)
 	if err != nil {
-		return nil, errors.New("invalid token signature")
+		return nil, users.ErrInvalidOAuthToken
 	}
 
 	verifier := provider.Verifier(&oidc.Config{
@@ -55,12 +54,12 @@ func (u *UserUsecase) UserDataSignUp(state, code string) (*users.OauthUserData,
 	})
 	_, err = verifier.Verify(context.Background(), token.Extra("id_token").(string))
 	if err != nil {
-		return nil, errors.New("invalid token signature")
+		return nil, users.ErrInvalidOAuthToken
 	}
 
 	result, err := http.Get(integrations.UserInfoURL + token.AccessToken)
 	if err != nil {
-		return nil, errors.New("cannot retrieve response")
+		return nil, users.ErrOAuthUnavailable
 	}
 	defer result.Body.Close()
 
@@ -74,17 +73,17 @@ func (u *UserUsecase) UserDataSignUp(state, code string) (*users.OauthUserData,
 
 func (u *UserUsecase) UserDataSignIn(state, code string) (*users.OauthUserData, error) {
 	if state != integrations.RandomString {
-		return nil, errors.New("invalid user state")
+		return nil, users.ErrInvalidOAuthState
 	}
 
 	token, err := integrations.SSOSignin.Exchange(context.Background(), code)
 	if err != nil {
-		return nil, errors.New("cannot retrieve token")
+		return nil, users.ErrOAuthExchange
 	}
 
 	provider, err := oidc.NewProvider(context.Background(), integrations.Provider)
 	if err != nil {
-		return nil, errors.New("invalid token signature")
+		return nil, users.ErrInvalidOAuthToken
 	}
 
 	verifier := provider.Verifier(&oidc.Config{
@@ -92,12 +91,12 @@ func (u *UserUsecase) UserDataSignIn(state, code string) (*users.OauthUserData,
 	})
 	_, err = verifier.Verify(context.Background(), token.Extra("id_token").(string))
 	if err != nil {
-		return nil, errors.New("invalid token signature")
+		return nil, users.ErrInvalidOAuthToken
 	}
 
 	result, err := http.Get(integrations.UserInfoURL + token.AccessToken)
 	if err != nil {
-		return nil, errors.New("cannot retrieve response")
+		return nil, users.ErrOAuthUnavailable
 	}
 	defer result.Body.Close()
 
@@ -1,12 +1,25 @@
 package users
 
 import (
+	"encoding/json"
 	"errors"
+	"log"
 	"math"
+	"net/url"
+	"strings"
 	"time"
+	"user-service/src/util/helper"
 	"user-service/src/util/helper/jwt"
+	"user-service/src/util/helper/totp"
+	"user-service/src/util/policy"
 	"user-service/src/util/repository/model"
+	"user-service/src/util/repository/model/audit"
+	"user-service/src/util/repository/model/mfa"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/sessions"
+	"user-service/src/util/repository/model/tokens"
 	"user-service/src/util/repository/model/users"
+	"user-service/src/util/repository/query"
 
 	"github.com/google/uuid"
 )
@@ -15,35 +28,328 @@ type userRepository interface {
 	RegisterUser(bReq users.Users) (*uuid.UUID, error)
 	GetUserDetails(bReq users.Users) (*users.Users, error)
 	GetUsers(bReq users.RequestUsers) (*[]users.Users, int, error)
-	UpdateUser(id uuid.UUID, bReq users.Users) error
+	UpdateUser(id uuid.UUID, bReq users.Users) (bool, error)
+	UpdateRole(id uuid.UUID, role string, entry audit.Entry) (string, error)
+	SuspendUser(id uuid.UUID, entry audit.Entry) error
+	UnsuspendUser(id uuid.UUID, entry audit.Entry) error
+	DeleteUser(id uuid.UUID, entry audit.Entry) error
+	RestoreUser(id uuid.UUID, entry audit.Entry) error
+	AuditUser(id uuid.UUID, entry audit.Entry) error
+	ScheduleAnonymization(id uuid.UUID, after time.Time, entry audit.Entry) error
+	AnonymizeUsers(now time.Time) (int64, error)
+	CreateEmailVerification(userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	VerifyEmail(tokenHash string) (*uuid.UUID, error)
+	CreatePasswordReset(userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash string, password string) (*uuid.UUID, error)
+}
+
+type tokenRepository interface {
+	CreateRefreshToken(bReq tokens.RefreshToken) error
+	UseRefreshToken(id uuid.UUID) (*tokens.RefreshToken, error)
+	RevokeFamily(familyID uuid.UUID) error
+	UseToken(jti uuid.UUID, userID uuid.UUID, expiresAt time.Time) (bool, error)
+}
+
+type sessionRepository interface {
+	CreateSession(bReq sessions.Session) error
+	GetSessions(userID uuid.UUID) (*[]sessions.Session, error)
+}
+
+type mfaRepository interface {
+	GetMFA(userID uuid.UUID) (*mfa.MFA, error)
+	SetupMFA(userID uuid.UUID, secret string, codeHashes []string) error
+	EnableMFA(userID uuid.UUID, step int64) error
+	UseTOTPStep(userID uuid.UUID, step int64) error
+	UseRecoveryCode(userID uuid.UUID, codeHash string) error
+	DeleteMFA(userID uuid.UUID) error
+}
+
+type attemptLimiter interface {
+	Begin(email string, ipAddress string) error
+	Fail(email string, ipAddress string) error
+	Refund(email string, ipAddress string) error
+	RecordSuccess(email string) error
+}
+
+type tokenRevoker interface {
+	Revoke(payload *jwt.Payload) error
+	RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error
+	RevokeAll(userID uuid.UUID) error
+}
+
+type orderClient interface {
+	GetOrders(userID uuid.UUID) ([]order.Order, error)
+}
+
+type mailer interface {
+	Send(to string, subject string, body string) error
 }
 
 type UserUsecase struct {
-	user userRepository
+	user    userRepository
+	token   tokenRepository
+	session sessionRepository
+	mfa     mfaRepository
+	attempt attemptLimiter
+	revoker tokenRevoker
+	orders  orderClient
+	mailer  mailer
+	appURL  string
+
+	// passwordResetURL is the frontend page reset mails link to
+	passwordResetURL string
 }
 
-func NewUserUsecase(user userRepository) *UserUsecase {
+const (
+	accessTokenExpiry       = time.Minute * 20
+	refreshTokenExpiry      = time.Hour * 72
+	emailVerificationExpiry = time.Hour * 24
+	passwordResetExpiry     = time.Hour
+	mfaTokenExpiry          = time.Minute * 5
+
+	// deletionGracePeriod is how long a deleted account can still be
+	// restored before its personal data is scrubbed
+	deletionGracePeriod = time.Hour * 24 * 30
+
+	mfaIssuer         = "Shopifun"
+	recoveryCodeCount = 10
+)
+
+func NewUserUsecase(user userRepository, token tokenRepository, session sessionRepository, mfa mfaRepository, attempt attemptLimiter, revoker tokenRevoker, orders orderClient, mailer mailer, appURL string, passwordResetURL string) *UserUsecase {
 	return &UserUsecase{
-		user: user,
+		user:    user,
+		token:   token,
+		session: session,
+		mfa:     mfa,
+		attempt: attempt,
+		revoker: revoker,
+		orders:  orders,
+		mailer:  mailer,
+		appURL:  strings.TrimSuffix(appURL, "/"),
+
+		passwordResetURL: passwordResetURL,
 	}
 }
 
-func (u *UserUsecase) UpdateProfile(id uuid.UUID, bReq users.Users) error {
-	if err := u.user.UpdateUser(id, bReq); err != nil {
+func (u *UserUsecase) UpdateProfile(actor policy.Actor, id uuid.UUID, bReq users.UpdateProfileRequest) error {
+	if err := policy.CanModifyUser(actor, id); err != nil {
 		return err
 	}
 
+	emailChanged, err := u.user.UpdateUser(id, users.Users{
+		Email:               users.NormalizeEmail(bReq.Email),
+		Address:             bReq.Address,
+		CategoryPreferences: bReq.CategoryPreferences,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The new address is unverified until its owner confirms it, like at
+	// registration
+	if emailChanged {
+		return u.sendVerification(id, bReq.Email)
+	}
+
 	return nil
 }
 
-func (u *UserUsecase) Register(bReq users.Users) (*uuid.UUID, error) {
-	usrInfo, err := u.user.GetUserDetails(bReq)
+// ChangeRole is the only way to change the role of a user. Every change is
+// audited and signs the user out, so the old role does not live on in issued
+// access tokens.
+func (u *UserUsecase) ChangeRole(actor policy.Actor, id uuid.UUID, role string) error {
+	if err := policy.CanChangeRole(actor); err != nil {
+		return err
+	}
+
+	previousRole, err := u.user.UpdateRole(id, role, newAuditEntry(actor, audit.ActionUserRoleChanged, id))
+	if err != nil {
+		return err
+	}
+
+	if previousRole == role {
+		return nil
+	}
+
+	return u.revoker.RevokeAll(id)
+}
+
+// GetUser returns any user for the admin API, suspended and deleted ones
+// included.
+func (u *UserUsecase) GetUser(id uuid.UUID) (*users.Users, error) {
+	result, _, err := u.user.GetUsers(users.RequestUsers{
+		UserId:         id,
+		Page:           1,
+		Limit:          1,
+		IncludeDeleted: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*result) == 0 {
+		return nil, users.ErrUserNotFound
+	}
+
+	return &(*result)[0], nil
+}
+
+func (u *UserUsecase) SuspendUser(actor policy.Actor, id uuid.UUID) error {
+	return u.manageUser(actor, id, u.user.SuspendUser, audit.ActionUserSuspended, true)
+}
+
+func (u *UserUsecase) UnsuspendUser(actor policy.Actor, id uuid.UUID) error {
+	return u.manageUser(actor, id, u.user.UnsuspendUser, audit.ActionUserUnsuspended, false)
+}
+
+func (u *UserUsecase) DeleteUser(actor policy.Actor, id uuid.UUID) error {
+	return u.manageUser(actor, id, u.user.DeleteUser, audit.ActionUserDeleted, true)
+}
+
+func (u *UserUsecase) RestoreUser(actor policy.Actor, id uuid.UUID) error {
+	return u.manageUser(actor, id, u.user.RestoreUser, audit.ActionUserRestored, false)
+}
+
+// ForceLogout ends every session of the user. Unlike the other actions an
+// admin may use it on their own account.
+func (u *UserUsecase) ForceLogout(actor policy.Actor, id uuid.UUID) error {
+	if err := policy.CanForceLogout(actor); err != nil {
+		return err
+	}
+
+	if err := u.user.AuditUser(id, newAuditEntry(actor, audit.ActionUserLoggedOut, id)); err != nil {
+		return err
+	}
+
+	return u.revoker.RevokeAll(id)
+}
+
+// manageUser runs an admin action on a user, the store audits it in the same
+// transaction. Actions that take the account away also sign the user out
+// everywhere.
+func (u *UserUsecase) manageUser(actor policy.Actor, id uuid.UUID, action func(id uuid.UUID, entry audit.Entry) error, auditAction string, signOut bool) error {
+	if err := policy.CanManageUser(actor, id); err != nil {
+		return err
+	}
+
+	if err := action(id, newAuditEntry(actor, auditAction, id)); err != nil {
+		return err
+	}
+
+	if !signOut {
+		return nil
+	}
+
+	return u.revoker.RevokeAll(id)
+}
+
+// Export collects the profile, sessions and order history of the user.
+func (u *UserUsecase) Export(userID uuid.UUID) (*users.Export, error) {
+	usr, err := u.user.GetUserDetails(users.Users{Id: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	userSessions, err := u.session.GetSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := u.orders.GetOrders(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bResp := users.Export{
+		Profile:    usr,
+		Sessions:   []sessions.Session{},
+		Orders:     orders,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	if userSessions != nil {
+		bResp.Sessions = *userSessions
+	}
+
+	return &bResp, nil
+}
+
+// RequestDeletion deletes the account of the user and signs them out. The
+// personal data is scrubbed by AnonymizeUsers once the grace period is over.
+func (u *UserUsecase) RequestDeletion(userID uuid.UUID) (*users.DeletionResponse, error) {
+	anonymizeAfter := time.Now().Add(deletionGracePeriod).UTC()
+	metadata, err := json.Marshal(map[string]string{
+		"anonymize_after": anonymizeAfter.Format(time.RFC3339),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if usrInfo.Email == bReq.Email && usrInfo.Username == bReq.Username {
-		return nil, errors.New("user already registered")
+	entry := newAuditEntry(policy.Actor{UserID: userID}, audit.ActionUserDeletionRequested, userID)
+	entry.Metadata = metadata
+	if err := u.user.ScheduleAnonymization(userID, anonymizeAfter, entry); err != nil {
+		return nil, err
+	}
+
+	if err := u.revoker.RevokeAll(userID); err != nil {
+		return nil, err
+	}
+
+	return &users.DeletionResponse{AnonymizeAfter: anonymizeAfter}, nil
+}
+
+// AnonymizeUsers scrubs the accounts whose deletion grace period is over, it
+// runs as a background job.
+func (u *UserUsecase) AnonymizeUsers() error {
+	affected, err := u.user.AnonymizeUsers(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		log.Printf("[USERS] anonymized %d deleted users", affected)
+	}
+
+	return nil
+}
+
+func newAuditEntry(actor policy.Actor, action string, userID uuid.UUID) audit.Entry {
+	return audit.Entry{
+		ActorId:    actor.UserID,
+		Action:     action,
+		TargetType: audit.TargetUser,
+		TargetId:   userID,
+	}
+}
+
+// checkActive refuses to sign in deleted and suspended accounts.
+func checkActive(usr *users.Users) error {
+	if usr.DeletedAt != nil {
+		return users.ErrUserNotFound
+	}
+
+	if usr.SuspendedAt != nil {
+		return users.ErrUserSuspended
+	}
+
+	return nil
+}
+
+func (u *UserUsecase) Register(bReq users.Users) (*uuid.UUID, error) {
+	bReq.Email = users.NormalizeEmail(bReq.Email)
+	if _, err := u.user.GetUserDetails(users.Users{Email: bReq.Email}); err == nil {
+		return nil, users.ErrUserAlreadyRegistered
+	} else if !errors.Is(err, users.ErrUserNotFound) {
+		return nil, err
+	}
+
+	// Accounts created through Google sign up have no password
+	if bReq.Password != "" {
+		hashedPassword, err := helper.HashPassword(bReq.Password)
+		if err != nil {
+			return nil, err
+		}
+		bReq.Password = hashedPassword
 	}
 
 	result, err := u.user.RegisterUser(bReq)
@@ -51,60 +357,558 @@ func (u *UserUsecase) Register(bReq users.Users) (*uuid.UUID, error) {
 		return nil, err
 	}
 
+	// The account exists at this point, a failed mail can be sent again
+	// through the resend endpoint
+	if bReq.EmailVerifiedAt == nil {
+		if err := u.sendVerification(*result, bReq.Email); err != nil {
+			log.Printf("[MAILER] failed to send verification mail to %s: %v", bReq.Email, err)
+		}
+	}
+
 	return result, nil
 }
 
-func (u *UserUsecase) Login(bReq users.Users) (*users.LoginResponse, error) {
-	usrLogin, err := u.user.GetUserDetails(bReq)
+// ResendVerification sends a new verification mail. It does not tell whether
+// the address is registered or already verified.
+func (u *UserUsecase) ResendVerification(email string) error {
+	usrInfo, err := u.user.GetUserDetails(users.Users{Email: email})
+	if err != nil {
+		if errors.Is(err, users.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if usrInfo.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return u.sendVerification(usrInfo.Id, usrInfo.Email)
+}
+
+func (u *UserUsecase) VerifyEmail(token string) error {
+	if _, err := u.user.VerifyEmail(helper.HashToken(token)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *UserUsecase) sendVerification(userID uuid.UUID, email string) error {
+	token, tokenHash, err := helper.GenerateToken()
 	if err != nil {
+		return err
+	}
+
+	if err := u.user.CreateEmailVerification(userID, tokenHash, time.Now().Add(emailVerificationExpiry)); err != nil {
+		return err
+	}
+
+	link := u.appURL + "/users/verify?token=" + url.QueryEscape(token)
+	body := "Please verify your email address by opening the link below. The link expires in 24 hours.\n\n" + link
+
+	return u.mailer.Send(email, "Verify your email address", body)
+}
+
+// ForgotPassword mails a password reset link. Like ResendVerification it does
+// not tell whether the address is registered.
+func (u *UserUsecase) ForgotPassword(email string) error {
+	usrInfo, err := u.user.GetUserDetails(users.Users{Email: email})
+	if err != nil {
+		if errors.Is(err, users.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, tokenHash, err := helper.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	if err := u.user.CreatePasswordReset(usrInfo.Id, tokenHash, time.Now().Add(passwordResetExpiry)); err != nil {
+		return err
+	}
+
+	// The reset itself is a POST with the new password, the link opens the
+	// frontend page with the form that sends it
+	link, err := url.Parse(u.passwordResetURL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	body := "A password reset was requested for your account. Open the link below to choose a new password, it expires in 1 hour.\n\n" +
+		link.String() + "\n\nIf you did not request this, you can ignore this mail."
+
+	return u.mailer.Send(usrInfo.Email, "Reset your password", body)
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out
+// of every session, since the old password may have been compromised.
+func (u *UserUsecase) ResetPassword(token string, password string) error {
+	hashedPassword, err := helper.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	userID, err := u.user.ResetPassword(helper.HashToken(token), hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	return u.revoker.RevokeAll(*userID)
+}
+
+// Login checks the password of the user. Users with two-factor authentication
+// get a challenge instead of the tokens, see VerifyMFA.
+func (u *UserUsecase) Login(bReq users.UsersLogin) (*users.LoginResponse, *mfa.ChallengeResponse, error) {
+	// The attempt is counted before the password is checked and only taken
+	// back once it matched
+	if err := u.attempt.Begin(bReq.Email, bReq.IpAddress); err != nil {
+		return nil, nil, err
+	}
+
+	usrLogin, err := u.user.GetUserDetails(users.Users{Email: bReq.Email})
+	if err != nil && !errors.Is(err, users.ErrUserNotFound) {
+		if err := u.attempt.Refund(bReq.Email, bReq.IpAddress); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, err
+	}
+
+	// CheckPassword still runs a bcrypt comparison for unknown emails and
+	// password-less (Google) accounts so the response time does not leak them
+	var hashedPassword string
+	if usrLogin != nil {
+		hashedPassword = usrLogin.Password
+	}
+
+	if err := helper.CheckPassword(hashedPassword, bReq.Password); err != nil {
+		if err := u.attempt.Fail(bReq.Email, bReq.IpAddress); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, nil, users.ErrInvalidCredentials
+	}
+
+	if err := u.attempt.Refund(bReq.Email, bReq.IpAddress); err != nil {
+		return nil, nil, err
+	}
+
+	if usrLogin.EmailVerifiedAt == nil {
+		return nil, nil, users.ErrEmailNotVerified
+	}
+
+	return u.SignIn(usrLogin, sessions.ClientInfo{
+		UserAgent: bReq.UserAgent,
+		IpAddress: bReq.IpAddress,
+	})
+}
+
+// SignIn finishes a login whose first factor was already checked, either by
+// issuing the tokens or by returning an MFA challenge.
+func (u *UserUsecase) SignIn(usr *users.Users, client sessions.ClientInfo) (*users.LoginResponse, *mfa.ChallengeResponse, error) {
+	if err := checkActive(usr); err != nil {
+		return nil, nil, err
+	}
+
+	usrMFA, err := u.mfa.GetMFA(usr.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The account counter is only reset once the tokens are issued, otherwise
+	// the password alone would reset it between guesses of the TOTP code
+	if usrMFA.EnabledAt == nil {
+		if err := u.attempt.RecordSuccess(usr.Email); err != nil {
+			return nil, nil, err
+		}
+
+		bResp, err := u.GenerateToken(usr, client)
+		return bResp, nil, err
+	}
+
+	mfaToken, payload, err := jwt.CreateMFAToken(usr.Email, usr.Id.String(), usr.Role, mfaTokenExpiry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, &mfa.ChallengeResponse{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresAt:   payload.ExpiresAt.Time,
+	}, nil
+}
+
+// VerifyMFA completes an MFA challenge with a TOTP or recovery code and starts
+// the session.
+func (u *UserUsecase) VerifyMFA(bReq mfa.VerifyRequest) (*users.LoginResponse, error) {
+	payload, err := jwt.VerifyMFAToken(bReq.MFAToken)
+	if err != nil {
+		return nil, mfa.ErrInvalidMFAToken
+	}
+
+	userID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		return nil, mfa.ErrInvalidMFAToken
+	}
+
+	jti, err := uuid.Parse(payload.ID)
+	if err != nil || payload.ExpiresAt == nil {
+		return nil, mfa.ErrInvalidMFAToken
+	}
+
+	// A challenge can be answered once, a wrong code needs a new login. A
+	// stolen or replayed token can not be used to guess further codes or
+	// to open another session.
+	first, err := u.token.UseToken(jti, userID, payload.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	if !first {
+		return nil, mfa.ErrInvalidMFAToken
+	}
+
+	usrMFA, err := u.mfa.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two-factor authentication was disabled since the challenge was issued
+	if usrMFA.EnabledAt == nil {
+		return nil, mfa.ErrInvalidMFAToken
+	}
+
+	if err := u.attempt.Begin(payload.Email, bReq.IpAddress); err != nil {
+		return nil, err
+	}
+
+	if err := u.checkMFACode(usrMFA, bReq.Code); err != nil {
+		settle := u.attempt.Refund
+		if errors.Is(err, mfa.ErrInvalidMFACode) {
+			settle = u.attempt.Fail
+		}
+
+		if err := settle(payload.Email, bReq.IpAddress); err != nil {
+			return nil, err
+		}
+
+		return nil, err
+	}
+
+	if err := u.attempt.Refund(payload.Email, bReq.IpAddress); err != nil {
 		return nil, err
 	}
 
-	if usrLogin.Email != bReq.Email {
-		return nil, errors.New("users not yet registered")
+	if err := u.attempt.RecordSuccess(payload.Email); err != nil {
+		return nil, err
 	}
 
-	tokenExpiry := time.Minute * 20
-	accessToken, payload, err := jwt.CreateAccessToken(usrLogin.Email, usrLogin.Id.String(), usrLogin.Role, tokenExpiry)
+	usr, err := u.user.GetUserDetails(users.Users{Id: userID})
 	if err != nil {
+		if errors.Is(err, users.ErrUserNotFound) {
+			return nil, mfa.ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	if err := checkActive(usr); err != nil {
 		return nil, err
 	}
 
-	refreshTokenExpiry := time.Hour * 72
-	refreshToken, refreshTokenPayload, err := jwt.CreateRefreshToken(usrLogin.Email, usrLogin.Id.String(), usrLogin.Role, refreshTokenExpiry)
+	return u.GenerateToken(usr, sessions.ClientInfo{
+		UserAgent: bReq.UserAgent,
+		IpAddress: bReq.IpAddress,
+	})
+}
+
+// SetupMFA starts the enrollment with a new secret and recovery codes. The
+// recovery codes are only shown here, just their hashes are stored.
+func (u *UserUsecase) SetupMFA(userID uuid.UUID) (*mfa.SetupResponse, error) {
+	usr, err := u.user.GetUserDetails(users.Users{Id: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
 	if err != nil {
 		return nil, err
 	}
 
+	recoveryCodes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	codeHashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		codeHashes[i] = helper.HashToken(code)
+	}
+
+	if err := u.mfa.SetupMFA(userID, secret, codeHashes); err != nil {
+		return nil, err
+	}
+
+	return &mfa.SetupResponse{
+		Secret:        secret,
+		OtpauthURI:    totp.URI(mfaIssuer, usr.Email, secret),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// ConfirmMFA enables two-factor authentication once the user shows a code of
+// the secret from SetupMFA.
+func (u *UserUsecase) ConfirmMFA(userID uuid.UUID, code string) error {
+	usrMFA, err := u.mfa.GetMFA(userID)
+	if err != nil {
+		return err
+	}
+
+	if usrMFA.EnabledAt != nil {
+		return mfa.ErrMFAAlreadyEnabled
+	}
+
+	if usrMFA.Secret == "" {
+		return mfa.ErrMFANotSetup
+	}
+
+	step, ok := totp.Validate(usrMFA.Secret, code, time.Now())
+	if !ok {
+		return mfa.ErrInvalidMFACode
+	}
+
+	return u.mfa.EnableMFA(userID, step)
+}
+
+// DisableMFA turns two-factor authentication off, it asks for a code so a
+// stolen access token alone cannot remove the second factor.
+func (u *UserUsecase) DisableMFA(userID uuid.UUID, code string) error {
+	usrMFA, err := u.mfa.GetMFA(userID)
+	if err != nil {
+		return err
+	}
+
+	if usrMFA.EnabledAt == nil {
+		return mfa.ErrMFANotEnabled
+	}
+
+	if err := u.checkMFACode(usrMFA, code); err != nil {
+		return err
+	}
+
+	return u.mfa.DeleteMFA(userID)
+}
+
+// checkMFACode accepts a TOTP code of a step that was not used yet, or an
+// unused recovery code.
+func (u *UserUsecase) checkMFACode(usrMFA *mfa.MFA, code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if step, ok := totp.Validate(usrMFA.Secret, code, time.Now()); ok {
+		return u.mfa.UseTOTPStep(usrMFA.UserId, step)
+	}
+
+	return u.mfa.UseRecoveryCode(usrMFA.UserId, helper.HashToken(code))
+}
+
+// GenerateToken starts a new session for the client and issues its first
+// access/refresh token pair.
+func (u *UserUsecase) GenerateToken(usr *users.Users, client sessions.ClientInfo) (*users.LoginResponse, error) {
+	sessionID := uuid.New()
+	if err := u.session.CreateSession(sessions.Session{
+		Id:        sessionID,
+		UserId:    usr.Id,
+		UserAgent: client.UserAgent,
+		IpAddress: client.IpAddress,
+	}); err != nil {
+		return nil, err
+	}
+
+	return u.issueTokens(usr, sessionID)
+}
+
+// RefreshToken rotates a refresh token. Every refresh token can be exchanged
+// once; presenting one that was already exchanged revokes its whole family,
+// since either the client or an attacker is holding a stolen copy.
+func (u *UserUsecase) RefreshToken(refreshToken string) (*users.LoginResponse, error) {
+	payload, err := jwt.VerifyRefreshToken(refreshToken)
+	if err != nil {
+		return nil, tokens.ErrInvalidRefreshToken
+	}
+
+	tokenID, err := uuid.Parse(payload.ID)
+	if err != nil {
+		return nil, tokens.ErrInvalidRefreshToken
+	}
+
+	stored, err := u.token.UseRefreshToken(tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, tokens.ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		if err := u.token.RevokeFamily(stored.FamilyId); err != nil {
+			return nil, err
+		}
+
+		return nil, tokens.ErrRefreshTokenReused
+	}
+
+	usr, err := u.user.GetUserDetails(users.Users{Id: stored.UserId})
+	if err != nil {
+		if errors.Is(err, users.ErrUserNotFound) {
+			return nil, tokens.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if checkActive(usr) != nil {
+		return nil, tokens.ErrInvalidRefreshToken
+	}
+
+	return u.issueTokens(usr, stored.FamilyId)
+}
+
+// Logout ends the session of the access token, revokes the refresh token
+// family issued with it and revokes the access token itself. The session id
+// doubles as the family id, so the family is revoked even when the session
+// was already gone.
+func (u *UserUsecase) Logout(payload *jwt.Payload) error {
+	userID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(payload.SessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.revoker.RevokeSession(userID, sessionID); err != nil {
+		if !errors.Is(err, sessions.ErrSessionNotFound) {
+			return err
+		}
+
+		if err := u.token.RevokeFamily(sessionID); err != nil {
+			return err
+		}
+	}
+
+	return u.revoker.Revoke(payload)
+}
+
+func (u *UserUsecase) LogoutAll(userID uuid.UUID) error {
+	return u.revoker.RevokeAll(userID)
+}
+
+// GetSessions lists the active sessions of the user, flagging the one the
+// request was made from.
+func (u *UserUsecase) GetSessions(userID uuid.UUID, currentSessionID string) (*[]sessions.Session, error) {
+	result, err := u.session.GetSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, session := range *result {
+		(*result)[i].Current = session.Id.String() == currentSessionID
+	}
+
+	return result, nil
+}
+
+func (u *UserUsecase) DeleteSession(userID uuid.UUID, sessionID uuid.UUID) error {
+	return u.revoker.RevokeSession(userID, sessionID)
+}
+
+// issueTokens signs a token pair for the session, the session id doubles as
+// the family id of the refresh token.
+func (u *UserUsecase) issueTokens(usr *users.Users, sessionID uuid.UUID) (*users.LoginResponse, error) {
+	accessToken, payload, err := jwt.CreateAccessToken(usr.Email, usr.Id.String(), usr.Role, sessionID.String(), accessTokenExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshTokenPayload, err := jwt.CreateRefreshToken(usr.Email, usr.Id.String(), usr.Role, sessionID.String(), refreshTokenExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenID, err := uuid.Parse(refreshTokenPayload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.token.CreateRefreshToken(tokens.RefreshToken{
+		Id:        refreshTokenID,
+		FamilyId:  sessionID,
+		UserId:    usr.Id,
+		ExpiresAt: refreshTokenPayload.ExpiresAt.Time,
+	}); err != nil {
+		return nil, err
+	}
+
 	bResp := users.LoginResponse{
 		AccessToken:          accessToken,
 		AccessTokenExpiresAt: payload.ExpiresAt.Time,
 		RefreshToken:         refreshToken,
 		RefreshTokenExpiryAt: refreshTokenPayload.ExpiresAt.Time,
-		Users:                usrLogin,
+		Users:                usr,
 	}
 
 	return &bResp, nil
 }
 
+// Get lists users either by page or, with a cursor, after the last user of
+// the previous page. NextCursor is only set for the default order, which is
+// the one cursors follow, and not for searches ranked by relevance.
 func (u *UserUsecase) Get(bReq users.RequestUsers) (*model.BaseModel, error) {
+	if bReq.Page < 1 {
+		bReq.Page = 1
+	}
+
+	if bReq.Limit < 1 {
+		bReq.Limit = users.DefaultLimit
+	}
+
+	if bReq.Limit > users.MaxLimit {
+		bReq.Limit = users.MaxLimit
+	}
+
 	result, totalData, err := u.user.GetUsers(bReq)
 	if err != nil {
 		return nil, err
 	}
 
-	filteredPage := int(math.Ceil(float64(totalData) / float64(bReq.Limit)))
+	totalPage := int(math.Ceil(float64(totalData) / float64(bReq.Limit)))
 	bResp := model.BaseModel{
 		Items:        result,
 		TotalItem:    totalData,
-		TotalPage:    filteredPage,
-		FilteredItem: bReq.Limit,
-		FilteredPage: filteredPage,
+		TotalPage:    totalPage,
+		FilteredItem: len(*result),
+		FilteredPage: bReq.Page,
+	}
+
+	if bReq.Cursor != "" {
+		bResp.FilteredPage = 0
 	}
 
 	if len(*result) == 0 {
 		bResp.Items = []string{}
 	}
 
+	if bReq.Sort == "" && !bReq.Ranked() && len(*result) == bReq.Limit {
+		last := (*result)[len(*result)-1]
+		if last.CreatedAt != nil {
+			bResp.NextCursor = query.Cursor{CreatedAt: *last.CreatedAt, Id: last.Id}.Encode()
+		}
+	}
+
 	return &bResp, nil
 }
@@ -0,0 +1,250 @@
+package users
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"user-service/src/util/helper"
+	"user-service/src/util/helper/jwt"
+	"user-service/src/util/repository/model/sessions"
+	"user-service/src/util/repository/model/users"
+	"user-service/src/util/repository/query"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUserRepository serves GetUsers from a fixed result and remembers the
+// last request, the other methods are not used by these tests.
+type fakeUserRepository struct {
+	userRepository
+	result    []users.Users
+	total     int
+	lastQuery users.RequestUsers
+}
+
+func (f *fakeUserRepository) GetUsers(bReq users.RequestUsers) (*[]users.Users, int, error) {
+	f.lastQuery = bReq
+	result := f.result
+	return &result, f.total, nil
+}
+
+func newUsers(n int) []users.Users {
+	result := make([]users.Users, n)
+	createdAt := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	for i := range result {
+		created := createdAt.Add(-time.Duration(i) * time.Minute)
+		result[i] = users.Users{Id: uuid.New(), CreatedAt: &created}
+	}
+
+	return result
+}
+
+func TestUserUsecase_Get(t *testing.T) {
+	t.Run("defaults page and limit", func(t *testing.T) {
+		repo := &fakeUserRepository{}
+		usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+
+		bResp, err := usecase.Get(users.RequestUsers{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, repo.lastQuery.Page)
+		assert.Equal(t, users.DefaultLimit, repo.lastQuery.Limit)
+		assert.Equal(t, []string{}, bResp.Items)
+		assert.Empty(t, bResp.NextCursor)
+	})
+
+	t.Run("caps the limit", func(t *testing.T) {
+		repo := &fakeUserRepository{}
+		usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+
+		_, err := usecase.Get(users.RequestUsers{Limit: 1000})
+		assert.NoError(t, err)
+		assert.Equal(t, users.MaxLimit, repo.lastQuery.Limit)
+	})
+
+	t.Run("totals cover every page", func(t *testing.T) {
+		repo := &fakeUserRepository{result: newUsers(3), total: 25}
+		usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+
+		bResp, err := usecase.Get(users.RequestUsers{Page: 3, Limit: 10, Sort: "email"})
+		assert.NoError(t, err)
+		assert.Equal(t, 25, bResp.TotalItem)
+		assert.Equal(t, 3, bResp.TotalPage)
+		assert.Equal(t, 3, bResp.FilteredItem)
+		assert.Equal(t, 3, bResp.FilteredPage)
+		assert.Empty(t, bResp.NextCursor)
+	})
+
+	t.Run("full page in default order has a next cursor", func(t *testing.T) {
+		result := newUsers(2)
+		repo := &fakeUserRepository{result: result, total: 5}
+		usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+
+		bResp, err := usecase.Get(users.RequestUsers{Limit: 2})
+		assert.NoError(t, err)
+
+		cursor, err := query.DecodeCursor(bResp.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, result[1].Id, cursor.Id)
+		assert.True(t, result[1].CreatedAt.Equal(cursor.CreatedAt))
+	})
+
+	t.Run("custom order has no next cursor", func(t *testing.T) {
+		repo := &fakeUserRepository{result: newUsers(2), total: 5}
+		usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+
+		bResp, err := usecase.Get(users.RequestUsers{Limit: 2, Sort: "-email"})
+		assert.NoError(t, err)
+		assert.Empty(t, bResp.NextCursor)
+	})
+
+	t.Run("ranked search has no next cursor", func(t *testing.T) {
+		repo := &fakeUserRepository{result: newUsers(2), total: 5}
+		usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+
+		bResp, err := usecase.Get(users.RequestUsers{Limit: 2, Search: "ann"})
+		assert.NoError(t, err)
+		assert.Empty(t, bResp.NextCursor)
+	})
+}
+
+// fakeRevoker ends sessions from a fixed result and remembers what it
+// revoked.
+type fakeRevoker struct {
+	tokenRevoker
+	sessionErr error
+	revoked    []*jwt.Payload
+}
+
+func (f *fakeRevoker) RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error {
+	return f.sessionErr
+}
+
+func (f *fakeRevoker) Revoke(payload *jwt.Payload) error {
+	f.revoked = append(f.revoked, payload)
+	return nil
+}
+
+// fakeTokenRepository remembers the refresh token families it revoked.
+type fakeTokenRepository struct {
+	tokenRepository
+	families []uuid.UUID
+}
+
+func (f *fakeTokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	f.families = append(f.families, familyID)
+	return nil
+}
+
+func TestUserUsecase_Logout(t *testing.T) {
+	payload := &jwt.Payload{UserID: uuid.NewString(), SessionID: uuid.NewString()}
+
+	t.Run("ends the session and revokes the access token", func(t *testing.T) {
+		revoker := &fakeRevoker{}
+		token := &fakeTokenRepository{}
+		u := &UserUsecase{revoker: revoker, token: token}
+
+		assert.NoError(t, u.Logout(payload))
+		assert.Equal(t, []*jwt.Payload{payload}, revoker.revoked)
+		assert.Empty(t, token.families)
+	})
+
+	t.Run("revokes the refresh token family of a session already gone", func(t *testing.T) {
+		revoker := &fakeRevoker{sessionErr: sessions.ErrSessionNotFound}
+		token := &fakeTokenRepository{}
+		u := &UserUsecase{revoker: revoker, token: token}
+
+		assert.NoError(t, u.Logout(payload))
+		assert.Equal(t, []uuid.UUID{uuid.MustParse(payload.SessionID)}, token.families)
+		assert.Equal(t, []*jwt.Payload{payload}, revoker.revoked)
+	})
+}
+
+// fakeResetRepository finds a single user and keeps the reset token hash it
+// was given.
+type fakeResetRepository struct {
+	userRepository
+	user      users.Users
+	tokenHash string
+}
+
+func (f *fakeResetRepository) GetUserDetails(bReq users.Users) (*users.Users, error) {
+	if bReq.Email != f.user.Email {
+		return nil, users.ErrUserNotFound
+	}
+
+	user := f.user
+	return &user, nil
+}
+
+func (f *fakeResetRepository) CreatePasswordReset(userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	f.tokenHash = tokenHash
+	return nil
+}
+
+type fakeMailer struct {
+	to   string
+	body string
+}
+
+func (f *fakeMailer) Send(to string, subject string, body string) error {
+	f.to = to
+	f.body = body
+	return nil
+}
+
+func TestUserUsecase_ForgotPassword(t *testing.T) {
+	repo := &fakeResetRepository{user: users.Users{Id: uuid.New(), Email: "a@example.com"}}
+	mailer := &fakeMailer{}
+	usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, mailer, "https://api.example.com", "https://shop.example.com/reset-password?lang=en")
+
+	assert.NoError(t, usecase.ForgotPassword("a@example.com"))
+	assert.Equal(t, "a@example.com", mailer.to)
+
+	var link *url.URL
+	for _, field := range strings.Fields(mailer.body) {
+		if strings.HasPrefix(field, "https://") {
+			parsed, err := url.Parse(field)
+			assert.NoError(t, err)
+			link = parsed
+		}
+	}
+
+	if assert.NotNil(t, link, "the mail contains a link") {
+		assert.Equal(t, "shop.example.com", link.Host)
+		assert.Equal(t, "/reset-password", link.Path)
+		assert.Equal(t, "en", link.Query().Get("lang"))
+		assert.Equal(t, repo.tokenHash, helper.HashToken(link.Query().Get("token")))
+	}
+}
+
+// fakeRegisterRepository has no users and keeps the one registered.
+type fakeRegisterRepository struct {
+	userRepository
+	lookup     string
+	registered users.Users
+}
+
+func (f *fakeRegisterRepository) GetUserDetails(bReq users.Users) (*users.Users, error) {
+	f.lookup = bReq.Email
+	return nil, users.ErrUserNotFound
+}
+
+func (f *fakeRegisterRepository) RegisterUser(bReq users.Users) (*uuid.UUID, error) {
+	f.registered = bReq
+	id := uuid.New()
+	return &id, nil
+}
+
+func TestUserUsecase_Register_NormalizesEmail(t *testing.T) {
+	repo := &fakeRegisterRepository{}
+	usecase := NewUserUsecase(repo, nil, nil, nil, nil, nil, nil, nil, "", "")
+	verifiedAt := time.Now()
+
+	_, err := usecase.Register(users.Users{Email: " Foo@Example.com ", EmailVerifiedAt: &verifiedAt})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "foo@example.com", repo.lookup)
+	assert.Equal(t, "foo@example.com", repo.registered.Email)
+}
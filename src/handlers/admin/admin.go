@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"net/http"
+	"user-service/src/util/helper"
+	"user-service/src/util/policy"
+	"user-service/src/util/repository/model"
+	"user-service/src/util/repository/model/attempts"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/model/users"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/thedevsaddam/renderer"
+)
+
+type attemptDto interface {
+	GetLockouts() (*[]attempts.Attempt, error)
+	ClearLockout(scope string, identifier string) error
+}
+
+type userDto interface {
+	GetUser(id uuid.UUID) (*users.Users, error)
+	ChangeRole(actor policy.Actor, id uuid.UUID, role string) error
+	SuspendUser(actor policy.Actor, id uuid.UUID) error
+	UnsuspendUser(actor policy.Actor, id uuid.UUID) error
+	DeleteUser(actor policy.Actor, id uuid.UUID) error
+	RestoreUser(actor policy.Actor, id uuid.UUID) error
+	ForceLogout(actor policy.Actor, id uuid.UUID) error
+}
+
+type checkoutDto interface {
+	GetSagas(bReq checkout.RequestSagas) (*model.BaseModel, error)
+	GetSaga(id uuid.UUID) (*checkout.Saga, error)
+	ResumeFailed(id uuid.UUID) (*checkout.Saga, error)
+}
+
+type Handler struct {
+	render    *renderer.Render
+	validator *validator.Validate
+	attempts  attemptDto
+	users     userDto
+	checkouts checkoutDto
+}
+
+func NewHandler(render *renderer.Render, validator *validator.Validate, attempts attemptDto, users userDto, checkouts checkoutDto) *Handler {
+	return &Handler{
+		render:    render,
+		validator: validator,
+		attempts:  attempts,
+		users:     users,
+		checkouts: checkouts,
+	}
+}
+
+func (h *Handler) GetLockouts(w http.ResponseWriter, r *http.Request) {
+	bResp, err := h.attempts.GetLockouts()
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	param := mux.Vars(r)
+	if err := h.attempts.ClearLockout(param["scope"], param["identifier"]); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
@@ -0,0 +1,280 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-service/src/util/helper"
+	"user-service/src/util/middleware"
+	"user-service/src/util/policy"
+	"user-service/src/util/repository/model"
+	attempts "user-service/src/util/repository/model/attempts"
+	checkout "user-service/src/util/repository/model/checkout"
+	users "user-service/src/util/repository/model/users"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/thedevsaddam/renderer"
+)
+
+func newAdminRequest(t *testing.T, method string, target string, body interface{}, admin policy.Actor, vars map[string]string) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+
+	req, err := http.NewRequest(method, target, &buf)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	req = mux.SetURLVars(req, vars)
+	ctx := middleware.SetUserID(req.Context(), admin.UserID.String())
+	ctx = middleware.SetRole(ctx, admin.Role)
+	return req.WithContext(ctx)
+}
+
+func TestHandler_ClearLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAttemptDto := NewMockattemptDto(ctrl)
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewHandler(renderer.New(), validate, mockAttemptDto, NewMockuserDto(ctrl), NewMockcheckoutDto(ctrl))
+
+	admin := policy.Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}
+
+	t.Run("successful clear", func(t *testing.T) {
+		mockAttemptDto.EXPECT().ClearLockout(attempts.ScopeAccount, "user@example.com").Return(nil)
+
+		req := newAdminRequest(t, "DELETE", "/admin/lockouts/account/user@example.com", nil, admin,
+			map[string]string{"scope": attempts.ScopeAccount, "identifier": "user@example.com"})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ClearLockout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid scope", func(t *testing.T) {
+		mockAttemptDto.EXPECT().ClearLockout("device", "x").Return(attempts.ErrInvalidScope)
+
+		req := newAdminRequest(t, "DELETE", "/admin/lockouts/device/x", nil, admin,
+			map[string]string{"scope": "device", "identifier": "x"})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ClearLockout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_ChangeUserRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewHandler(renderer.New(), validate, NewMockattemptDto(ctrl), mockUserDto, NewMockcheckoutDto(ctrl))
+
+	admin := policy.Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}
+	usrId := uuid.New()
+	vars := map[string]string{"user_id": usrId.String()}
+
+	t.Run("successful change", func(t *testing.T) {
+		mockUserDto.EXPECT().ChangeRole(admin, usrId, middleware.RoleSeller).Return(nil)
+
+		req := newAdminRequest(t, "PUT", "/admin/users/"+usrId.String()+"/role",
+			users.ChangeRoleRequest{Role: middleware.RoleSeller}, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ChangeUserRole).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		req := newAdminRequest(t, "PUT", "/admin/users/"+usrId.String()+"/role",
+			users.ChangeRoleRequest{Role: "Owner"}, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ChangeUserRole).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockUserDto.EXPECT().ChangeRole(admin, usrId, middleware.RoleUser).Return(users.ErrUserNotFound)
+
+		req := newAdminRequest(t, "PUT", "/admin/users/"+usrId.String()+"/role",
+			users.ChangeRoleRequest{Role: middleware.RoleUser}, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ChangeUserRole).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestHandler_UserActions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewHandler(renderer.New(), validate, NewMockattemptDto(ctrl), mockUserDto, NewMockcheckoutDto(ctrl))
+
+	admin := policy.Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}
+	usrId := uuid.New()
+	vars := map[string]string{"user_id": usrId.String()}
+
+	t.Run("get user", func(t *testing.T) {
+		mockUserDto.EXPECT().GetUser(usrId).Return(&users.Users{Id: usrId, Email: "user@example.com"}, nil)
+
+		req := newAdminRequest(t, "GET", "/admin/users/"+usrId.String(), nil, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.GetUser).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "user@example.com")
+	})
+
+	t.Run("suspend user", func(t *testing.T) {
+		mockUserDto.EXPECT().SuspendUser(admin, usrId).Return(nil)
+
+		req := newAdminRequest(t, "POST", "/admin/users/"+usrId.String()+"/suspend", nil, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.SuspendUser).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("delete own account", func(t *testing.T) {
+		selfVars := map[string]string{"user_id": admin.UserID.String()}
+		mockUserDto.EXPECT().DeleteUser(admin, admin.UserID).Return(policy.ErrSelfAction)
+
+		req := newAdminRequest(t, "DELETE", "/admin/users/"+admin.UserID.String(), nil, admin, selfVars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.DeleteUser).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("force logout of unknown user", func(t *testing.T) {
+		mockUserDto.EXPECT().ForceLogout(admin, usrId).Return(users.ErrUserNotFound)
+
+		req := newAdminRequest(t, "POST", "/admin/users/"+usrId.String()+"/logout", nil, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ForceLogout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("invalid user id", func(t *testing.T) {
+		req := newAdminRequest(t, "POST", "/admin/users/invalid-uuid/restore", nil, admin,
+			map[string]string{"user_id": "invalid-uuid"})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.RestoreUser).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_Checkouts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCheckoutDto := NewMockcheckoutDto(ctrl)
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewHandler(renderer.New(), validate, NewMockattemptDto(ctrl), NewMockuserDto(ctrl), mockCheckoutDto)
+
+	admin := policy.Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}
+	sagaId := uuid.New()
+	vars := map[string]string{"checkout_id": sagaId.String()}
+
+	t.Run("list failed checkouts", func(t *testing.T) {
+		mockCheckoutDto.EXPECT().GetSagas(checkout.RequestSagas{Status: checkout.StatusFailed, Page: 2}).
+			Return(&model.BaseModel{Items: []checkout.Saga{}}, nil)
+
+		req := newAdminRequest(t, "GET", "/admin/checkouts?status=failed&page=2", nil, admin, nil)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.GetCheckouts).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		mockCheckoutDto.EXPECT().GetSagas(checkout.RequestSagas{Status: "done"}).Return(nil, checkout.ErrInvalidStatus)
+
+		req := newAdminRequest(t, "GET", "/admin/checkouts?status=done", nil, admin, nil)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.GetCheckouts).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("get checkout with its steps", func(t *testing.T) {
+		mockCheckoutDto.EXPECT().GetSaga(sagaId).Return(&checkout.Saga{
+			Id:     sagaId,
+			Status: checkout.StatusCompensated,
+			Step:   checkout.StepCancelOrder,
+			Steps:  []checkout.StepLog{{Step: checkout.StepCancelOrder, Status: checkout.StepSucceeded}},
+		}, nil)
+
+		req := newAdminRequest(t, "GET", "/admin/checkouts/"+sagaId.String(), nil, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.GetCheckout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"status":"compensated"`)
+		assert.Contains(t, rr.Body.String(), `"step":"cancel_order"`)
+	})
+
+	t.Run("unknown checkout", func(t *testing.T) {
+		mockCheckoutDto.EXPECT().GetSaga(sagaId).Return(nil, checkout.ErrSagaNotFound)
+
+		req := newAdminRequest(t, "GET", "/admin/checkouts/"+sagaId.String(), nil, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.GetCheckout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("resume a checkout that did not fail", func(t *testing.T) {
+		mockCheckoutDto.EXPECT().ResumeFailed(sagaId).Return(nil, checkout.ErrNotResumable)
+
+		req := newAdminRequest(t, "POST", "/admin/checkouts/"+sagaId.String()+"/resume", nil, admin, vars)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.ResumeCheckout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("invalid checkout id", func(t *testing.T) {
+		req := newAdminRequest(t, "GET", "/admin/checkouts/invalid-uuid", nil, admin,
+			map[string]string{"checkout_id": "invalid-uuid"})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(h.GetCheckout).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"user-service/src/util/apperr"
+	"user-service/src/util/helper"
+	"user-service/src/util/repository/model/checkout"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) GetCheckouts(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Query()
+
+	page, err := helper.ParsePositiveInt(param.Get("page"))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("page"))
+		return
+	}
+
+	limit, err := helper.ParsePositiveInt(param.Get("limit"))
+	if err != nil || limit > checkout.MaxLimit {
+		helper.HandleError(w, h.render, apperr.Validation("invalid_limit", "Invalid limit, it must be between 1 and "+strconv.Itoa(checkout.MaxLimit)))
+		return
+	}
+
+	bResp, err := h.checkouts.GetSagas(checkout.RequestSagas{
+		Status: param.Get("status"),
+		Page:   page,
+		Limit:  limit,
+	})
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) GetCheckout(w http.ResponseWriter, r *http.Request) {
+	sagaId, err := uuid.Parse(mux.Vars(r)["checkout_id"])
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("checkout_id"))
+		return
+	}
+
+	bResp, err := h.checkouts.GetSaga(sagaId)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) ResumeCheckout(w http.ResponseWriter, r *http.Request) {
+	sagaId, err := uuid.Parse(mux.Vars(r)["checkout_id"])
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("checkout_id"))
+		return
+	}
+
+	bResp, err := h.checkouts.ResumeFailed(sagaId)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
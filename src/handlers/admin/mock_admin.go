@@ -0,0 +1,259 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admin.go
+
+// Package admin is a generated GoMock package.
+package admin
+
+import (
+	reflect "reflect"
+	policy "user-service/src/util/policy"
+	model "user-service/src/util/repository/model"
+	attempts "user-service/src/util/repository/model/attempts"
+	checkout "user-service/src/util/repository/model/checkout"
+	users "user-service/src/util/repository/model/users"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+)
+
+// MockattemptDto is a mock of attemptDto interface.
+type MockattemptDto struct {
+	ctrl     *gomock.Controller
+	recorder *MockattemptDtoMockRecorder
+}
+
+// MockattemptDtoMockRecorder is the mock recorder for MockattemptDto.
+type MockattemptDtoMockRecorder struct {
+	mock *MockattemptDto
+}
+
+// NewMockattemptDto creates a new mock instance.
+func NewMockattemptDto(ctrl *gomock.Controller) *MockattemptDto {
+	mock := &MockattemptDto{ctrl: ctrl}
+	mock.recorder = &MockattemptDtoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockattemptDto) EXPECT() *MockattemptDtoMockRecorder {
+	return m.recorder
+}
+
+// ClearLockout mocks base method.
+func (m *MockattemptDto) ClearLockout(scope, identifier string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearLockout", scope, identifier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearLockout indicates an expected call of ClearLockout.
+func (mr *MockattemptDtoMockRecorder) ClearLockout(scope, identifier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearLockout", reflect.TypeOf((*MockattemptDto)(nil).ClearLockout), scope, identifier)
+}
+
+// GetLockouts mocks base method.
+func (m *MockattemptDto) GetLockouts() (*[]attempts.Attempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLockouts")
+	ret0, _ := ret[0].(*[]attempts.Attempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLockouts indicates an expected call of GetLockouts.
+func (mr *MockattemptDtoMockRecorder) GetLockouts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLockouts", reflect.TypeOf((*MockattemptDto)(nil).GetLockouts))
+}
+
+// MockuserDto is a mock of userDto interface.
+type MockuserDto struct {
+	ctrl     *gomock.Controller
+	recorder *MockuserDtoMockRecorder
+}
+
+// MockuserDtoMockRecorder is the mock recorder for MockuserDto.
+type MockuserDtoMockRecorder struct {
+	mock *MockuserDto
+}
+
+// NewMockuserDto creates a new mock instance.
+func NewMockuserDto(ctrl *gomock.Controller) *MockuserDto {
+	mock := &MockuserDto{ctrl: ctrl}
+	mock.recorder = &MockuserDtoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockuserDto) EXPECT() *MockuserDtoMockRecorder {
+	return m.recorder
+}
+
+// ChangeRole mocks base method.
+func (m *MockuserDto) ChangeRole(actor policy.Actor, id uuid.UUID, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeRole", actor, id, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangeRole indicates an expected call of ChangeRole.
+func (mr *MockuserDtoMockRecorder) ChangeRole(actor, id, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeRole", reflect.TypeOf((*MockuserDto)(nil).ChangeRole), actor, id, role)
+}
+
+// DeleteUser mocks base method.
+func (m *MockuserDto) DeleteUser(actor policy.Actor, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", actor, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockuserDtoMockRecorder) DeleteUser(actor, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockuserDto)(nil).DeleteUser), actor, id)
+}
+
+// ForceLogout mocks base method.
+func (m *MockuserDto) ForceLogout(actor policy.Actor, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceLogout", actor, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceLogout indicates an expected call of ForceLogout.
+func (mr *MockuserDtoMockRecorder) ForceLogout(actor, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceLogout", reflect.TypeOf((*MockuserDto)(nil).ForceLogout), actor, id)
+}
+
+// GetUser mocks base method.
+func (m *MockuserDto) GetUser(id uuid.UUID) (*users.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", id)
+	ret0, _ := ret[0].(*users.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockuserDtoMockRecorder) GetUser(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockuserDto)(nil).GetUser), id)
+}
+
+// RestoreUser mocks base method.
+func (m *MockuserDto) RestoreUser(actor policy.Actor, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", actor, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockuserDtoMockRecorder) RestoreUser(actor, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockuserDto)(nil).RestoreUser), actor, id)
+}
+
+// SuspendUser mocks base method.
+func (m *MockuserDto) SuspendUser(actor policy.Actor, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SuspendUser", actor, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SuspendUser indicates an expected call of SuspendUser.
+func (mr *MockuserDtoMockRecorder) SuspendUser(actor, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SuspendUser", reflect.TypeOf((*MockuserDto)(nil).SuspendUser), actor, id)
+}
+
+// UnsuspendUser mocks base method.
+func (m *MockuserDto) UnsuspendUser(actor policy.Actor, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnsuspendUser", actor, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnsuspendUser indicates an expected call of UnsuspendUser.
+func (mr *MockuserDtoMockRecorder) UnsuspendUser(actor, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsuspendUser", reflect.TypeOf((*MockuserDto)(nil).UnsuspendUser), actor, id)
+}
+
+// MockcheckoutDto is a mock of checkoutDto interface.
+type MockcheckoutDto struct {
+	ctrl     *gomock.Controller
+	recorder *MockcheckoutDtoMockRecorder
+}
+
+// MockcheckoutDtoMockRecorder is the mock recorder for MockcheckoutDto.
+type MockcheckoutDtoMockRecorder struct {
+	mock *MockcheckoutDto
+}
+
+// NewMockcheckoutDto creates a new mock instance.
+func NewMockcheckoutDto(ctrl *gomock.Controller) *MockcheckoutDto {
+	mock := &MockcheckoutDto{ctrl: ctrl}
+	mock.recorder = &MockcheckoutDtoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockcheckoutDto) EXPECT() *MockcheckoutDtoMockRecorder {
+	return m.recorder
+}
+
+// GetSaga mocks base method.
+func (m *MockcheckoutDto) GetSaga(id uuid.UUID) (*checkout.Saga, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSaga", id)
+	ret0, _ := ret[0].(*checkout.Saga)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSaga indicates an expected call of GetSaga.
+func (mr *MockcheckoutDtoMockRecorder) GetSaga(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSaga", reflect.TypeOf((*MockcheckoutDto)(nil).GetSaga), id)
+}
+
+// GetSagas mocks base method.
+func (m *MockcheckoutDto) GetSagas(bReq checkout.RequestSagas) (*model.BaseModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSagas", bReq)
+	ret0, _ := ret[0].(*model.BaseModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSagas indicates an expected call of GetSagas.
+func (mr *MockcheckoutDtoMockRecorder) GetSagas(bReq interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSagas", reflect.TypeOf((*MockcheckoutDto)(nil).GetSagas), bReq)
+}
+
+// ResumeFailed mocks base method.
+func (m *MockcheckoutDto) ResumeFailed(id uuid.UUID) (*checkout.Saga, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeFailed", id)
+	ret0, _ := ret[0].(*checkout.Saga)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeFailed indicates an expected call of ResumeFailed.
+func (mr *MockcheckoutDtoMockRecorder) ResumeFailed(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeFailed", reflect.TypeOf((*MockcheckoutDto)(nil).ResumeFailed), id)
+}
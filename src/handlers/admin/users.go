@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"net/http"
+	"user-service/src/util/helper"
+	"user-service/src/util/policy"
+	"user-service/src/util/repository/model/users"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("user_id"))
+		return
+	}
+
+	bResp, err := h.users.GetUser(usrId)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) ChangeUserRole(w http.ResponseWriter, r *http.Request) {
+	actor, usrId, ok := h.parseUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	var bReq users.ChangeRoleRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if err := h.users.ChangeRole(actor, usrId, bReq.Role); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.users.SuspendUser)
+}
+
+func (h *Handler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.users.UnsuspendUser)
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.users.DeleteUser)
+}
+
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.users.RestoreUser)
+}
+
+func (h *Handler) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.users.ForceLogout)
+}
+
+// userAction runs an admin action without request body on the user of the
+// path.
+func (h *Handler) userAction(w http.ResponseWriter, r *http.Request, action func(actor policy.Actor, id uuid.UUID) error) {
+	actor, usrId, ok := h.parseUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	if err := action(actor, usrId); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) parseUserAction(w http.ResponseWriter, r *http.Request) (policy.Actor, uuid.UUID, bool) {
+	actor, err := policy.ActorFromContext(r.Context())
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return policy.Actor{}, uuid.Nil, false
+	}
+
+	usrId, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("user_id"))
+		return policy.Actor{}, uuid.Nil, false
+	}
+
+	return actor, usrId, true
+}
@@ -8,12 +8,14 @@ import (
 	"user-service/src/util/middleware"
 	"user-service/src/util/repository/model/cart"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/thedevsaddam/renderer"
 )
 
 type Handler struct {
-	render *renderer.Render
+	render    *renderer.Render
+	validator *validator.Validate
 }
 
 const (
@@ -23,23 +25,26 @@ const (
 	deleteCartUrl = "http://localhost:9993/cart/delete/"
 )
 
-func NewHandler(r *renderer.Render) *Handler {
-	return &Handler{render: r}
+func NewHandler(r *renderer.Render, validator *validator.Validate) *Handler {
+	return &Handler{render: r, validator: validator}
 }
 
 func (h *Handler) GetCartByUserID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	usrId := middleware.GetUserID(ctx)
 
+	var bReq cart.GetCartRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
 	cartChannel := make(chan client.Response)
 	netClient := client.NetClientRequest{
 		NetClient:  client.NetClient,
 		RequestUrl: getCartUrl + usrId,
 	}
 
-	var bReq cart.GetCartRequest
-	json.NewDecoder(r.Body).Decode(&bReq)
-
 	go netClient.Get(bReq, cartChannel)
 	bResp := <-cartChannel
 	if bResp.Err != nil {
@@ -49,7 +54,7 @@ func (h *Handler) GetCartByUserID(w http.ResponseWriter, r *http.Request) {
 
 	var response []cart.Cart
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -61,7 +66,11 @@ func (h *Handler) UpdateCart(w http.ResponseWriter, r *http.Request) {
 	usrId := middleware.GetUserID(ctx)
 
 	var bReq cart.Cart
-	json.NewDecoder(r.Body).Decode(&bReq)
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
 	cartChannel := make(chan client.Response)
 	url := updateCartUrl + usrId
 
@@ -74,7 +83,7 @@ func (h *Handler) UpdateCart(w http.ResponseWriter, r *http.Request) {
 
 	var response string
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -87,17 +96,12 @@ func (h *Handler) AddCart(w http.ResponseWriter, r *http.Request) {
 	uid := uuid.MustParse(usrId)
 
 	var bReq cart.Cart
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, err.Error(), nil)
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 	bReq.UserID = uid
 
-	if bReq.Qty == 0 {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "Qty must be greater than 0", nil)
-		return
-	}
-
 	cartChannel := make(chan client.Response)
 	netClient := client.NetClientRequest{
 		NetClient:  client.NetClient,
@@ -113,7 +117,7 @@ func (h *Handler) AddCart(w http.ResponseWriter, r *http.Request) {
 
 	var response *uuid.UUID
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -125,8 +129,8 @@ func (h *Handler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 	usrId := middleware.GetUserID(ctx)
 
 	var bReq cart.DeleteCartRequest
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, err.Error(), nil)
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -145,7 +149,7 @@ func (h *Handler) DeleteCart(w http.ResponseWriter, r *http.Request) {
 
 	var response string
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -0,0 +1,42 @@
+package order
+
+import (
+	"bytes"
+	"net/http"
+	"user-service/src/util/repository/model/idempotency"
+)
+
+// responseRecorder holds the response back until it is stored for replays,
+// the client only gets a response the key can answer again. Headers go to
+// the client's writer right away.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// flush sends the recorded response to the client.
+func (r *responseRecorder) flush() {
+	r.ResponseWriter.WriteHeader(r.statusCode)
+	r.ResponseWriter.Write(r.body.Bytes())
+}
+
+// replayResponse writes the stored response of an earlier request again.
+func replayResponse(w http.ResponseWriter, record *idempotency.Record) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(*record.StatusCode)
+	w.Write(record.Response)
+}
@@ -1,19 +1,19 @@
 package order
 
 import (
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 	"user-service/src/util/client"
 	"user-service/src/util/helper"
 	"user-service/src/util/middleware"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/model/idempotency"
 	"user-service/src/util/repository/model/order"
 	"user-service/src/util/repository/model/payment"
-	"user-service/src/util/repository/model/products"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -21,36 +21,42 @@ import (
 	"github.com/thedevsaddam/renderer"
 )
 
-var (
-	responseError = map[string]interface{}{}
-)
+type checkoutDto interface {
+	Checkout(id uuid.UUID, bReq order.CreateOrderRequest) (*checkout.Saga, error)
+	GetSaga(id uuid.UUID) (*checkout.Saga, error)
+}
+
+type idempotencyDto interface {
+	Begin(userID uuid.UUID, key string, request []byte) (*idempotency.Record, error)
+	SetSaga(userID uuid.UUID, key string, sagaID uuid.UUID) error
+	Complete(userID uuid.UUID, key string, statusCode int, response []byte) error
+	Release(userID uuid.UUID, key string) error
+}
+
+type reservationDto interface {
+	Confirm(orderID string) error
+	Release(orderID string) error
+}
 
 type Handler struct {
-	render    *renderer.Render
-	validator *validator.Validate
-	mutex     *sync.Mutex
-	clientKey string
-	serverKey string
+	render       *renderer.Render
+	validator    *validator.Validate
+	checkout     checkoutDto
+	idempotency  idempotencyDto
+	reservations reservationDto
+	serverKey    string
 }
 
 const (
 	// Service Order
-	createOrderUrl   = "https://8c5c-182-253-51-145.ngrok-free.app/order/create"
 	callbackUrl      = "https://8c5c-182-253-51-145.ngrok-free.app/order/callback"
 	checkStatusUrl   = "https://8c5c-182-253-51-145.ngrok-free.app/order/status/"
 	updateStatusUrl  = "https://localhost:9993/order/status/update"
 	updateShppingUrl = "https://localhost:9993/order/shipping/update"
-
-	// Service Product
-	getproductUrl    = "https://362c-182-253-51-145.ngrok-free.app/api/products"
-	updateProductUrl = "https://362c-182-253-51-145.ngrok-free.app/api/product-stocks"
-
-	// Service Payment
-	paymentUrl = "https://51e6-182-253-51-145.ngrok-free.app/api/payments"
 )
 
-func NewHandler(r *renderer.Render, validator *validator.Validate, mutex *sync.Mutex, clientKey, serverKey string) *Handler {
-	return &Handler{render: r, validator: validator, mutex: mutex, clientKey: clientKey, serverKey: serverKey}
+func NewHandler(r *renderer.Render, validator *validator.Validate, checkout checkoutDto, idempotency idempotencyDto, reservations reservationDto, serverKey string) *Handler {
+	return &Handler{render: r, validator: validator, checkout: checkout, idempotency: idempotency, reservations: reservations, serverKey: serverKey}
 }
 
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
@@ -65,179 +71,200 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	param := r.URL.Query()
 	bReq.Limit = param.Get("limit")
 
-	// Decode from body request to struct
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, err.Error(), nil)
+	// Keep the raw body, a retry is recognized by it
+	body, err := helper.ReadBody(r)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
-	bReq.UserID = uid
-
-	// Channel for each request
-	getProductChannel := make(chan client.Response)
-	createOrderChannel := make(chan client.Response)
-	updateStockChannel := make(chan client.Response)
-	paymentChannel := make(chan client.Response)
 
-	// Get data product from product service
-	var productIDs []string
-	for _, product := range bReq.ProductOrder {
-		productIDs = append(productIDs, product.ProductID)
+	// Decode from body request to struct
+	if err := helper.DecodeJSON(r, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
 	}
-	productId := strings.Join(productIDs, ",")
-
-	// Lock the mutex before accessing the critical section
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	bReq.UserID = uid
 
-	netClientGetProducts := client.NetClientRequest{
-		NetClient:  client.NetClient,
-		RequestUrl: getproductUrl,
-		QueryParam: []client.QueryParams{
-			{Param: "product_ids", Value: productId},
-			{Param: "limit", Value: bReq.Limit},
-		},
+	if err := helper.Validate(h.validator, bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
 	}
 
-	netClientGetProducts.Get(nil, getProductChannel)
-	responseGetProducts := <-getProductChannel
-	if responseGetProducts.Err != nil || responseGetProducts.StatusCode != http.StatusOK {
-		if err := json.Unmarshal(responseGetProducts.Res, &responseError); err != nil {
-			helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
-			return
-		}
-
-		helper.HandleResponse(w, h.render, responseGetProducts.StatusCode, responseError, nil)
+	key := r.Header.Get(idempotency.Header)
+	if key == "" {
+		h.createOrder(w, uuid.New(), bReq)
 		return
 	}
 
-	var dataProducts products.DataProduct
-	if err := json.Unmarshal(responseGetProducts.Res, &dataProducts); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+	// The limit query parameter changes the request as much as the body
+	record, err := h.idempotency.Begin(uid, key, []byte(r.URL.RawQuery+"\n"+string(body)))
+	if err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	// Handle check if product < order product
-	for _, prod := range dataProducts.Data.Items {
-		for _, orderProd := range bReq.ProductOrder {
-			if prod.Id == orderProd.ProductID && prod.Stock < orderProd.Qty {
-				helper.HandleResponse(w, h.render, http.StatusBadRequest, "Product out of stock", nil)
-				return
-			}
-		}
+	if record != nil && record.Completed() {
+		replayResponse(w, record)
+		return
 	}
 
-	// Calucate subtotal based on price (data products) * qty (input user)
-	for i, orderProd := range bReq.ProductOrder {
-		for _, prod := range dataProducts.Data.Items {
-			if prod.Id == orderProd.ProductID {
-				bReq.ProductOrder[i].Price = prod.Price
-				bReq.ProductOrder[i].SubtotalPrice = prod.Price * float64(orderProd.Qty)
-			}
-		}
+	if record != nil {
+		h.takeOverOrder(w, uid, key, *record.SagaID, bReq)
+		return
 	}
 
-	// Calculate total prices based on subtotal price
-	var total float64
-	for _, orderProd := range bReq.ProductOrder {
-		total += orderProd.SubtotalPrice
-	}
-	bReq.TotalPrice = total
+	h.createIdempotentOrder(w, uid, key, bReq)
+}
 
-	// Create order to order services
-	netClientCreateOrder := client.NetClientRequest{
-		NetClient:  client.NetClient,
-		RequestUrl: createOrderUrl,
+// createIdempotentOrder records the saga on the key before running it, so a
+// retry that takes the key over answers with this checkout instead of placing
+// another order.
+func (h *Handler) createIdempotentOrder(w http.ResponseWriter, userID uuid.UUID, key string, bReq order.CreateOrderRequest) {
+	sagaID := uuid.New()
+	if err := h.idempotency.SetSaga(userID, key, sagaID); err != nil {
+		h.releaseKey(userID, key)
+		helper.HandleError(w, h.render, err)
+		return
 	}
 
-	netClientCreateOrder.Post(bReq, createOrderChannel)
-	responseCreateOrder := <-createOrderChannel
-	if responseCreateOrder.Err != nil || responseCreateOrder.StatusCode != http.StatusCreated {
-		helper.HandleResponse(w, h.render, responseCreateOrder.StatusCode, responseCreateOrder.Res, nil)
+	recorder := newResponseRecorder(w)
+	if !h.createOrder(recorder, sagaID, bReq) {
+		// Nothing was created, the client may retry with the same key
+		h.releaseKey(userID, key)
+		recorder.flush()
 		return
 	}
 
-	var orderID string
-	if err := json.Unmarshal(responseCreateOrder.Res, &orderID); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+	h.completeKey(w, recorder, userID, key)
+}
+
+// takeOverOrder answers a retry whose earlier attempt lost its claim after
+// starting the saga. A completed saga is answered like the first attempt
+// would have been, a rolled back one left nothing behind and runs again. Any
+// other saga is still being finished by ResumeStale or waits for an admin.
+func (h *Handler) takeOverOrder(w http.ResponseWriter, userID uuid.UUID, key string, sagaID uuid.UUID, bReq order.CreateOrderRequest) {
+	saga, err := h.checkout.GetSaga(sagaID)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	// Calculate stock product based on data products - qty order
-	for _, orderProd := range bReq.ProductOrder {
-		for _, prod := range dataProducts.Data.Items {
-			if prod.Id == orderProd.ProductID {
-				var requestUpdate order.UpdateQtyRequest
-				requestUpdate.Stock = prod.Stock - orderProd.Qty
-				requestUpdate.ProductId = prod.Id
-				bReq.UpdateQty = append(bReq.UpdateQty, requestUpdate)
-			}
-		}
+	switch saga.Status {
+	case checkout.StatusCompleted:
+		recorder := newResponseRecorder(w)
+		writeOrder(recorder, h.render, saga)
+		h.completeKey(w, recorder, userID, key)
+	case checkout.StatusCompensated:
+		h.createIdempotentOrder(w, userID, key, bReq)
+	default:
+		helper.HandleError(w, h.render, idempotency.ErrRequestInProgress)
 	}
+}
 
-	// Update stock product to product service
-	netClientUpdateStock := client.NetClientRequest{
-		NetClient:  client.NetClient,
-		RequestUrl: updateProductUrl,
+// completeKey stores the recorded response and sends it. When it cannot be
+// stored the client gets an error instead, the key still points at the saga
+// so a retry answers with it.
+func (h *Handler) completeKey(w http.ResponseWriter, recorder *responseRecorder, userID uuid.UUID, key string) {
+	if err := h.idempotency.Complete(userID, key, recorder.statusCode, recorder.body.Bytes()); err != nil {
+		log.Printf("[ORDER] failed to store response for idempotency key: %v", err)
+		helper.HandleError(w, h.render, err)
+		return
 	}
 
-	netClientUpdateStock.Patch(bReq.UpdateQty, updateStockChannel)
-	responseUpdateStock := <-updateStockChannel
-	if responseUpdateStock.Err != nil || responseUpdateStock.StatusCode != http.StatusOK {
-		if err := json.Unmarshal(responseUpdateStock.Res, &responseError); err != nil {
-			helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
-			return
-		}
+	recorder.flush()
+}
 
-		helper.HandleResponse(w, h.render, responseUpdateStock.StatusCode, responseError, nil)
-		return
+func (h *Handler) releaseKey(userID uuid.UUID, key string) {
+	if err := h.idempotency.Release(userID, key); err != nil {
+		log.Printf("[ORDER] failed to release idempotency key: %v", err)
 	}
+}
 
-	// Set auth header, payment type, and transaction details for request payment services
-	bReq.BasicAuthHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(h.serverKey+":"))
-	bReq.PaymentType = "bank_transfer"
-	bReq.TransactionDetails.OrderID = orderID
-	bReq.TransactionDetails.GrossAmount = bReq.TotalPrice
-
-	// Create payment to payment services
-	netClientPayment := client.NetClientRequest{
-		NetClient:  client.NetClient,
-		RequestUrl: paymentUrl,
+// createOrder runs the checkout and writes the response. It reports whether
+// the checkout left anything behind, a rolled back checkout may run again.
+func (h *Handler) createOrder(w http.ResponseWriter, sagaID uuid.UUID, bReq order.CreateOrderRequest) bool {
+	saga, err := h.checkout.Checkout(sagaID, bReq)
+	if err != nil {
+		h.handleCheckoutError(w, err)
+		return saga != nil && saga.Status != checkout.StatusCompensated
 	}
 
-	netClientPayment.Post(bReq, paymentChannel)
-	responsePayment := <-paymentChannel
+	writeOrder(w, h.render, saga)
+	return true
+}
 
-	var paymentResponse payment.CreatePaymentResponse
-	if responsePayment.Err != nil || responsePayment.StatusCode != http.StatusCreated {
-		if err := json.Unmarshal(responsePayment.Res, &paymentResponse); err != nil {
-			helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
-			return
-		}
+func writeOrder(w http.ResponseWriter, render *renderer.Render, saga *checkout.Saga) {
+	helper.HandleResponse(w, render, http.StatusCreated, helper.SUCCESS_MESSSAGE, saga.State.Payment)
+}
 
-		helper.HandleResponse(w, h.render, responsePayment.StatusCode, paymentResponse, nil)
+// handleCheckoutError passes the error of another service on to the client as
+// it was answered, like before the checkout became a saga.
+func (h *Handler) handleCheckoutError(w http.ResponseWriter, err error) {
+	var statusErr *client.StatusError
+	if !errors.As(err, &statusErr) {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	if err := json.Unmarshal(responsePayment.Res, &paymentResponse); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
-		return
+	var message interface{} = string(statusErr.Body)
+	var body map[string]interface{}
+	if json.Unmarshal(statusErr.Body, &body) == nil {
+		message = body
 	}
 
-	helper.HandleResponse(w, h.render, responsePayment.StatusCode, helper.SUCCESS_MESSSAGE, paymentResponse)
+	helper.HandleResponse(w, h.render, statusErr.StatusCode, message, nil)
 }
 
 func (h *Handler) CallbackPayment(w http.ResponseWriter, r *http.Request) {
+	// Midtrans adds fields per payment type, so the notification is decoded
+	// leniently instead of through helper.DecodeJSON
 	var midtrans order.RequestFromMidtrans
 	if err := json.NewDecoder(r.Body).Decode(&midtrans); err != nil {
 		helper.HandleResponse(w, h.render, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
+	// The route is public, only a notification signed with the server key
+	// may touch reservations or mark an order paid
+	if !midtrans.ValidSignature(h.serverKey) {
+		log.Printf("[ORDER] rejected notification for order %s with an invalid signature", midtrans.OrderID)
+		helper.HandleError(w, h.render, payment.ErrInvalidSignature)
+		return
+	}
+
 	if strings.Contains(midtrans.StatusMessage, "notification") {
 		helper.HandleResponse(w, h.render, http.StatusNotFound, "not a notification path", nil)
 		return
 	}
 
+	// An error answers with a 500 so Midtrans delivers the notification again
+	switch midtrans.TransactionStatus {
+	case payment.TxStatusCapture, payment.TxStatusSettlement:
+		if midtrans.FraudStatus == payment.FraudStatusChallenge {
+			helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+			return
+		}
+
+		if err := h.reservations.Confirm(midtrans.OrderID); err != nil {
+			log.Printf("[ORDER] failed to confirm reservations of order %s: %v", midtrans.OrderID, err)
+			helper.HandleError(w, h.render, err)
+			return
+		}
+	case payment.TxStatusDeny, payment.TxStatusCancel, payment.TxStatusExpire, payment.TxStatusFailure:
+		if err := h.reservations.Release(midtrans.OrderID); err != nil {
+			log.Printf("[ORDER] failed to release reservations of order %s: %v", midtrans.OrderID, err)
+			helper.HandleError(w, h.render, err)
+			return
+		}
+
+		helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+		return
+	default:
+		// Still pending, nothing is paid yet
+		helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+		return
+	}
+
 	timeNow := time.Now()
 	var bReq order.RequestCallback
 	bReq.OrderId = midtrans.OrderID
@@ -252,14 +279,14 @@ func (h *Handler) CallbackPayment(w http.ResponseWriter, r *http.Request) {
 	}
 	netClient.Post(bReq, callbackChannel)
 	responseCallback := <-callbackChannel
-	if responseCallback.Err != nil || responseCallback.StatusCode != http.StatusOK {
-		helper.HandleResponse(w, h.render, responseCallback.StatusCode, responseCallback.Res, nil)
+	if err := orderServiceError(responseCallback, http.StatusOK); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
 	var bResp string
 	if err := json.Unmarshal(responseCallback.Res, &bResp); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -284,14 +311,14 @@ func (h *Handler) CheckStatusPayment(w http.ResponseWriter, r *http.Request) {
 
 	netClient.Get(nil, checkStatusChannel)
 	responseCheckStatus := <-checkStatusChannel
-	if responseCheckStatus.Err != nil || responseCheckStatus.StatusCode != http.StatusOK {
-		helper.HandleResponse(w, h.render, responseCheckStatus.StatusCode, responseCheckStatus.Res, nil)
+	if err := orderServiceError(responseCheckStatus, http.StatusOK); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
 	var bResp order.Order
 	if err := json.Unmarshal(responseCheckStatus.Res, &bResp); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -315,18 +342,23 @@ func (h *Handler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var bReq order.UpdateStatus
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, err.Error(), nil)
+	if err := helper.DecodeJSON(r, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 	bReq.UserID = uid
 	bReq.OrderID = oid
 
+	if err := helper.Validate(h.validator, bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
 	updateChannel := make(chan client.Response)
 	go client.Put(client.NetClient, updateStatusUrl, bReq, updateChannel)
 	bResp := <-updateChannel
-	if bResp.Err != nil || bResp.StatusCode != http.StatusOK {
-		helper.HandleResponse(w, h.render, bResp.StatusCode, bResp.Err.Error(), nil)
+	if err := orderServiceError(bResp, http.StatusOK); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -341,37 +373,57 @@ func (h *Handler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) SellerUpdateStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	role := middleware.GetRole(ctx)
 	usrID := middleware.GetUserID(ctx)
-	log.Println("ROLE: ", role)
-	log.Println("USER ID: ", usrID)
 	uid, err := uuid.Parse(usrID)
 	if err != nil {
 		helper.HandleResponse(w, h.render, http.StatusBadRequest, "Error parse uuid", nil)
 		return
 	}
 
-	if role != middleware.RoleSeller {
-		helper.HandleResponse(w, h.render, http.StatusForbidden, "You are not Seller", nil)
+	var bReq order.RequestUpdateShipping
+	if err := helper.DecodeJSON(r, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
+	bReq.UserID = uid
 
-	var bReq order.RequestUpdateShipping
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, err.Error(), nil)
+	if err := helper.Validate(h.validator, bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	bReq.UserID = uid
-
 	netClient := client.NetClient
 	updateShippingChannel := make(chan client.Response)
 	client.Put(netClient, updateShppingUrl, bReq, updateShippingChannel)
 	bResp := <-updateShippingChannel
-	if bResp.Err != nil || bResp.StatusCode != http.StatusCreated {
-		helper.HandleResponse(w, h.render, bResp.StatusCode, bResp.Err.Error(), nil)
+	if err := orderServiceError(bResp, http.StatusCreated); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
 	helper.HandleResponse(w, h.render, http.StatusCreated, helper.SUCCESS_MESSSAGE, nil)
 }
+
+// orderServiceError maps a response of the order service other than the
+// expected one to the error passed on to the client. A failed call or a 5xx
+// is logged, the client only learns that the service is unavailable.
+func orderServiceError(response client.Response, expected int) error {
+	if response.Err == nil && response.StatusCode == expected {
+		return nil
+	}
+
+	switch {
+	case response.Err != nil:
+		log.Printf("[ORDER] failed to call order service: %v", response.Err)
+		return order.ErrOrderServiceUnavailable
+	case response.StatusCode == http.StatusNotFound:
+		return order.ErrOrderNotFound
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return order.ErrOrderForbidden
+	case response.StatusCode >= 400 && response.StatusCode < 500:
+		return order.ErrOrderRejected
+	default:
+		log.Printf("[ORDER] order service responded with status %d: %s", response.StatusCode, response.Res)
+		return order.ErrOrderServiceUnavailable
+	}
+}
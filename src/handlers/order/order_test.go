@@ -0,0 +1,266 @@
+package order
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-service/src/util/client"
+	"user-service/src/util/middleware"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/model/idempotency"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/payment"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/thedevsaddam/renderer"
+)
+
+type fakeReservations struct {
+	confirmed []string
+	released  []string
+}
+
+func (f *fakeReservations) Confirm(orderID string) error {
+	f.confirmed = append(f.confirmed, orderID)
+	return nil
+}
+
+func (f *fakeReservations) Release(orderID string) error {
+	f.released = append(f.released, orderID)
+	return nil
+}
+
+func sign(notification order.RequestFromMidtrans, serverKey string) string {
+	sum := sha512.Sum512([]byte(notification.OrderID + notification.StatusCode + notification.GrossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHandler_CallbackPayment(t *testing.T) {
+	const serverKey = "server-key"
+	notification := order.RequestFromMidtrans{
+		OrderID:           "order-1",
+		StatusCode:        "202",
+		GrossAmount:       "10000.00",
+		TransactionStatus: "cancel",
+	}
+
+	tests := []struct {
+		name         string
+		signature    string
+		wantStatus   int
+		wantReleased []string
+	}{
+		{
+			name:         "valid signature",
+			signature:    sign(notification, serverKey),
+			wantStatus:   http.StatusOK,
+			wantReleased: []string{"order-1"},
+		},
+		{
+			name:       "signed with another key",
+			signature:  sign(notification, "other-key"),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "missing signature",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservations := &fakeReservations{}
+			h := NewHandler(renderer.New(), validator.New(), nil, nil, reservations, serverKey)
+
+			body := notification
+			body.SignatureKey = tt.signature
+			var buf bytes.Buffer
+			json.NewEncoder(&buf).Encode(body)
+
+			req := httptest.NewRequest(http.MethodPost, "/order/callback", &buf)
+			rec := httptest.NewRecorder()
+			h.CallbackPayment(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantReleased, reservations.released)
+			assert.Empty(t, reservations.confirmed)
+		})
+	}
+}
+
+// fakeCheckout completes every checkout and keeps the sagas it ran.
+type fakeCheckout struct {
+	sagas map[uuid.UUID]*checkout.Saga
+	runs  int
+}
+
+func (f *fakeCheckout) Checkout(id uuid.UUID, bReq order.CreateOrderRequest) (*checkout.Saga, error) {
+	f.runs++
+	saga := &checkout.Saga{Id: id, Status: checkout.StatusCompleted, State: checkout.State{Payment: &payment.CreatePaymentResponse{OrderId: "order-1"}}}
+	f.sagas[id] = saga
+	return saga, nil
+}
+
+func (f *fakeCheckout) GetSaga(id uuid.UUID) (*checkout.Saga, error) {
+	saga, ok := f.sagas[id]
+	if !ok {
+		return nil, checkout.ErrSagaNotFound
+	}
+
+	return saga, nil
+}
+
+// fakeIdempotency serves Begin from a fixed record and remembers the saga
+// and response stored on the key.
+type fakeIdempotency struct {
+	record      *idempotency.Record
+	sagaID      *uuid.UUID
+	completeErr error
+	completed   []byte
+	released    bool
+}
+
+func (f *fakeIdempotency) Begin(userID uuid.UUID, key string, request []byte) (*idempotency.Record, error) {
+	return f.record, nil
+}
+
+func (f *fakeIdempotency) SetSaga(userID uuid.UUID, key string, sagaID uuid.UUID) error {
+	f.sagaID = &sagaID
+	return nil
+}
+
+func (f *fakeIdempotency) Complete(userID uuid.UUID, key string, statusCode int, response []byte) error {
+	if f.completeErr != nil {
+		return f.completeErr
+	}
+
+	f.completed = response
+	return nil
+}
+
+func (f *fakeIdempotency) Release(userID uuid.UUID, key string) error {
+	f.released = true
+	return nil
+}
+
+func TestHandler_CreateOrder(t *testing.T) {
+	body := `{"payment_type_id":"` + uuid.NewString() + `","order_number":"ORD-1","status":"Pending","product_order":[{"product_id":"` + uuid.NewString() + `","qty":1}]}`
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotency.Header, "key-1")
+		return req.WithContext(middleware.SetUserID(req.Context(), uuid.NewString()))
+	}
+
+	t.Run("records the saga on the key before the checkout", func(t *testing.T) {
+		checkouts := &fakeCheckout{sagas: map[uuid.UUID]*checkout.Saga{}}
+		keys := &fakeIdempotency{}
+		h := NewHandler(renderer.New(), validator.New(), checkouts, keys, nil, "")
+
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, newRequest())
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		if assert.NotNil(t, keys.sagaID) {
+			assert.Contains(t, checkouts.sagas, *keys.sagaID)
+		}
+		assert.Equal(t, rec.Body.Bytes(), keys.completed)
+	})
+
+	t.Run("takeover answers with the completed saga", func(t *testing.T) {
+		sagaID := uuid.New()
+		checkouts := &fakeCheckout{sagas: map[uuid.UUID]*checkout.Saga{
+			sagaID: {Id: sagaID, Status: checkout.StatusCompleted, State: checkout.State{Payment: &payment.CreatePaymentResponse{OrderId: "order-1"}}},
+		}}
+		keys := &fakeIdempotency{record: &idempotency.Record{SagaID: &sagaID}}
+		h := NewHandler(renderer.New(), validator.New(), checkouts, keys, nil, "")
+
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, newRequest())
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), "order-1")
+		assert.Zero(t, checkouts.runs)
+		assert.Equal(t, rec.Body.Bytes(), keys.completed)
+	})
+
+	t.Run("takeover of an unfinished saga is still in progress", func(t *testing.T) {
+		sagaID := uuid.New()
+		checkouts := &fakeCheckout{sagas: map[uuid.UUID]*checkout.Saga{
+			sagaID: {Id: sagaID, Status: checkout.StatusRunning},
+		}}
+		keys := &fakeIdempotency{record: &idempotency.Record{SagaID: &sagaID}}
+		h := NewHandler(renderer.New(), validator.New(), checkouts, keys, nil, "")
+
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, newRequest())
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Zero(t, checkouts.runs)
+		assert.False(t, keys.released)
+	})
+
+	t.Run("takeover of a rolled back saga checks out again", func(t *testing.T) {
+		sagaID := uuid.New()
+		checkouts := &fakeCheckout{sagas: map[uuid.UUID]*checkout.Saga{
+			sagaID: {Id: sagaID, Status: checkout.StatusCompensated},
+		}}
+		keys := &fakeIdempotency{record: &idempotency.Record{SagaID: &sagaID}}
+		h := NewHandler(renderer.New(), validator.New(), checkouts, keys, nil, "")
+
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, newRequest())
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, 1, checkouts.runs)
+		assert.NotEqual(t, &sagaID, keys.sagaID)
+	})
+
+	t.Run("failing to store the response is an error", func(t *testing.T) {
+		checkouts := &fakeCheckout{sagas: map[uuid.UUID]*checkout.Saga{}}
+		keys := &fakeIdempotency{completeErr: errors.New("connection reset")}
+		h := NewHandler(renderer.New(), validator.New(), checkouts, keys, nil, "")
+
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, newRequest())
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "order-1")
+		assert.False(t, keys.released, "the key keeps its saga for the retry")
+	})
+}
+
+func TestOrderServiceError(t *testing.T) {
+	tests := []struct {
+		name     string
+		response client.Response
+		want     error
+	}{
+		{name: "expected status", response: client.Response{StatusCode: http.StatusOK}},
+		{name: "call failed", response: client.Response{Err: errors.New("timeout")}, want: order.ErrOrderServiceUnavailable},
+		{name: "not found", response: client.Response{StatusCode: http.StatusNotFound}, want: order.ErrOrderNotFound},
+		{name: "forbidden", response: client.Response{StatusCode: http.StatusForbidden}, want: order.ErrOrderForbidden},
+		{name: "rejected", response: client.Response{StatusCode: http.StatusUnprocessableEntity}, want: order.ErrOrderRejected},
+		{name: "server error without an error", response: client.Response{StatusCode: http.StatusBadGateway}, want: order.ErrOrderServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := orderServiceError(tt.response, http.StatusOK)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
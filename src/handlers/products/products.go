@@ -8,19 +8,22 @@ import (
 	"user-service/src/util/middleware"
 	"user-service/src/util/repository/model/products"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/thedevsaddam/renderer"
 )
 
 type Handler struct {
-	render  *renderer.Render
-	baseURL string
+	render    *renderer.Render
+	validator *validator.Validate
+	baseURL   string
 }
 
-func NewHandler(r *renderer.Render) *Handler {
+func NewHandler(r *renderer.Render, validator *validator.Validate) *Handler {
 	return &Handler{
-		render:  r,
-		baseURL: "http://localhost:3000/api",
+		render:    r,
+		validator: validator,
+		baseURL:   "http://localhost:3000/api",
 	}
 }
 
@@ -31,8 +34,8 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	response := make(map[string]any)
 
 	var bReq products.CreateProductRequest
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "Invalid request payload", nil)
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -68,7 +71,7 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -130,7 +133,7 @@ func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -145,8 +148,14 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	productId := param["product_id"]
 
 	var bReq products.UpdateProductRequest
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "Invalid request payload", nil)
+	if err := helper.DecodeJSON(r, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+	bReq.Id = productId
+
+	if err := helper.Validate(h.validator, bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 	response := make(map[string]any)
@@ -183,7 +192,7 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -8,6 +8,7 @@ import (
 	"user-service/src/util/middleware"
 	"user-service/src/util/repository/model/products"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/thedevsaddam/renderer"
 )
 
@@ -16,14 +17,16 @@ var (
 )
 
 type Handler struct {
-	render  *renderer.Render
-	baseURL string
+	render    *renderer.Render
+	validator *validator.Validate
+	baseURL   string
 }
 
-func NewHandler(r *renderer.Render) *Handler {
+func NewHandler(r *renderer.Render, validator *validator.Validate) *Handler {
 	return &Handler{
-		render:  r,
-		baseURL: "http://localhost:3000/api",
+		render:    r,
+		validator: validator,
+		baseURL:   "http://localhost:3000/api",
 	}
 }
 
@@ -32,8 +35,8 @@ func (h *Handler) CreateShop(w http.ResponseWriter, r *http.Request) {
 	usrId := middleware.GetUserID(ctx)
 
 	var bReq products.CreateShopRequest
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "Invalid request payload", nil)
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -50,7 +53,7 @@ func (h *Handler) CreateShop(w http.ResponseWriter, r *http.Request) {
 	bResp := <-shopChannel
 	if bResp.Err != nil {
 		if err := json.Unmarshal(bResp.Res, &responseError); err != nil {
-			helper.HandleResponse(w, h.render, bResp.StatusCode, err.Error(), nil)
+			helper.HandleError(w, h.render, err)
 			return
 		}
 
@@ -70,7 +73,7 @@ func (h *Handler) CreateShop(w http.ResponseWriter, r *http.Request) {
 
 	var resp products.Response
 	if err := json.Unmarshal(bResp.Res, &resp); err != nil {
-		helper.HandleResponse(w, h.render, bResp.StatusCode, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -120,7 +123,7 @@ func (h *Handler) GetShops(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.Unmarshal(bResp.Res, &response); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -0,0 +1,42 @@
+package users
+
+import (
+	"net/http"
+	"user-service/src/util/helper"
+	"user-service/src/util/middleware"
+
+	"github.com/google/uuid"
+)
+
+func (h *Handler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	bResp, err := h.dto.Export(usrId)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="account-export.json"`)
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	bResp, err := h.dto.RequestDeletion(usrId)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
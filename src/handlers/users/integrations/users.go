@@ -1,12 +1,17 @@
 package integrations
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
+	"user-service/src/util/apperr"
 	"user-service/src/util/helper"
 	"user-service/src/util/helper/integrations"
-	"user-service/src/util/helper/jwt"
+	"user-service/src/util/middleware"
+	"user-service/src/util/repository/model/mfa"
+	"user-service/src/util/repository/model/sessions"
 	"user-service/src/util/repository/model/users"
 
 	"github.com/google/uuid"
@@ -15,6 +20,7 @@ import (
 
 type userDto interface {
 	Register(bReq users.Users) (*uuid.UUID, error)
+	SignIn(usr *users.Users, client sessions.ClientInfo) (*users.LoginResponse, *mfa.ChallengeResponse, error)
 }
 
 type userDtoIntegration interface {
@@ -24,17 +30,25 @@ type userDtoIntegration interface {
 	UserDataSignIn(state, code string) (*users.OauthUserData, error)
 }
 
+type attemptLimiter interface {
+	Begin(email string, ipAddress string) error
+	Fail(email string, ipAddress string) error
+	Refund(email string, ipAddress string) error
+}
+
 type Handler struct {
 	render      *renderer.Render
 	dto         userDto
 	integration userDtoIntegration
+	attempts    attemptLimiter
 }
 
-func NewHandler(render *renderer.Render, dto userDto, integration userDtoIntegration) *Handler {
+func NewHandler(render *renderer.Render, dto userDto, integration userDtoIntegration, attempts attemptLimiter) *Handler {
 	return &Handler{
 		render:      render,
 		dto:         dto,
 		integration: integration,
+		attempts:    attempts,
 	}
 }
 
@@ -47,23 +61,44 @@ func (h *Handler) SignIn(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) RedirectSignUp(w http.ResponseWriter, r *http.Request) {
-	handleOAuthCallback(w, r, h.render, h.dto, h.integration, h.integration.UserDataSignUp, true)
+	h.handleOAuthCallback(w, r, h.integration.UserDataSignUp, true)
 }
 
 func (h *Handler) RedirectSignIn(w http.ResponseWriter, r *http.Request) {
-	handleOAuthCallback(w, r, h.render, h.dto, h.integration, h.integration.UserDataSignIn, false)
+	h.handleOAuthCallback(w, r, h.integration.UserDataSignIn, false)
 }
 
-func handleOAuthCallback(w http.ResponseWriter, r *http.Request, render *renderer.Render, dto userDto, integration userDtoIntegration, userDataFunc func(state, code string) (*users.OauthUserData, error), register bool) {
+func (h *Handler) handleOAuthCallback(w http.ResponseWriter, r *http.Request, userDataFunc func(state, code string) (*users.OauthUserData, error), register bool) {
+	render, dto, integration := h.render, h.dto, h.integration
+	ipAddress := helper.ClientIP(r)
+
+	// Only the sign in is throttled, a failed sign up cannot be used to guess
+	// anything. The attempt is counted for the address up front and only
+	// stays counted when the OAuth exchange fails or the account is unknown.
+	if !register {
+		if err := h.attempts.Begin("", ipAddress); err != nil {
+			helper.HandleError(w, render, err)
+			return
+		}
+	}
+
 	state, code := r.FormValue("state"), r.FormValue("code")
 	if state == "" || code == "" {
-		helper.HandleResponse(w, render, http.StatusConflict, "state or code is nil", nil)
+		if !register {
+			h.refund("", ipAddress)
+		}
+
+		helper.HandleError(w, render, apperr.New(apperr.KindConflict, "missing_oauth_params", "state or code is nil"))
 		return
 	}
 
 	userData, err := userDataFunc(state, code)
 	if err != nil {
-		helper.HandleResponse(w, render, http.StatusInternalServerError, err.Error(), nil)
+		if !register {
+			h.fail("", ipAddress)
+		}
+
+		helper.HandleError(w, render, err)
 		return
 	}
 
@@ -75,29 +110,36 @@ func handleOAuthCallback(w http.ResponseWriter, r *http.Request, render *rendere
 			Limit: 1,
 		})
 		if err != nil {
-			helper.HandleResponse(w, render, http.StatusInternalServerError, err, nil)
+			helper.HandleError(w, render, err)
 			return
 		}
 
 		if len(*checkUser) > 0 {
-			helper.HandleResponse(w, render, http.StatusConflict, "User already registered", nil)
+			helper.HandleError(w, render, users.ErrUserAlreadyRegistered)
 			return
 		}
 
-		// Register user
+		// Register user, Google already verified the address when it says so
+		var emailVerifiedAt *time.Time
+		if userData.VerifiedEmail {
+			timeNow := time.Now()
+			emailVerifiedAt = &timeNow
+		}
+
 		userName := strings.ReplaceAll(strings.ToLower(userData.GivenName), " ", "")
 		bResp, err := dto.Register(users.Users{
 			Email:    userData.Email,
 			Username: userName,
-			Role:     "Admin",
+			Role:     middleware.RoleUser,
 			CategoryPreferences: []string{
 				"Baju",
 				"Buku",
 			},
-			Address: "Jakarta",
+			Address:         "Jakarta",
+			EmailVerifiedAt: emailVerifiedAt,
 		})
 		if err != nil {
-			helper.HandleResponse(w, render, http.StatusInternalServerError, err, nil)
+			helper.HandleError(w, render, err)
 			return
 		}
 
@@ -109,43 +151,73 @@ func handleOAuthCallback(w http.ResponseWriter, r *http.Request, render *rendere
 			Limit: 1,
 		})
 		if err != nil {
-			helper.HandleResponse(w, render, http.StatusInternalServerError, err, nil)
+			h.refund("", ipAddress)
+			helper.HandleError(w, render, err)
 			return
 		}
 
 		if len(*checkUser) == 0 {
-			helper.HandleResponse(w, render, http.StatusConflict, "User not yet registered", nil)
+			h.fail("", ipAddress)
+			helper.HandleError(w, render, users.ErrUserNotRegistered)
 			return
 		}
 
+		// Google vouched for the account, so the attempt is no guess. It is
+		// still refused while the account is locked.
+		if err := h.attempts.Begin(userData.Email, ""); err != nil {
+			h.refund("", ipAddress)
+			helper.HandleError(w, render, err)
+			return
+		}
+		defer h.refund(userData.Email, ipAddress)
+
 		usrLogin, err := integration.Login(users.Users{
 			Email: userData.Email,
 		})
 		if err != nil {
-			helper.HandleResponse(w, render, http.StatusInternalServerError, err, nil)
+			if errors.Is(err, users.ErrUserNotFound) {
+				err = users.ErrUserNotRegistered
+			}
+
+			helper.HandleError(w, render, err)
 			return
 		}
 
-		tokenExpiry := time.Minute * 20
-		accessToken, payload, err := jwt.CreateAccessToken(usrLogin.Email, usrLogin.Id.String(), usrLogin.Role, tokenExpiry)
-		if err != nil {
+		if usrLogin.EmailVerifiedAt == nil && !userData.VerifiedEmail {
+			helper.HandleError(w, render, users.ErrEmailNotVerified)
 			return
 		}
 
-		refreshTokenExpiry := time.Hour * 72
-		refreshToken, refreshTokenPayload, err := jwt.CreateRefreshToken(usrLogin.Email, usrLogin.Id.String(), usrLogin.Role, refreshTokenExpiry)
+		bResp, challenge, err := dto.SignIn(usrLogin, sessions.ClientInfo{
+			UserAgent: r.UserAgent(),
+			IpAddress: ipAddress,
+		})
 		if err != nil {
+			if errors.Is(err, users.ErrUserNotFound) {
+				err = users.ErrUserNotRegistered
+			}
+
+			helper.HandleError(w, render, err)
 			return
 		}
 
-		bResp := users.LoginResponse{
-			AccessToken:          accessToken,
-			AccessTokenExpiresAt: payload.ExpiresAt.Time,
-			RefreshToken:         refreshToken,
-			RefreshTokenExpiryAt: refreshTokenPayload.ExpiresAt.Time,
-			Users:                usrLogin,
+		if challenge != nil {
+			helper.HandleResponse(w, render, http.StatusOK, "Two-factor authentication required", challenge)
+			return
 		}
 
 		helper.HandleResponse(w, render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
 	}
 }
+
+func (h *Handler) fail(email string, ipAddress string) {
+	if err := h.attempts.Fail(email, ipAddress); err != nil {
+		log.Printf("[ATTEMPTS] failed to record sign in failure: %v", err)
+	}
+}
+
+func (h *Handler) refund(email string, ipAddress string) {
+	if err := h.attempts.Refund(email, ipAddress); err != nil {
+		log.Printf("[ATTEMPTS] failed to refund sign in attempt: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"user-service/src/util/apperr"
+	"user-service/src/util/helper"
+	"user-service/src/util/middleware"
+	"user-service/src/util/repository/model/mfa"
+
+	"github.com/google/uuid"
+)
+
+func (h *Handler) SignInMFA(w http.ResponseWriter, r *http.Request) {
+	var bReq mfa.VerifyRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	bReq.UserAgent = r.UserAgent()
+	bReq.IpAddress = helper.ClientIP(r)
+
+	bResp, err := h.dto.VerifyMFA(bReq)
+	if err != nil {
+		// A wrong code at sign-in is a failed login, not a malformed request.
+		if errors.Is(err, mfa.ErrInvalidMFACode) {
+			err = apperr.Unauthorized("invalid_mfa_code", err.Error())
+		}
+
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) SetupMFA(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	bResp, err := h.dto.SetupMFA(usrId)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	var bReq mfa.CodeRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if err := h.dto.ConfirmMFA(usrId, bReq.Code); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	var bReq mfa.CodeRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if err := h.dto.DisableMFA(usrId, bReq.Code); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
@@ -6,7 +6,11 @@ package users
 
 import (
 	reflect "reflect"
+	jwt "user-service/src/util/helper/jwt"
+	policy "user-service/src/util/policy"
 	model "user-service/src/util/repository/model"
+	mfa "user-service/src/util/repository/model/mfa"
+	sessions "user-service/src/util/repository/model/sessions"
 	users "user-service/src/util/repository/model/users"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,6 +40,77 @@ func (m *MockuserDto) EXPECT() *MockuserDtoMockRecorder {
 	return m.recorder
 }
 
+// ConfirmMFA mocks base method.
+func (m *MockuserDto) ConfirmMFA(userID uuid.UUID, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmMFA", userID, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmMFA indicates an expected call of ConfirmMFA.
+func (mr *MockuserDtoMockRecorder) ConfirmMFA(userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmMFA", reflect.TypeOf((*MockuserDto)(nil).ConfirmMFA), userID, code)
+}
+
+// DeleteSession mocks base method.
+func (m *MockuserDto) DeleteSession(userID, sessionID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", userID, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession.
+func (mr *MockuserDtoMockRecorder) DeleteSession(userID, sessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockuserDto)(nil).DeleteSession), userID, sessionID)
+}
+
+// DisableMFA mocks base method.
+func (m *MockuserDto) DisableMFA(userID uuid.UUID, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableMFA", userID, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableMFA indicates an expected call of DisableMFA.
+func (mr *MockuserDtoMockRecorder) DisableMFA(userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableMFA", reflect.TypeOf((*MockuserDto)(nil).DisableMFA), userID, code)
+}
+
+// Export mocks base method.
+func (m *MockuserDto) Export(userID uuid.UUID) (*users.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", userID)
+	ret0, _ := ret[0].(*users.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockuserDtoMockRecorder) Export(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockuserDto)(nil).Export), userID)
+}
+
+// ForgotPassword mocks base method.
+func (m *MockuserDto) ForgotPassword(email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgotPassword", email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgotPassword indicates an expected call of ForgotPassword.
+func (mr *MockuserDtoMockRecorder) ForgotPassword(email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgotPassword", reflect.TypeOf((*MockuserDto)(nil).ForgotPassword), email)
+}
+
 // Get mocks base method.
 func (m *MockuserDto) Get(bReq users.RequestUsers) (*model.BaseModel, error) {
 	m.ctrl.T.Helper()
@@ -51,13 +126,29 @@ func (mr *MockuserDtoMockRecorder) Get(bReq interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockuserDto)(nil).Get), bReq)
 }
 
+// GetSessions mocks base method.
+func (m *MockuserDto) GetSessions(userID uuid.UUID, currentSessionID string) (*[]sessions.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", userID, currentSessionID)
+	ret0, _ := ret[0].(*[]sessions.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockuserDtoMockRecorder) GetSessions(userID, currentSessionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockuserDto)(nil).GetSessions), userID, currentSessionID)
+}
+
 // Login mocks base method.
-func (m *MockuserDto) Login(bReq users.Users) (*users.LoginResponse, error) {
+func (m *MockuserDto) Login(bReq users.UsersLogin) (*users.LoginResponse, *mfa.ChallengeResponse, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Login", bReq)
 	ret0, _ := ret[0].(*users.LoginResponse)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(*mfa.ChallengeResponse)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // Login indicates an expected call of Login.
@@ -66,6 +157,49 @@ func (mr *MockuserDtoMockRecorder) Login(bReq interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockuserDto)(nil).Login), bReq)
 }
 
+// Logout mocks base method.
+func (m *MockuserDto) Logout(payload *jwt.Payload) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", payload)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockuserDtoMockRecorder) Logout(payload interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockuserDto)(nil).Logout), payload)
+}
+
+// LogoutAll mocks base method.
+func (m *MockuserDto) LogoutAll(userID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogoutAll", userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LogoutAll indicates an expected call of LogoutAll.
+func (mr *MockuserDtoMockRecorder) LogoutAll(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogoutAll", reflect.TypeOf((*MockuserDto)(nil).LogoutAll), userID)
+}
+
+// RefreshToken mocks base method.
+func (m *MockuserDto) RefreshToken(refreshToken string) (*users.LoginResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshToken", refreshToken)
+	ret0, _ := ret[0].(*users.LoginResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshToken indicates an expected call of RefreshToken.
+func (mr *MockuserDtoMockRecorder) RefreshToken(refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshToken", reflect.TypeOf((*MockuserDto)(nil).RefreshToken), refreshToken)
+}
+
 // Register mocks base method.
 func (m *MockuserDto) Register(bReq users.Users) (*uuid.UUID, error) {
 	m.ctrl.T.Helper()
@@ -81,16 +215,103 @@ func (mr *MockuserDtoMockRecorder) Register(bReq interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockuserDto)(nil).Register), bReq)
 }
 
+// RequestDeletion mocks base method.
+func (m *MockuserDto) RequestDeletion(userID uuid.UUID) (*users.DeletionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestDeletion", userID)
+	ret0, _ := ret[0].(*users.DeletionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestDeletion indicates an expected call of RequestDeletion.
+func (mr *MockuserDtoMockRecorder) RequestDeletion(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestDeletion", reflect.TypeOf((*MockuserDto)(nil).RequestDeletion), userID)
+}
+
+// ResendVerification mocks base method.
+func (m *MockuserDto) ResendVerification(email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendVerification", email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResendVerification indicates an expected call of ResendVerification.
+func (mr *MockuserDtoMockRecorder) ResendVerification(email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendVerification", reflect.TypeOf((*MockuserDto)(nil).ResendVerification), email)
+}
+
+// ResetPassword mocks base method.
+func (m *MockuserDto) ResetPassword(token, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", token, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockuserDtoMockRecorder) ResetPassword(token, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockuserDto)(nil).ResetPassword), token, password)
+}
+
+// SetupMFA mocks base method.
+func (m *MockuserDto) SetupMFA(userID uuid.UUID) (*mfa.SetupResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetupMFA", userID)
+	ret0, _ := ret[0].(*mfa.SetupResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetupMFA indicates an expected call of SetupMFA.
+func (mr *MockuserDtoMockRecorder) SetupMFA(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupMFA", reflect.TypeOf((*MockuserDto)(nil).SetupMFA), userID)
+}
+
 // UpdateProfile mocks base method.
-func (m *MockuserDto) UpdateProfile(id uuid.UUID, bReq users.Users) error {
+func (m *MockuserDto) UpdateProfile(actor policy.Actor, id uuid.UUID, bReq users.UpdateProfileRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateProfile", id, bReq)
+	ret := m.ctrl.Call(m, "UpdateProfile", actor, id, bReq)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateProfile indicates an expected call of UpdateProfile.
-func (mr *MockuserDtoMockRecorder) UpdateProfile(id, bReq interface{}) *gomock.Call {
+func (mr *MockuserDtoMockRecorder) UpdateProfile(actor, id, bReq interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockuserDto)(nil).UpdateProfile), actor, id, bReq)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockuserDto) VerifyEmail(token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockuserDtoMockRecorder) VerifyEmail(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockuserDto)(nil).VerifyEmail), token)
+}
+
+// VerifyMFA mocks base method.
+func (m *MockuserDto) VerifyMFA(bReq mfa.VerifyRequest) (*users.LoginResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyMFA", bReq)
+	ret0, _ := ret[0].(*users.LoginResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyMFA indicates an expected call of VerifyMFA.
+func (mr *MockuserDtoMockRecorder) VerifyMFA(bReq interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockuserDto)(nil).UpdateProfile), id, bReq)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyMFA", reflect.TypeOf((*MockuserDto)(nil).VerifyMFA), bReq)
 }
@@ -1,13 +1,22 @@
 package users
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"user-service/src/util/apperr"
 	"user-service/src/util/helper"
+	"user-service/src/util/helper/jwt"
+	"user-service/src/util/middleware"
+	"user-service/src/util/policy"
 	"user-service/src/util/repository/model"
+	"user-service/src/util/repository/model/mfa"
+	"user-service/src/util/repository/model/sessions"
+	"user-service/src/util/repository/model/tokens"
 	"user-service/src/util/repository/model/users"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/thedevsaddam/renderer"
@@ -16,19 +25,36 @@ import (
 type userDto interface {
 	Register(bReq users.Users) (*uuid.UUID, error)
 	Get(bReq users.RequestUsers) (*model.BaseModel, error)
-	UpdateProfile(id uuid.UUID, bReq users.Users) error
-	Login(bReq users.Users) (*users.LoginResponse, error)
+	UpdateProfile(actor policy.Actor, id uuid.UUID, bReq users.UpdateProfileRequest) error
+	Login(bReq users.UsersLogin) (*users.LoginResponse, *mfa.ChallengeResponse, error)
+	RefreshToken(refreshToken string) (*users.LoginResponse, error)
+	Logout(payload *jwt.Payload) error
+	LogoutAll(userID uuid.UUID) error
+	GetSessions(userID uuid.UUID, currentSessionID string) (*[]sessions.Session, error)
+	DeleteSession(userID uuid.UUID, sessionID uuid.UUID) error
+	ResendVerification(email string) error
+	VerifyEmail(token string) error
+	ForgotPassword(email string) error
+	ResetPassword(token string, password string) error
+	VerifyMFA(bReq mfa.VerifyRequest) (*users.LoginResponse, error)
+	SetupMFA(userID uuid.UUID) (*mfa.SetupResponse, error)
+	ConfirmMFA(userID uuid.UUID, code string) error
+	DisableMFA(userID uuid.UUID, code string) error
+	Export(userID uuid.UUID) (*users.Export, error)
+	RequestDeletion(userID uuid.UUID) (*users.DeletionResponse, error)
 }
 
 type Handler struct {
-	render *renderer.Render
-	dto    userDto
+	render    *renderer.Render
+	validator *validator.Validate
+	dto       userDto
 }
 
-func NewUserHandler(dto userDto, render *renderer.Render) *Handler {
+func NewUserHandler(dto userDto, render *renderer.Render, validator *validator.Validate) *Handler {
 	return &Handler{
-		dto:    dto,
-		render: render,
+		dto:       dto,
+		render:    render,
+		validator: validator,
 	}
 }
 
@@ -38,18 +64,24 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 
 	usrId, err := uuid.Parse(id)
 	if err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "Invalid user id", nil)
+		helper.HandleError(w, h.render, helper.InvalidParam("user_id"))
 		return
 	}
 
-	var bReq users.Users
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, err, nil)
+	actor, err := policy.ActorFromContext(r.Context())
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	var bReq users.UpdateProfileRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	if err := h.dto.UpdateProfile(usrId, bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err, nil)
+	if err := h.dto.UpdateProfile(actor, usrId, bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -69,48 +101,120 @@ func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		var err error
 		userId, err = uuid.Parse(userIdStr)
 		if err != nil {
-			helper.HandleResponse(w, h.render, http.StatusBadRequest, "Invalid user id", nil)
+			helper.HandleError(w, h.render, helper.InvalidParam("user_id"))
 			return
 		}
 		userIdPtr = userId
 	}
 
-	page, err := strconv.Atoi(param.Get("page"))
+	// Omitted page and limit fall back to the defaults of the usecase
+	page, err := helper.ParsePositiveInt(param.Get("page"))
 	if err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "page cant not nil", nil)
+		helper.HandleError(w, h.render, helper.InvalidParam("page"))
 		return
 	}
-	limit, err := strconv.Atoi(param.Get("limit"))
+
+	limit, err := helper.ParsePositiveInt(param.Get("limit"))
+	if err != nil || limit > users.MaxLimit {
+		helper.HandleError(w, h.render, apperr.Validation("invalid_limit", "Invalid limit, it must be between 1 and "+strconv.Itoa(users.MaxLimit)))
+		return
+	}
+
+	var includeDeleted bool
+	if param.Get("include_deleted") != "" {
+		includeDeleted, err = strconv.ParseBool(param.Get("include_deleted"))
+		if err != nil {
+			helper.HandleError(w, h.render, helper.InvalidParam("include_deleted"))
+			return
+		}
+	}
+
+	createdFrom, err := parseTimeParam(param.Get("created_from"), false)
 	if err != nil {
-		helper.HandleResponse(w, h.render, http.StatusBadRequest, "limit cant not nil", nil)
+		helper.HandleError(w, h.render, helper.InvalidParam("created_from"))
 		return
 	}
 
+	createdTo, err := parseTimeParam(param.Get("created_to"), true)
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("created_to"))
+		return
+	}
+
+	var categories []string
+	for _, value := range param["category"] {
+		for _, category := range strings.Split(value, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				categories = append(categories, category)
+			}
+		}
+	}
+
 	bResp, err := h.dto.Get(users.RequestUsers{
-		Search: search,
-		Role:   role,
-		UserId: userIdPtr,
-		Page:   page,
-		Limit:  limit,
+		Search:         search,
+		Role:           role,
+		UserId:         userIdPtr,
+		Page:           page,
+		Limit:          limit,
+		IncludeDeleted: includeDeleted,
+		Sort:           param.Get("sort"),
+		Cursor:         param.Get("cursor"),
+		CreatedFrom:    createdFrom,
+		CreatedTo:      createdTo,
+		Categories:     categories,
 	})
 	if err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err, nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
 	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
 }
 
+// parseTimeParam parses an optional RFC 3339 time or date. A date as upper
+// bound covers the whole day.
+func parseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	return &t, nil
+}
+
 func (h *Handler) SignUpByEmail(w http.ResponseWriter, r *http.Request) {
-	var bReq users.Users
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusConflict, err.Error(), nil)
+	var bReq users.RegisterRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	bResp, err := h.dto.Register(bReq)
+	if bReq.Role == "" {
+		bReq.Role = middleware.RoleUser
+	}
+
+	bResp, err := h.dto.Register(users.Users{
+		Email:               bReq.Email,
+		Username:            bReq.Username,
+		Role:                bReq.Role,
+		Address:             bReq.Address,
+		CategoryPreferences: bReq.CategoryPreferences,
+		Password:            bReq.Password,
+	})
 	if err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
@@ -118,17 +222,174 @@ func (h *Handler) SignUpByEmail(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) SignInByEmail(w http.ResponseWriter, r *http.Request) {
-	var bReq users.Users
-	if err := json.NewDecoder(r.Body).Decode(&bReq); err != nil {
-		helper.HandleResponse(w, h.render, http.StatusConflict, err.Error(), nil)
+	var bReq users.UsersLogin
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
 		return
 	}
 
-	bResp, err := h.dto.Login(bReq)
+	bReq.UserAgent = r.UserAgent()
+	bReq.IpAddress = helper.ClientIP(r)
+
+	bResp, challenge, err := h.dto.Login(bReq)
 	if err != nil {
-		helper.HandleResponse(w, h.render, http.StatusInternalServerError, err.Error(), nil)
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if challenge != nil {
+		helper.HandleResponse(w, h.render, http.StatusOK, "Two-factor authentication required", challenge)
 		return
 	}
 
 	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
 }
+
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var bReq tokens.RefreshTokenRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	bResp, err := h.dto.RefreshToken(bReq.RefreshToken)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	payload := middleware.GetToken(r.Context())
+	if payload == nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	if err := h.dto.Logout(payload); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	if err := h.dto.LogoutAll(usrId); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	usrId, err := uuid.Parse(middleware.GetUserID(ctx))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	var currentSessionID string
+	if payload := middleware.GetToken(ctx); payload != nil {
+		currentSessionID = payload.SessionID
+	}
+
+	bResp, err := h.dto.GetSessions(usrId, currentSessionID)
+	if err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, bResp)
+}
+
+func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	usrId, err := uuid.Parse(middleware.GetUserID(r.Context()))
+	if err != nil {
+		helper.HandleError(w, h.render, helper.ErrUnauthorized)
+		return
+	}
+
+	sessionId, err := uuid.Parse(mux.Vars(r)["session_id"])
+	if err != nil {
+		helper.HandleError(w, h.render, helper.InvalidParam("session_id"))
+		return
+	}
+
+	if err := h.dto.DeleteSession(usrId, sessionId); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var bReq users.ResendVerificationRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if err := h.dto.ResendVerification(bReq.Email); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, "If the email address is registered and not verified yet, a verification mail has been sent", nil)
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		helper.HandleError(w, h.render, helper.InvalidParam("token"))
+		return
+	}
+
+	if err := h.dto.VerifyEmail(token); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var bReq users.ForgotPasswordRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if err := h.dto.ForgotPassword(bReq.Email); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, "If the email address is registered, a password reset mail has been sent", nil)
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var bReq users.ResetPasswordRequest
+	if err := helper.DecodeAndValidate(r, h.validator, &bReq); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	if err := h.dto.ResetPassword(bReq.Token, bReq.Password); err != nil {
+		helper.HandleError(w, h.render, err)
+		return
+	}
+
+	helper.HandleResponse(w, h.render, http.StatusOK, helper.SUCCESS_MESSSAGE, nil)
+}
@@ -8,10 +8,20 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 	"user-service/src/util/helper"
+	"user-service/src/util/helper/jwt"
+	"user-service/src/util/middleware"
+	"user-service/src/util/policy"
 	model "user-service/src/util/repository/model"
+	attempts "user-service/src/util/repository/model/attempts"
+	mfa "user-service/src/util/repository/model/mfa"
+	sessions "user-service/src/util/repository/model/sessions"
+	tokens "user-service/src/util/repository/model/tokens"
 	users "user-service/src/util/repository/model/users"
+	"user-service/src/util/repository/query"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -25,32 +35,38 @@ func TestHandler_UpdateProfile(t *testing.T) {
 
 	mockUserDto := NewMockuserDto(ctrl)
 	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
 	h := NewUserHandler(
 		mockUserDto,
 		rend,
+		validate,
 	)
 
-	t.Run("successful update", func(t *testing.T) {
-		usrId := uuid.New()
-		user := users.Users{
-			Id:                  usrId,
-			Email:               "",
-			Username:            "",
-			Role:                "",
-			Address:             "",
-			CategoryPreferences: []string{},
-			CreatedAt:           nil,
-			UpdatedAt:           nil,
-			DeletedAt:           nil,
-		}
+	usrId := uuid.New()
+	actor := policy.Actor{UserID: usrId, Role: middleware.RoleUser}
+	bReq := users.UpdateProfileRequest{
+		Email:               "user@example.com",
+		Address:             "Jakarta",
+		CategoryPreferences: []string{"Buku"},
+	}
 
-		mockUserDto.EXPECT().UpdateProfile(usrId, user).Return(nil)
+	withActor := func(req *http.Request, actor policy.Actor) *http.Request {
+		ctx := middleware.SetUserID(req.Context(), actor.UserID.String())
+		ctx = middleware.SetRole(ctx, actor.Role)
+		return req.WithContext(ctx)
+	}
 
-		body, _ := json.Marshal(user)
+	t.Run("successful update", func(t *testing.T) {
+		mockUserDto.EXPECT().UpdateProfile(actor, usrId, bReq).Return(nil)
+
+		body, _ := json.Marshal(bReq)
 		req, err := http.NewRequest("PUT", "/users/"+usrId.String(), bytes.NewBuffer(body))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		req = mux.SetURLVars(req, map[string]string{"user_id": usrId.String()})
+		req = withActor(req, actor)
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.UpdateProfile)
@@ -66,6 +82,7 @@ func TestHandler_UpdateProfile(t *testing.T) {
 		assert.NoError(t, err)
 
 		req = mux.SetURLVars(req, map[string]string{"user_id": "invalid-uuid"})
+		req = withActor(req, actor)
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.UpdateProfile)
@@ -77,12 +94,12 @@ func TestHandler_UpdateProfile(t *testing.T) {
 	})
 
 	t.Run("decode error", func(t *testing.T) {
-		usrId := uuid.New()
-
 		req, err := http.NewRequest("PUT", "/users/"+usrId.String(), bytes.NewBuffer([]byte("invalid body")))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		req = mux.SetURLVars(req, map[string]string{"user_id": usrId.String()})
+		req = withActor(req, actor)
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.UpdateProfile)
@@ -92,27 +109,53 @@ func TestHandler_UpdateProfile(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 	})
 
-	t.Run("update profile error", func(t *testing.T) {
-		usrId := uuid.New()
-		user := users.Users{
-			Id:                  usrId,
-			Email:               "",
-			Username:            "",
-			Role:                "",
-			Address:             "",
-			CategoryPreferences: []string{},
-			CreatedAt:           nil,
-			UpdatedAt:           nil,
-			DeletedAt:           nil,
-		}
+	t.Run("invalid email", func(t *testing.T) {
+		body, _ := json.Marshal(users.UpdateProfileRequest{Email: "not-an-email"})
+		req, err := http.NewRequest("PUT", "/users/"+usrId.String(), bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
-		mockUserDto.EXPECT().UpdateProfile(usrId, user).Return(errors.New("update error"))
+		req = mux.SetURLVars(req, map[string]string{"user_id": usrId.String()})
+		req = withActor(req, actor)
 
-		body, _ := json.Marshal(user)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.UpdateProfile)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("profile of another user", func(t *testing.T) {
+		otherId := uuid.New()
+		mockUserDto.EXPECT().UpdateProfile(actor, otherId, bReq).Return(policy.ErrForbidden)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("PUT", "/users/"+otherId.String(), bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		req = mux.SetURLVars(req, map[string]string{"user_id": otherId.String()})
+		req = withActor(req, actor)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.UpdateProfile)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("update profile error", func(t *testing.T) {
+		mockUserDto.EXPECT().UpdateProfile(actor, usrId, bReq).Return(errors.New("update error"))
+
+		body, _ := json.Marshal(bReq)
 		req, err := http.NewRequest("PUT", "/users/"+usrId.String(), bytes.NewBuffer(body))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		req = mux.SetURLVars(req, map[string]string{"user_id": usrId.String()})
+		req = withActor(req, actor)
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.UpdateProfile)
@@ -129,9 +172,12 @@ func TestHandler_GetUsers(t *testing.T) {
 
 	mockUserDto := NewMockuserDto(ctrl)
 	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
 	h := NewUserHandler(
 		mockUserDto,
 		rend,
+		validate,
 	)
 
 	t.Run("successful get users", func(t *testing.T) {
@@ -165,6 +211,87 @@ func TestHandler_GetUsers(t *testing.T) {
 		assert.Contains(t, rr.Body.String(), helper.SUCCESS_MESSSAGE)
 	})
 
+	t.Run("include deleted users", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?page=1&limit=10&include_deleted=true", nil)
+		assert.NoError(t, err)
+
+		mockUserDto.EXPECT().Get(users.RequestUsers{
+			Page:           1,
+			Limit:          10,
+			IncludeDeleted: true,
+		}).Return(&model.BaseModel{}, nil)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("search filters", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?search=ann&created_from=2024-07-01&created_to=2024-07-31&category=books,games&category=music", nil)
+		assert.NoError(t, err)
+
+		createdFrom := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		createdTo := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+		mockUserDto.EXPECT().Get(users.RequestUsers{
+			Search:      "ann",
+			CreatedFrom: &createdFrom,
+			CreatedTo:   &createdTo,
+			Categories:  []string{"books", "games", "music"},
+		}).Return(&model.BaseModel{}, nil)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid created range", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?created_from=yesterday", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("invalid include deleted", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?page=1&limit=10&include_deleted=maybe", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("invalid sort", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?page=1&limit=10&sort=password", nil)
+		assert.NoError(t, err)
+
+		mockUserDto.EXPECT().Get(users.RequestUsers{
+			Page:  1,
+			Limit: 10,
+			Sort:  "password",
+		}).Return(nil, query.ErrInvalidSort)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
 	t.Run("invalid user id", func(t *testing.T) {
 		req, err := http.NewRequest("GET", "/users?user_id=invalid-uuid", nil)
 		assert.NoError(t, err)
@@ -188,7 +315,7 @@ func TestHandler_GetUsers(t *testing.T) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
-		assert.Contains(t, rr.Body.String(), "page cant not nil")
+		assert.Contains(t, rr.Body.String(), "Invalid page")
 	})
 
 	t.Run("invalid limit parameter", func(t *testing.T) {
@@ -201,7 +328,50 @@ func TestHandler_GetUsers(t *testing.T) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
-		assert.Contains(t, rr.Body.String(), "limit cant not nil")
+		assert.Contains(t, rr.Body.String(), "Invalid limit")
+	})
+
+	t.Run("limit above the maximum", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?limit=1000", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("omitted page and limit", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users", nil)
+		assert.NoError(t, err)
+
+		mockUserDto.EXPECT().Get(users.RequestUsers{}).Return(&model.BaseModel{}, nil)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("cursor pagination", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users?limit=10&cursor=abc", nil)
+		assert.NoError(t, err)
+
+		mockUserDto.EXPECT().Get(users.RequestUsers{
+			Limit:  10,
+			Cursor: "abc",
+		}).Return(nil, query.ErrInvalidCursor)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetUsers)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
 	})
 
 	t.Run("get users error", func(t *testing.T) {
@@ -237,22 +407,34 @@ func TestHandler_SignUpByEmail(t *testing.T) {
 
 	mockUserDto := NewMockuserDto(ctrl)
 	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
 	h := NewUserHandler(
 		mockUserDto,
 		rend,
+		validate,
 	)
 
-	t.Run("successful sign up", func(t *testing.T) {
-		user := users.Users{
-			// isi field user sesuai dengan struct Users
-		}
+	bReq := users.RegisterRequest{
+		Email:    "user@example.com",
+		Username: "user",
+		Password: "Str0ng!Password",
+	}
+	user := users.Users{
+		Email:    bReq.Email,
+		Username: bReq.Username,
+		Role:     "User",
+		Password: bReq.Password,
+	}
 
+	t.Run("successful sign up", func(t *testing.T) {
 		newUUID := uuid.New()
 		mockUserDto.EXPECT().Register(user).Return(&newUUID, nil)
 
-		body, _ := json.Marshal(user)
+		body, _ := json.Marshal(bReq)
 		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer(body))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.SignUpByEmail)
@@ -266,6 +448,71 @@ func TestHandler_SignUpByEmail(t *testing.T) {
 	t.Run("decode error", func(t *testing.T) {
 		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer([]byte("invalid body")))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignUpByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("not json content type", func(t *testing.T) {
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "text/plain")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignUpByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		body := []byte(`{"email":"user@example.com","username":"user","password":"Str0ng!Password","is_admin":true}`)
+		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignUpByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"field":"is_admin"`)
+	})
+
+	t.Run("weak password", func(t *testing.T) {
+		weak := bReq
+		weak.Password = "password"
+
+		body, _ := json.Marshal(weak)
+		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignUpByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"code":"validation_failed"`)
+		assert.Contains(t, rr.Body.String(), `"field":"password"`)
+	})
+
+	t.Run("already registered", func(t *testing.T) {
+		mockUserDto.EXPECT().Register(user).Return(nil, users.ErrUserAlreadyRegistered)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.SignUpByEmail)
@@ -273,18 +520,16 @@ func TestHandler_SignUpByEmail(t *testing.T) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"code":"user_already_registered"`)
 	})
 
 	t.Run("register error", func(t *testing.T) {
-		user := users.Users{
-			// isi field user sesuai dengan struct Users
-		}
-
 		mockUserDto.EXPECT().Register(user).Return(nil, errors.New("register error"))
 
-		body, _ := json.Marshal(user)
+		body, _ := json.Marshal(bReq)
 		req, err := http.NewRequest("POST", "/signup", bytes.NewBuffer(body))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.SignUpByEmail)
@@ -292,6 +537,7 @@ func TestHandler_SignUpByEmail(t *testing.T) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "register error")
 	})
 }
 
@@ -301,25 +547,30 @@ func TestHandler_SignInByEmail(t *testing.T) {
 
 	mockUserDto := NewMockuserDto(ctrl)
 	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
 	h := NewUserHandler(
 		mockUserDto,
 		rend,
+		validate,
 	)
 
-	t.Run("successful sign in", func(t *testing.T) {
-		user := users.Users{
-			// isi field user sesuai dengan struct Users
-		}
+	user := users.UsersLogin{
+		Email:    "user@example.com",
+		Password: "Str0ng!Password",
+	}
 
+	t.Run("successful sign in", func(t *testing.T) {
 		expectedResponse := &users.LoginResponse{
 			// isi field sesuai dengan struct LoginResponse
 		}
 
-		mockUserDto.EXPECT().Login(user).Return(expectedResponse, nil)
+		mockUserDto.EXPECT().Login(user).Return(expectedResponse, nil, nil)
 
 		body, _ := json.Marshal(user)
 		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.SignInByEmail)
@@ -330,34 +581,967 @@ func TestHandler_SignInByEmail(t *testing.T) {
 		assert.Contains(t, rr.Body.String(), helper.SUCCESS_MESSSAGE)
 	})
 
+	t.Run("mfa challenge", func(t *testing.T) {
+		challenge := &mfa.ChallengeResponse{
+			MFARequired: true,
+			MFAToken:    "mfa-token",
+		}
+
+		mockUserDto.EXPECT().Login(user).Return(nil, challenge, nil)
+
+		body, _ := json.Marshal(user)
+		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "mfa-token")
+		assert.NotContains(t, rr.Body.String(), "access_token")
+	})
+
 	t.Run("decode error", func(t *testing.T) {
 		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer([]byte("invalid body")))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.SignInByEmail)
 
 		handler.ServeHTTP(rr, req)
 
-		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
 	})
 
-	t.Run("login error", func(t *testing.T) {
-		user := users.Users{
-			// isi field user sesuai dengan struct Users
-		}
+	t.Run("missing password", func(t *testing.T) {
+		body, _ := json.Marshal(users.UsersLogin{Email: user.Email})
+		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
 
-		mockUserDto.EXPECT().Login(user).Return(nil, errors.New("login error"))
+	t.Run("invalid credentials", func(t *testing.T) {
+		mockUserDto.EXPECT().Login(user).Return(nil, nil, users.ErrInvalidCredentials)
 
 		body, _ := json.Marshal(user)
 		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
 		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(h.SignInByEmail)
 
 		handler.ServeHTTP(rr, req)
 
-		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("email not verified", func(t *testing.T) {
+		mockUserDto.EXPECT().Login(user).Return(nil, nil, users.ErrEmailNotVerified)
+
+		body, _ := json.Marshal(user)
+		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		mockUserDto.EXPECT().Login(user).Return(nil, nil, &attempts.LockoutError{
+			Err:        attempts.ErrTooManyAttempts,
+			RetryAfter: 1500 * time.Millisecond,
+		})
+
+		body, _ := json.Marshal(user)
+		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("account locked", func(t *testing.T) {
+		mockUserDto.EXPECT().Login(user).Return(nil, nil, &attempts.LockoutError{
+			Err:        attempts.ErrAccountLocked,
+			RetryAfter: 15 * time.Minute,
+		})
+
+		body, _ := json.Marshal(user)
+		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusLocked, rr.Code)
+		assert.Equal(t, "900", rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("login error", func(t *testing.T) {
+		mockUserDto.EXPECT().Login(user).Return(nil, nil, errors.New("login error"))
+
+		body, _ := json.Marshal(user)
+		req, err := http.NewRequest("POST", "/signin", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInByEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestHandler_RefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	bReq := tokens.RefreshTokenRequest{
+		RefreshToken: "refresh-token",
+	}
+
+	t.Run("successful refresh", func(t *testing.T) {
+		mockUserDto.EXPECT().RefreshToken(bReq.RefreshToken).Return(&users.LoginResponse{}, nil)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/token/refresh", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.RefreshToken)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), helper.SUCCESS_MESSSAGE)
+	})
+
+	t.Run("missing refresh token", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/users/token/refresh", bytes.NewBuffer([]byte("{}")))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.RefreshToken)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("reused refresh token", func(t *testing.T) {
+		mockUserDto.EXPECT().RefreshToken(bReq.RefreshToken).Return(nil, tokens.ErrRefreshTokenReused)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/token/refresh", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.RefreshToken)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("refresh error", func(t *testing.T) {
+		mockUserDto.EXPECT().RefreshToken(bReq.RefreshToken).Return(nil, errors.New("refresh error"))
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/token/refresh", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.RefreshToken)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestHandler_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	payload := &jwt.Payload{
+		UserID:    uuid.New().String(),
+		SessionID: uuid.New().String(),
+	}
+
+	t.Run("successful logout", func(t *testing.T) {
+		mockUserDto.EXPECT().Logout(payload).Return(nil)
+
+		req, err := http.NewRequest("POST", "/users/logout", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetToken(req.Context(), payload))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.Logout)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/users/logout", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.Logout)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("logout error", func(t *testing.T) {
+		mockUserDto.EXPECT().Logout(payload).Return(errors.New("logout error"))
+
+		req, err := http.NewRequest("POST", "/users/logout", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetToken(req.Context(), payload))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.Logout)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestHandler_LogoutAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	t.Run("successful logout all", func(t *testing.T) {
+		usrId := uuid.New()
+		mockUserDto.EXPECT().LogoutAll(usrId).Return(nil)
+
+		req, err := http.NewRequest("POST", "/users/logout-all", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.LogoutAll)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("logout all error", func(t *testing.T) {
+		usrId := uuid.New()
+		mockUserDto.EXPECT().LogoutAll(usrId).Return(errors.New("logout error"))
+
+		req, err := http.NewRequest("POST", "/users/logout-all", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.LogoutAll)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestHandler_GetSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	t.Run("successful get sessions", func(t *testing.T) {
+		usrId := uuid.New()
+		payload := &jwt.Payload{
+			UserID:    usrId.String(),
+			SessionID: uuid.New().String(),
+		}
+
+		mockUserDto.EXPECT().GetSessions(usrId, payload.SessionID).Return(&[]sessions.Session{}, nil)
+
+		req, err := http.NewRequest("GET", "/users/me/sessions", nil)
+		assert.NoError(t, err)
+		ctx := middleware.SetUserID(req.Context(), usrId.String())
+		req = req.WithContext(middleware.SetToken(ctx, payload))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.GetSessions)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), helper.SUCCESS_MESSSAGE)
+	})
+}
+
+func TestHandler_DeleteSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	usrId := uuid.New()
+
+	t.Run("successful delete session", func(t *testing.T) {
+		sessionId := uuid.New()
+		mockUserDto.EXPECT().DeleteSession(usrId, sessionId).Return(nil)
+
+		req, err := http.NewRequest("DELETE", "/users/me/sessions/"+sessionId.String(), nil)
+		assert.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"session_id": sessionId.String()})
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DeleteSession)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid session id", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", "/users/me/sessions/invalid-uuid", nil)
+		assert.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"session_id": "invalid-uuid"})
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DeleteSession)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		sessionId := uuid.New()
+		mockUserDto.EXPECT().DeleteSession(usrId, sessionId).Return(sessions.ErrSessionNotFound)
+
+		req, err := http.NewRequest("DELETE", "/users/me/sessions/"+sessionId.String(), nil)
+		assert.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"session_id": sessionId.String()})
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DeleteSession)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestHandler_ResendVerification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	t.Run("successful resend", func(t *testing.T) {
+		mockUserDto.EXPECT().ResendVerification("user@example.com").Return(nil)
+
+		body, _ := json.Marshal(users.ResendVerificationRequest{Email: "user@example.com"})
+		req, err := http.NewRequest("POST", "/users/verify/resend", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ResendVerification)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		body, _ := json.Marshal(users.ResendVerificationRequest{Email: "not-an-email"})
+		req, err := http.NewRequest("POST", "/users/verify/resend", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ResendVerification)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_VerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	t.Run("successful verification", func(t *testing.T) {
+		mockUserDto.EXPECT().VerifyEmail("valid-token").Return(nil)
+
+		req, err := http.NewRequest("GET", "/users/verify?token=valid-token", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.VerifyEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/users/verify", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.VerifyEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("expired or used token", func(t *testing.T) {
+		mockUserDto.EXPECT().VerifyEmail("used-token").Return(users.ErrInvalidVerification)
+
+		req, err := http.NewRequest("GET", "/users/verify?token=used-token", nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.VerifyEmail)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_ForgotPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	t.Run("successful forgot password", func(t *testing.T) {
+		mockUserDto.EXPECT().ForgotPassword("user@example.com").Return(nil)
+
+		body, _ := json.Marshal(users.ForgotPasswordRequest{Email: "user@example.com"})
+		req, err := http.NewRequest("POST", "/users/password/forgot", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ForgotPassword)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		body, _ := json.Marshal(users.ForgotPasswordRequest{Email: "not-an-email"})
+		req, err := http.NewRequest("POST", "/users/password/forgot", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ForgotPassword)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_ResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	bReq := users.ResetPasswordRequest{
+		Token:    "reset-token",
+		Password: "N3w!Password",
+	}
+
+	t.Run("successful reset", func(t *testing.T) {
+		mockUserDto.EXPECT().ResetPassword(bReq.Token, bReq.Password).Return(nil)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/password/reset", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ResetPassword)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("weak password", func(t *testing.T) {
+		body, _ := json.Marshal(users.ResetPasswordRequest{Token: bReq.Token, Password: "weak"})
+		req, err := http.NewRequest("POST", "/users/password/reset", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ResetPassword)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("expired or used token", func(t *testing.T) {
+		mockUserDto.EXPECT().ResetPassword(bReq.Token, bReq.Password).Return(users.ErrInvalidPasswordReset)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/password/reset", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ResetPassword)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_SignInMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	bReq := mfa.VerifyRequest{
+		MFAToken: "mfa-token",
+		Code:     "123456",
+	}
+
+	t.Run("successful mfa sign in", func(t *testing.T) {
+		mockUserDto.EXPECT().VerifyMFA(bReq).Return(&users.LoginResponse{AccessToken: "access-token"}, nil)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/signin/mfa", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "access-token")
+	})
+
+	t.Run("missing code", func(t *testing.T) {
+		body, _ := json.Marshal(mfa.VerifyRequest{MFAToken: bReq.MFAToken})
+		req, err := http.NewRequest("POST", "/users/signin/mfa", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockUserDto.EXPECT().VerifyMFA(bReq).Return(nil, mfa.ErrInvalidMFACode)
+
+		body, _ := json.Marshal(bReq)
+		req, err := http.NewRequest("POST", "/users/signin/mfa", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SignInMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestHandler_SetupMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	usrId := uuid.New()
+
+	t.Run("successful setup", func(t *testing.T) {
+		mockUserDto.EXPECT().SetupMFA(usrId).Return(&mfa.SetupResponse{
+			Secret:        "SECRET",
+			OtpauthURI:    "otpauth://totp/Shopifun:user@example.com?secret=SECRET",
+			RecoveryCodes: []string{"abcde-fghij"},
+		}, nil)
+
+		req, err := http.NewRequest("POST", "/users/me/2fa/setup", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SetupMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "otpauth://totp/")
+		assert.Contains(t, rr.Body.String(), "abcde-fghij")
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		mockUserDto.EXPECT().SetupMFA(usrId).Return(nil, mfa.ErrMFAAlreadyEnabled)
+
+		req, err := http.NewRequest("POST", "/users/me/2fa/setup", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.SetupMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+}
+
+func TestHandler_ConfirmMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	usrId := uuid.New()
+
+	t.Run("successful confirm", func(t *testing.T) {
+		mockUserDto.EXPECT().ConfirmMFA(usrId, "123456").Return(nil)
+
+		body, _ := json.Marshal(mfa.CodeRequest{Code: "123456"})
+		req, err := http.NewRequest("POST", "/users/me/2fa/confirm", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ConfirmMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockUserDto.EXPECT().ConfirmMFA(usrId, "000000").Return(mfa.ErrInvalidMFACode)
+
+		body, _ := json.Marshal(mfa.CodeRequest{Code: "000000"})
+		req, err := http.NewRequest("POST", "/users/me/2fa/confirm", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ConfirmMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_DisableMFA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	usrId := uuid.New()
+
+	t.Run("successful disable", func(t *testing.T) {
+		mockUserDto.EXPECT().DisableMFA(usrId, "abcde-fghij").Return(nil)
+
+		body, _ := json.Marshal(mfa.CodeRequest{Code: "abcde-fghij"})
+		req, err := http.NewRequest("POST", "/users/me/2fa/disable", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DisableMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		mockUserDto.EXPECT().DisableMFA(usrId, "123456").Return(mfa.ErrMFANotEnabled)
+
+		body, _ := json.Marshal(mfa.CodeRequest{Code: "123456"})
+		req, err := http.NewRequest("POST", "/users/me/2fa/disable", bytes.NewBuffer(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DisableMFA)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandler_ExportAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	usrId := uuid.New()
+
+	t.Run("successful export", func(t *testing.T) {
+		mockUserDto.EXPECT().Export(usrId).Return(&users.Export{
+			Profile:  &users.Users{Id: usrId, Email: "test@example.com"},
+			Sessions: []sessions.Session{},
+		}, nil)
+
+		req, err := http.NewRequest("GET", "/users/me/export", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ExportAccount)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+		assert.Contains(t, rr.Body.String(), "test@example.com")
+	})
+
+	t.Run("order service error", func(t *testing.T) {
+		mockUserDto.EXPECT().Export(usrId).Return(nil, errors.New("failed to fetch orders"))
+
+		req, err := http.NewRequest("GET", "/users/me/export", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.ExportAccount)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestHandler_DeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserDto := NewMockuserDto(ctrl)
+	rend := renderer.New()
+	validate := validator.New()
+	helper.RegisterValidations(validate)
+	h := NewUserHandler(
+		mockUserDto,
+		rend,
+		validate,
+	)
+
+	usrId := uuid.New()
+
+	t.Run("successful deletion request", func(t *testing.T) {
+		mockUserDto.EXPECT().RequestDeletion(usrId).Return(&users.DeletionResponse{AnonymizeAfter: time.Now().Add(time.Hour)}, nil)
+
+		req, err := http.NewRequest("DELETE", "/users/me", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DeleteAccount)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "anonymize_after")
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		mockUserDto.EXPECT().RequestDeletion(usrId).Return(nil, users.ErrUserNotFound)
+
+		req, err := http.NewRequest("DELETE", "/users/me", nil)
+		assert.NoError(t, err)
+		req = req.WithContext(middleware.SetUserID(req.Context(), usrId.String()))
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(h.DeleteAccount)
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
 	})
 }
@@ -0,0 +1,24 @@
+package wellknown
+
+import (
+	"net/http"
+	"user-service/src/util/helper/jwt"
+
+	"github.com/thedevsaddam/renderer"
+)
+
+type Handler struct {
+	render *renderer.Render
+}
+
+func NewHandler(r *renderer.Render) *Handler {
+	return &Handler{render: r}
+}
+
+// JWKS publishes the public keys our tokens are signed with, so other services
+// can verify them without sharing a secret. It is served as a plain JSON Web
+// Key Set instead of the usual response envelope.
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	h.render.JSON(w, http.StatusOK, jwt.JWKS())
+}
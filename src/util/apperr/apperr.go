@@ -0,0 +1,119 @@
+// Package apperr defines the errors the stores and usecases return for
+// expected failures. Each error has a kind, which decides the HTTP status,
+// and a stable code clients can rely on instead of the message.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindLocked
+	KindTooManyRequests
+	KindUnavailable
+	KindUnsupportedMediaType
+	KindTooLarge
+)
+
+const CodeInternal = "internal_error"
+
+// Error is an expected failure. Declared once as a package variable it works
+// as a sentinel for errors.Is, like the errors.New values it replaces.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+// FieldError describes why a single field of a request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Detail is the machine-readable part of an error response.
+type Detail struct {
+	Code   string       `json:"code"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+func New(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func Validation(code string, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code string, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Detail() *Detail {
+	return &Detail{Code: e.Code, Fields: e.Fields}
+}
+
+// StatusCode returns the HTTP status for the kind of the error.
+func (e *Error) StatusCode() int {
+	switch e.Kind {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindLocked:
+		return http.StatusLocked
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// From returns the *Error in the chain of err, or nil for unexpected errors.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	errNotFound := NotFound("thing_not_found", "thing not found")
+	wrapped := fmt.Errorf("failed to load thing: %w", errNotFound)
+
+	assert.ErrorIs(t, wrapped, errNotFound)
+	assert.Equal(t, errNotFound, From(wrapped))
+	assert.Equal(t, http.StatusNotFound, From(wrapped).StatusCode())
+	assert.Equal(t, "thing not found", errNotFound.Error())
+
+	assert.Nil(t, From(errors.New("connection reset")))
+	assert.Nil(t, From(nil))
+}
+
+func TestError_StatusCode(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want int
+	}{
+		{Validation("invalid", "invalid", FieldError{Field: "email"}), http.StatusBadRequest},
+		{Unauthorized("unauthorized", "unauthorized"), http.StatusUnauthorized},
+		{Forbidden("forbidden", "forbidden"), http.StatusForbidden},
+		{NotFound("not_found", "not found"), http.StatusNotFound},
+		{Conflict("conflict", "conflict"), http.StatusConflict},
+		{New(KindLocked, "locked", "locked"), http.StatusLocked},
+		{New(KindTooManyRequests, "throttled", "throttled"), http.StatusTooManyRequests},
+		{New(KindUnsupportedMediaType, "unsupported_media_type", "unsupported"), http.StatusUnsupportedMediaType},
+		{New(KindTooLarge, "body_too_large", "too large"), http.StatusRequestEntityTooLarge},
+		{New(KindInternal, CodeInternal, "internal"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Code, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.err.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/payment"
+	"user-service/src/util/repository/model/products"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Service Order
+	createOrderUrl = "https://8c5c-182-253-51-145.ngrok-free.app/order/create"
+	cancelOrderUrl = "https://localhost:9993/order/status/update"
+
+	// Service Product
+	getProductUrl    = "https://362c-182-253-51-145.ngrok-free.app/api/products"
+	updateProductUrl = "https://362c-182-253-51-145.ngrok-free.app/api/product-stocks"
+
+	// Service Payment
+	paymentUrl = "https://51e6-182-253-51-145.ngrok-free.app/api/payments"
+)
+
+// StatusError is an unexpected response of another service. The body is kept
+// so the handler can pass the error of the service on to the client.
+type StatusError struct {
+	Service    string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s service responded with status %d", e.Service, e.StatusCode)
+}
+
+// Rejected reports whether the service turned the request down. A 5xx may
+// come after the request was carried out, so only a 4xx is a definite no.
+func (e *StatusError) Rejected() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// CheckoutClient calls the product, order and payment services for the
+// steps of a checkout.
+type CheckoutClient struct {
+	netClient *http.Client
+	serverKey string
+}
+
+func NewCheckoutClient(netClient *http.Client, serverKey string) *CheckoutClient {
+	return &CheckoutClient{
+		netClient: netClient,
+		serverKey: serverKey,
+	}
+}
+
+// GetProducts reads the products, the request is aborted when ctx is done.
+func (c *CheckoutClient) GetProducts(ctx context.Context, productIDs []string, limit string) ([]products.Product, error) {
+	productChannel := make(chan Response)
+	netClient := NetClientRequest{
+		NetClient:  c.netClient,
+		Context:    ctx,
+		RequestUrl: getProductUrl,
+		QueryParam: []QueryParams{
+			{Param: "product_ids", Value: strings.Join(productIDs, ",")},
+			{Param: "limit", Value: limit},
+		},
+	}
+
+	netClient.Get(nil, productChannel)
+	response := <-productChannel
+	if err := checkResponse("product", response, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var dataProducts products.DataProduct
+	if err := json.Unmarshal(response.Res, &dataProducts); err != nil {
+		return nil, fmt.Errorf("failed to decode products: %w", err)
+	}
+
+	return dataProducts.Data.Items, nil
+}
+
+// CreateOrder returns the id of the new order.
+func (c *CheckoutClient) CreateOrder(bReq order.CreateOrderRequest) (string, error) {
+	orderChannel := make(chan Response)
+	netClient := NetClientRequest{
+		NetClient:  c.netClient,
+		RequestUrl: createOrderUrl,
+	}
+
+	netClient.Post(bReq, orderChannel)
+	response := <-orderChannel
+	if err := checkResponse("order", response, http.StatusCreated); err != nil {
+		return "", err
+	}
+
+	var orderID string
+	if err := json.Unmarshal(response.Res, &orderID); err != nil {
+		return "", fmt.Errorf("failed to decode order id: %w", err)
+	}
+
+	return orderID, nil
+}
+
+func (c *CheckoutClient) CancelOrder(userID uuid.UUID, orderID string) error {
+	oid, err := uuid.Parse(orderID)
+	if err != nil {
+		return fmt.Errorf("invalid order id %q: %w", orderID, err)
+	}
+
+	cancelChannel := make(chan Response)
+	Put(c.netClient, cancelOrderUrl, order.UpdateStatus{
+		UserID:  userID,
+		OrderID: oid,
+		Status:  checkout.OrderCancelled,
+	}, cancelChannel)
+
+	return checkResponse("order", <-cancelChannel, http.StatusOK)
+}
+
+// SetStock overwrites the stock of the products, the request is aborted when
+// ctx is done.
+func (c *CheckoutClient) SetStock(ctx context.Context, updates []order.UpdateQtyRequest) error {
+	stockChannel := make(chan Response)
+	netClient := NetClientRequest{
+		NetClient:  c.netClient,
+		Context:    ctx,
+		RequestUrl: updateProductUrl,
+	}
+
+	netClient.Patch(updates, stockChannel)
+	return checkResponse("product", <-stockChannel, http.StatusOK)
+}
+
+// CreatePayment opens a bank transfer for the order.
+func (c *CheckoutClient) CreatePayment(bReq order.CreateOrderRequest) (*payment.CreatePaymentResponse, error) {
+	bReq.BasicAuthHeader = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.serverKey+":"))
+	bReq.PaymentType = "bank_transfer"
+
+	paymentChannel := make(chan Response)
+	netClient := NetClientRequest{
+		NetClient:  c.netClient,
+		RequestUrl: paymentUrl,
+	}
+
+	netClient.Post(bReq, paymentChannel)
+	response := <-paymentChannel
+	if err := checkResponse("payment", response, http.StatusCreated); err != nil {
+		return nil, err
+	}
+
+	var paymentResponse payment.CreatePaymentResponse
+	if err := json.Unmarshal(response.Res, &paymentResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode payment: %w", err)
+	}
+
+	return &paymentResponse, nil
+}
+
+func checkResponse(service string, response Response, expected int) error {
+	if response.Err != nil {
+		return fmt.Errorf("failed to call %s service: %w", service, response.Err)
+	}
+
+	if response.StatusCode != expected {
+		return &StatusError{Service: service, StatusCode: response.StatusCode, Body: response.Res}
+	}
+
+	return nil
+}
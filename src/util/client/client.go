@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -13,6 +14,10 @@ type NetClientRequest struct {
 	NetClient  *http.Client
 	RequestUrl string
 	QueryParam []QueryParams
+
+	// Context bounds the request on top of the client timeout, it is
+	// optional
+	Context context.Context
 }
 
 type QueryParams struct {
@@ -30,6 +35,14 @@ var NetClient = &http.Client{
 	Timeout: time.Second * 10,
 }
 
+func (ncr *NetClientRequest) context() context.Context {
+	if ncr.Context == nil {
+		return context.Background()
+	}
+
+	return ncr.Context
+}
+
 func (ncr *NetClientRequest) AddQueryParam(param, value string) {
 	ncr.QueryParam = append(ncr.QueryParam, QueryParams{Param: param, Value: value})
 }
@@ -57,7 +70,7 @@ func (ncr *NetClientRequest) Get(load interface{}, channel chan Response) {
 			urlObj.RawQuery = query.Encode()
 		}
 
-		req, err := http.NewRequest(http.MethodGet, urlObj.String(), bytes.NewBuffer(marshalled))
+		req, err := http.NewRequestWithContext(ncr.context(), http.MethodGet, urlObj.String(), bytes.NewBuffer(marshalled))
 		if err != nil {
 			channel <- Response{Err: err}
 			return
@@ -106,7 +119,7 @@ func (ncr *NetClientRequest) Post(load interface{}, channel chan Response) {
 		}
 
 		// Create a new POST request
-		req, err := http.NewRequest(http.MethodPost, urlObj.String(), bytes.NewBuffer(marshalled))
+		req, err := http.NewRequestWithContext(ncr.context(), http.MethodPost, urlObj.String(), bytes.NewBuffer(marshalled))
 		if err != nil {
 			channel <- Response{Err: err}
 			return
@@ -187,7 +200,7 @@ func (ncr *NetClientRequest) Delete(load interface{}, channel chan Response) {
 		}
 
 		// Create a new POST request
-		req, err := http.NewRequest(http.MethodDelete, urlObj.String(), bytes.NewBuffer(marshalled))
+		req, err := http.NewRequestWithContext(ncr.context(), http.MethodDelete, urlObj.String(), bytes.NewBuffer(marshalled))
 		if err != nil {
 			channel <- Response{Err: err}
 			return
@@ -237,7 +250,7 @@ func (ncr *NetClientRequest) Patch(load interface{}, channel chan Response) {
 		}
 
 		// Create a new POST request
-		req, err := http.NewRequest(http.MethodPatch, urlObj.String(), bytes.NewBuffer(marshalled))
+		req, err := http.NewRequestWithContext(ncr.context(), http.MethodPatch, urlObj.String(), bytes.NewBuffer(marshalled))
 		if err != nil {
 			channel <- Response{Err: err}
 			return
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"user-service/src/util/repository/model/order"
+
+	"github.com/google/uuid"
+)
+
+// OrderClient reads from the order service on behalf of other usecases.
+type OrderClient struct {
+	netClient *http.Client
+	baseURL   string
+}
+
+func NewOrderClient(netClient *http.Client, baseURL string) *OrderClient {
+	return &OrderClient{
+		netClient: netClient,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// GetOrders returns the order history of the user.
+func (c *OrderClient) GetOrders(userID uuid.UUID) ([]order.Order, error) {
+	ordersChannel := make(chan Response)
+	netClient := NetClientRequest{
+		NetClient:  c.netClient,
+		RequestUrl: c.baseURL + "/order/user/" + userID.String(),
+	}
+
+	netClient.Get(nil, ordersChannel)
+	response := <-ordersChannel
+	if response.Err != nil {
+		return nil, fmt.Errorf("failed to fetch orders: %w", response.Err)
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return []order.Order{}, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch orders: order service responded with status %d", response.StatusCode)
+	}
+
+	var orders []order.Order
+	if err := json.Unmarshal(response.Res, &orders); err != nil {
+		return nil, fmt.Errorf("failed to decode orders: %w", err)
+	}
+
+	if orders == nil {
+		orders = []order.Order{}
+	}
+
+	return orders, nil
+}
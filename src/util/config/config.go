@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -22,6 +24,37 @@ type Config struct {
 	ClientKey    string
 	ServerKey    string
 	MerchantID   string
+
+	// JWT signing, see jwt.LoadKeys. JWTEphemeralKey signs with a key
+	// generated at startup instead, for local development only
+	JWTSigningKeyPath       string
+	JWTSigningKeyID         string
+	JWTVerificationKeyPaths map[string]string
+	JWTEphemeralKey         bool
+
+	// AppURL is the public URL of this service, used in links sent by mail
+	AppURL string
+
+	// PasswordResetURL is the frontend page with the new password form,
+	// reset mails link to it with the token as query parameter
+	PasswordResetURL string
+
+	// MailDriver is either "smtp" or "log"
+	MailDriver    string
+	MailFrom      string
+	MailOutputDir string
+	SMTPHost      string
+	SMTPPort      int
+	SMTPUsername  string
+	SMTPPassword  string
+
+	// TrustedProxies are the proxies, as IPs or CIDR ranges, whose
+	// X-Forwarded-For header tells the client address
+	TrustedProxies []string
+
+	// OrderServiceURL is the base URL of the order service, used to export
+	// the order history of a user
+	OrderServiceURL string
 }
 
 func LoadConfig() (*Config, error) {
@@ -49,11 +82,100 @@ func LoadConfig() (*Config, error) {
 		ClientKey:   viper.GetString("CLIENT_KEY"),
 		ServerKey:   viper.GetString("SERVER_KEY"),
 		MerchantID:  viper.GetString("MERCHANT_ID"),
+
+		JWTSigningKeyPath:       viper.GetString("JWT_SIGNING_KEY_PATH"),
+		JWTSigningKeyID:         viper.GetString("JWT_SIGNING_KEY_ID"),
+		JWTVerificationKeyPaths: parseKeyPaths(viper.GetString("JWT_VERIFICATION_KEY_PATHS")),
+		JWTEphemeralKey:         viper.GetBool("JWT_EPHEMERAL_KEY"),
+
+		AppURL:           viper.GetString("APP_URL"),
+		PasswordResetURL: viper.GetString("PASSWORD_RESET_URL"),
+
+		MailDriver:    viper.GetString("MAIL_DRIVER"),
+		MailFrom:      viper.GetString("MAIL_FROM"),
+		MailOutputDir: viper.GetString("MAIL_OUTPUT_DIR"),
+		SMTPHost:      viper.GetString("SMTP_HOST"),
+		SMTPPort:      viper.GetInt("SMTP_PORT"),
+		SMTPUsername:  viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:  viper.GetString("SMTP_PASSWORD"),
+
+		TrustedProxies: parseList(viper.GetString("TRUSTED_PROXIES")),
+
+		OrderServiceURL: viper.GetString("ORDER_SERVICE_URL"),
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
+// validate rejects settings that have no usable default, so a missing one
+// stops the service at startup instead of failing requests later.
+func (c *Config) validate() error {
+	// Every replica has to sign with the same key, and a restart must not
+	// sign everybody out
+	if c.JWTSigningKeyPath == "" && !c.JWTEphemeralKey {
+		return fmt.Errorf("JWT_SIGNING_KEY_PATH is not set, set JWT_EPHEMERAL_KEY=true to sign with a throwaway key in development")
+	}
+
+	// Verification mails link to it, without it they carry relative links
+	if err := validateURL("APP_URL", c.AppURL); err != nil {
+		return err
+	}
+
+	if err := validateURL("ORDER_SERVICE_URL", c.OrderServiceURL); err != nil {
+		return err
+	}
+
+	if err := validateURL("PASSWORD_RESET_URL", c.PasswordResetURL); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateURL(name string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is not set", name)
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL, got %q", name, value)
+	}
+
+	return nil
+}
+
+// parseKeyPaths parses a comma separated list of kid=path pairs, e.g.
+// "2024-06=/etc/keys/2024-06.pem,2024-05=/etc/keys/2024-05.pem".
+func parseKeyPaths(value string) map[string]string {
+	keyPaths := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		kid, path, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || kid == "" || path == "" {
+			continue
+		}
+		keyPaths[kid] = path
+	}
+
+	return keyPaths
+}
+
+// parseList parses a comma separated list, skipping empty entries.
+func parseList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
 func WriteTimeout() time.Duration {
 	return 10 * time.Second
 }
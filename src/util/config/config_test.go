@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "app url not set", modify: func(c *Config) { c.AppURL = "" }, wantErr: "APP_URL is not set"},
+		{name: "relative app url", modify: func(c *Config) { c.AppURL = "user-service" }, wantErr: "APP_URL must be an absolute http(s) URL"},
+		{name: "order service not set", modify: func(c *Config) { c.OrderServiceURL = "" }, wantErr: "ORDER_SERVICE_URL is not set"},
+		{name: "relative order service", modify: func(c *Config) { c.OrderServiceURL = "order-service/api" }, wantErr: "ORDER_SERVICE_URL must be an absolute http(s) URL"},
+		{name: "other scheme", modify: func(c *Config) { c.OrderServiceURL = "ftp://order-service" }, wantErr: "ORDER_SERVICE_URL must be an absolute http(s) URL"},
+		{name: "signing key not set", modify: func(c *Config) { c.JWTSigningKeyPath = "" }, wantErr: "JWT_SIGNING_KEY_PATH is not set"},
+		{name: "ephemeral key in development", modify: func(c *Config) { c.JWTSigningKeyPath = ""; c.JWTEphemeralKey = true }},
+		{name: "password reset page not set", modify: func(c *Config) { c.PasswordResetURL = "" }, wantErr: "PASSWORD_RESET_URL is not set"},
+		{name: "relative password reset page", modify: func(c *Config) { c.PasswordResetURL = "/reset-password" }, wantErr: "PASSWORD_RESET_URL must be an absolute http(s) URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				JWTSigningKeyPath: "/etc/keys/signing.pem",
+				AppURL:            "https://api.example.com",
+				OrderServiceURL:   "http://order-service:9993",
+				PasswordResetURL:  "https://shop.example.com/reset-password",
+			}
+			tt.modify(config)
+
+			err := config.validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"user-service/src/util/apperr"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrUnauthorized is returned when a route behind authentication finds no
+// valid user in the request context.
+var ErrUnauthorized = apperr.Unauthorized("unauthorized", "Unauthorized")
+
+// InvalidParam reports a malformed path or query parameter, e.g. "user_id"
+// gets the code invalid_user_id.
+func InvalidParam(name string) error {
+	return apperr.Validation("invalid_"+name, "Invalid "+strings.ReplaceAll(name, "_", " "))
+}
+
+// InvalidBody reports a request body that cannot be decoded.
+func InvalidBody(err error) error {
+	return apperr.Validation("invalid_body", "Invalid request body: "+err.Error())
+}
+
+// ValidationError turns the error of validator.Struct into a validation error
+// listing every invalid field by its JSON name.
+func ValidationError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return apperr.Validation("validation_failed", err.Error())
+	}
+
+	fields := make([]apperr.FieldError, len(validationErrs))
+	for i, fieldErr := range validationErrs {
+		fields[i] = apperr.FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErr.Field() + " " + describeRule(fieldErr),
+		}
+	}
+
+	return apperr.Validation("validation_failed", "Request validation failed", fields...)
+}
+
+func describeRule(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s long", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s long", fieldErr.Param())
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fieldErr.Param()), ", ")
+	case "password":
+		return fmt.Sprintf("must be %d-%d characters with an upper case letter, a lower case letter, a digit and a symbol", passwordMinLength, passwordMaxLength)
+	default:
+		return "is invalid"
+	}
+}
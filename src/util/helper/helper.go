@@ -3,6 +3,7 @@ package helper
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -74,3 +75,75 @@ func LoggerMiddleware() mux.MiddlewareFunc {
 		})
 	}
 }
+
+// trustedProxies are the networks of the proxies whose X-Forwarded-For is
+// believed. It is set once at startup by SetTrustedProxies.
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the proxies, as IP addresses or CIDR ranges, allowed
+// to tell the client address through X-Forwarded-For.
+func SetTrustedProxies(proxies []string) error {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+
+	trustedProxies = networks
+	return nil
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is only
+// followed while the hops are trusted proxies, walking it from the nearest
+// hop, so a client cannot pick its own address by sending the header.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		host = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
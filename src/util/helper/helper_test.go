@@ -0,0 +1,47 @@
+package helper
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	assert.NoError(t, SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}))
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{name: "no proxy", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "header from an untrusted client", remoteAddr: "203.0.113.7:5000", forwarded: "198.51.100.1", want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:5000", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "spoofed first hop", remoteAddr: "10.1.2.3:5000", forwarded: "1.2.3.4, 198.51.100.1", want: "198.51.100.1"},
+		{name: "chain of trusted proxies", remoteAddr: "10.1.2.3:5000", forwarded: "198.51.100.1, 192.168.1.1, 10.9.9.9", want: "198.51.100.1"},
+		{name: "trusted proxy without header", remoteAddr: "10.1.2.3:5000", want: "10.1.2.3"},
+		{name: "garbage hop", remoteAddr: "10.1.2.3:5000", forwarded: "not-an-ip", want: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			assert.Equal(t, tt.want, ClientIP(req))
+		})
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	assert.NoError(t, SetTrustedProxies([]string{"::1", "fd00::/8", "127.0.0.1"}))
+	assert.Error(t, SetTrustedProxies([]string{"proxy.internal"}))
+}
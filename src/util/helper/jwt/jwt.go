@@ -7,25 +7,27 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var signedKey = []byte("test")
+func CreateRefreshToken(email string, userID string, role string, sessionID string, tokenExpiry time.Duration) (string, *Payload, error) {
+	return createToken(email, userID, role, sessionID, TokenTypeRefresh, tokenExpiry)
+}
 
-func CreateRefreshToken(email string, userID string, role string, tokenExpiry time.Duration) (string, *Payload, error) {
-	return createToken(email, userID, role, tokenExpiry)
+func CreateAccessToken(email string, userID string, role string, sessionID string, tokenExpiry time.Duration) (string, *Payload, error) {
+	return createToken(email, userID, role, sessionID, TokenTypeAccess, tokenExpiry)
 }
 
-func CreateAccessToken(email string, userID string, role string, tokenExpiry time.Duration) (string, *Payload, error) {
-	return createToken(email, userID, role, tokenExpiry)
+// CreateMFAToken signs the challenge token of a login that still waits for its
+// second factor. There is no session yet, it is started once the challenge is
+// passed.
+func CreateMFAToken(email string, userID string, role string, tokenExpiry time.Duration) (string, *Payload, error) {
+	return createToken(email, userID, role, "", TokenTypeMFA, tokenExpiry)
 }
 
-func createToken(email string, userID string, role string, tokenExpiry time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(email, userID, role, tokenExpiry)
+func createToken(email string, userID string, role string, sessionID string, tokenType string, tokenExpiry time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(email, userID, role, sessionID, tokenType, tokenExpiry)
 	if err != nil {
 		return "", nil, err // Added signed with error handling
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-
-	// Create token with signed
-	tokenString, err := token.SignedString(signedKey)
+	tokenString, err := signToken(payload)
 	if err != nil {
 		return "", nil, err
 	}
@@ -33,11 +35,43 @@ func createToken(email string, userID string, role string, tokenExpiry time.Dura
 	return tokenString, payload, nil
 }
 
-func VerifyToken(tokenString string) (*Payload, error) {
+func signToken(payload *Payload) (string, error) {
+	set, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(set.signingMethod, payload)
+	token.Header["kid"] = set.signingKeyID
+
+	// Create token with signed
+	return token.SignedString(set.signingKey)
+}
+
+// VerifyAccessToken only accepts access tokens, refresh tokens are rejected.
+func VerifyAccessToken(tokenString string) (*Payload, error) {
+	return verifyToken(tokenString, TokenTypeAccess)
+}
+
+// VerifyRefreshToken only accepts refresh tokens, access tokens are rejected.
+func VerifyRefreshToken(tokenString string) (*Payload, error) {
+	return verifyToken(tokenString, TokenTypeRefresh)
+}
+
+// VerifyMFAToken only accepts MFA challenge tokens.
+func VerifyMFAToken(tokenString string) (*Payload, error) {
+	return verifyToken(tokenString, TokenTypeMFA)
+}
+
+func verifyToken(tokenString string, tokenType string) (*Payload, error) {
 	// Parse token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return signedKey, nil
-	})
+	payload := &Payload{}
+	token, err := jwt.ParseWithClaims(tokenString, payload, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(tokenAudience[tokenType]),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -46,45 +80,24 @@ func VerifyToken(tokenString string) (*Payload, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, fmt.Errorf("invalid token")
+	if payload.TokenType != tokenType {
+		return nil, fmt.Errorf("invalid token type, expected %s token", tokenType)
 	}
 
-	emailInterface, ok := claims["Email"]
-	if !ok {
+	if payload.Email == "" {
 		return nil, fmt.Errorf("email claim not found in token")
 	}
 
-	email, ok := emailInterface.(string)
-	if !ok {
-		return nil, fmt.Errorf("email claim is not a string")
-	}
-
-	userIdInterface, ok := claims["UserID"]
-	if !ok {
+	if payload.UserID == "" {
 		return nil, fmt.Errorf("user id claim not found in token")
 	}
 
-	userId, ok := userIdInterface.(string)
-	if !ok {
-		return nil, fmt.Errorf("user id claim is not a string")
-	}
-
-	roleInterface, ok := claims["Role"]
-	if !ok {
+	if payload.Role == "" {
 		return nil, fmt.Errorf("role claim not found in token")
 	}
 
-	role, ok := roleInterface.(string)
-	if !ok {
-		return nil, fmt.Errorf("role claim is not a string")
-	}
-
-	payload := &Payload{
-		Email:  email,
-		UserID: userId,
-		Role:   role,
+	if payload.SessionID == "" && tokenType != TokenTypeMFA {
+		return nil, fmt.Errorf("session id claim not found in token")
 	}
 
 	return payload, nil
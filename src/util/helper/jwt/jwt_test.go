@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	if err := GenerateKeys(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	t.Run("accepts access token", func(t *testing.T) {
+		tokenString, payload, err := CreateAccessToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+		assert.NoError(t, err)
+
+		result, err := VerifyAccessToken(tokenString)
+		assert.NoError(t, err)
+		assert.Equal(t, payload.ID, result.ID)
+		assert.Equal(t, "user@example.com", result.Email)
+		assert.Equal(t, "user-id", result.UserID)
+		assert.Equal(t, "User", result.Role)
+		assert.Equal(t, "session-id", result.SessionID)
+		assert.Equal(t, TokenTypeAccess, result.TokenType)
+	})
+
+	t.Run("rejects refresh token", func(t *testing.T) {
+		tokenString, _, err := CreateRefreshToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects expired token", func(t *testing.T) {
+		tokenString, _, err := CreateAccessToken("user@example.com", "user-id", "User", "session-id", -time.Minute)
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+
+	t.Run("rejects token without type", func(t *testing.T) {
+		payload, err := NewPayload("user@example.com", "user-id", "User", "session-id", "", time.Minute)
+		assert.NoError(t, err)
+		payload.Audience = jwt.ClaimStrings{tokenAudience[TokenTypeAccess]}
+
+		tokenString, err := signToken(payload)
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects refresh token relabelled as access token", func(t *testing.T) {
+		payload, err := NewPayload("user@example.com", "user-id", "User", "session-id", TokenTypeAccess, time.Minute)
+		assert.NoError(t, err)
+		payload.Audience = jwt.ClaimStrings{tokenAudience[TokenTypeRefresh]}
+
+		tokenString, err := signToken(payload)
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+	})
+
+	t.Run("rejects tampered token", func(t *testing.T) {
+		tokenString, _, err := CreateAccessToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString + "x")
+		assert.Error(t, err)
+	})
+}
+
+func TestVerifyRefreshToken(t *testing.T) {
+	t.Run("accepts refresh token", func(t *testing.T) {
+		tokenString, payload, err := CreateRefreshToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+		assert.NoError(t, err)
+
+		result, err := VerifyRefreshToken(tokenString)
+		assert.NoError(t, err)
+		assert.Equal(t, payload.ID, result.ID)
+		assert.Equal(t, TokenTypeRefresh, result.TokenType)
+	})
+
+	t.Run("rejects access token", func(t *testing.T) {
+		tokenString, _, err := CreateAccessToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+		assert.NoError(t, err)
+
+		_, err = VerifyRefreshToken(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects access token relabelled as refresh token", func(t *testing.T) {
+		payload, err := NewPayload("user@example.com", "user-id", "User", "session-id", TokenTypeRefresh, time.Minute)
+		assert.NoError(t, err)
+		payload.Audience = jwt.ClaimStrings{tokenAudience[TokenTypeAccess]}
+
+		tokenString, err := signToken(payload)
+		assert.NoError(t, err)
+
+		_, err = VerifyRefreshToken(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+	})
+}
+
+func TestVerifyMFAToken(t *testing.T) {
+	t.Run("accepts mfa token without session", func(t *testing.T) {
+		tokenString, _, err := CreateMFAToken("user@example.com", "user-id", "Seller", time.Minute)
+		assert.NoError(t, err)
+
+		result, err := VerifyMFAToken(tokenString)
+		assert.NoError(t, err)
+		assert.Equal(t, TokenTypeMFA, result.TokenType)
+		assert.Empty(t, result.SessionID)
+	})
+
+	t.Run("mfa token is not an access token", func(t *testing.T) {
+		tokenString, _, err := CreateMFAToken("user@example.com", "user-id", "Seller", time.Minute)
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	defer GenerateKeys()
+
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	assert.NoError(t, setKeys(oldKey, "old", nil))
+	tokenString, _, err := CreateAccessToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+	assert.NoError(t, err)
+
+	t.Run("accepts token of previous key during rotation", func(t *testing.T) {
+		assert.NoError(t, setKeys(newKey, "new", map[string]crypto.PublicKey{"old": oldKey.Public()}))
+
+		_, err := VerifyAccessToken(tokenString)
+		assert.NoError(t, err)
+
+		newTokenString, _, err := CreateAccessToken("user@example.com", "user-id", "User", "session-id", time.Minute)
+		assert.NoError(t, err)
+
+		token, _, err := jwt.NewParser().ParseUnverified(newTokenString, &Payload{})
+		assert.NoError(t, err)
+		assert.Equal(t, "new", token.Header["kid"])
+		assert.Equal(t, jwt.SigningMethodRS256.Alg(), token.Method.Alg())
+	})
+
+	t.Run("rejects token of retired key", func(t *testing.T) {
+		assert.NoError(t, setKeys(newKey, "new", nil))
+
+		_, err := VerifyAccessToken(tokenString)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects symmetric token", func(t *testing.T) {
+		payload, err := NewPayload("user@example.com", "user-id", "User", "session-id", TokenTypeAccess, time.Minute)
+		assert.NoError(t, err)
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+		token.Header["kid"] = "new"
+		tokenString, err := token.SignedString(x509.MarshalPKCS1PublicKey(&newKey.PublicKey))
+		assert.NoError(t, err)
+
+		_, err = VerifyAccessToken(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	})
+}
+
+func TestLoadKeys(t *testing.T) {
+	defer GenerateKeys()
+
+	dir := t.TempDir()
+
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	signingKeyPath := filepath.Join(dir, "signing.pem")
+	assert.NoError(t, os.WriteFile(signingKeyPath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(signingKey),
+	}), 0600))
+
+	previousKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	previousKeyDER, err := x509.MarshalPKIXPublicKey(previousKey)
+	assert.NoError(t, err)
+	previousKeyPath := filepath.Join(dir, "previous.pem")
+	assert.NoError(t, os.WriteFile(previousKeyPath, pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: previousKeyDER,
+	}), 0600))
+
+	err = LoadKeys(signingKeyPath, "current", map[string]string{"previous": previousKeyPath})
+	assert.NoError(t, err)
+
+	jwks := JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	for _, key := range jwks.Keys {
+		switch key.Kid {
+		case "current":
+			assert.Equal(t, "RSA", key.Kty)
+			assert.Equal(t, "RS256", key.Alg)
+			assert.Equal(t, "AQAB", key.E)
+			assert.NotEmpty(t, key.N)
+		case "previous":
+			assert.Equal(t, "OKP", key.Kty)
+			assert.Equal(t, "EdDSA", key.Alg)
+			assert.Equal(t, "Ed25519", key.Crv)
+			assert.NotEmpty(t, key.X)
+		default:
+			t.Errorf("unexpected kid %q", key.Kid)
+		}
+	}
+
+	t.Run("missing key file", func(t *testing.T) {
+		err := LoadKeys(filepath.Join(dir, "missing.pem"), "current", nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,230 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keySet holds the key new tokens are signed with and every public key that is
+// still accepted, so tokens signed before a rotation stay valid until they
+// expire as long as the previous key is kept in the verification set.
+type keySet struct {
+	signingKey       crypto.Signer
+	signingKeyID     string
+	signingMethod    jwt.SigningMethod
+	verificationKeys map[string]crypto.PublicKey
+}
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+var keys atomic.Pointer[keySet]
+
+// LoadKeys reads the PEM encoded private key used for signing and the public
+// (or private) keys of previous rotations that must still be accepted, keyed
+// by their kid.
+func LoadKeys(signingKeyPath string, signingKeyID string, verificationKeyPaths map[string]string) error {
+	signingKeyPEM, err := os.ReadFile(signingKeyPath)
+	if err != nil {
+		return fmt.Errorf("cannot read signing key: %w", err)
+	}
+
+	signingKey, err := parsePrivateKey(signingKeyPEM)
+	if err != nil {
+		return fmt.Errorf("cannot parse signing key: %w", err)
+	}
+
+	verificationKeys := make(map[string]crypto.PublicKey)
+	for kid, path := range verificationKeyPaths {
+		keyPEM, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read verification key %s: %w", kid, err)
+		}
+
+		publicKey, err := parsePublicKey(keyPEM)
+		if err != nil {
+			return fmt.Errorf("cannot parse verification key %s: %w", kid, err)
+		}
+		verificationKeys[kid] = publicKey
+	}
+
+	return setKeys(signingKey, signingKeyID, verificationKeys)
+}
+
+// GenerateKeys signs with a random Ed25519 key that only lives as long as the
+// process. It is meant for local development and tests, other services cannot
+// verify these tokens after a restart. The kid is derived from the key, so
+// two processes never publish different keys under the same kid.
+func GenerateKeys() error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	return setKeys(privateKey, fmt.Sprintf("ephemeral-%x", publicKey[:8]), nil)
+}
+
+func setKeys(signingKey crypto.Signer, signingKeyID string, verificationKeys map[string]crypto.PublicKey) error {
+	if signingKeyID == "" {
+		return fmt.Errorf("signing key id is required")
+	}
+
+	var signingMethod jwt.SigningMethod
+	switch signingKey.(type) {
+	case *rsa.PrivateKey:
+		signingMethod = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		signingMethod = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("unsupported signing key type %T", signingKey)
+	}
+
+	set := &keySet{
+		signingKey:       signingKey,
+		signingKeyID:     signingKeyID,
+		signingMethod:    signingMethod,
+		verificationKeys: map[string]crypto.PublicKey{signingKeyID: signingKey.Public()},
+	}
+	for kid, publicKey := range verificationKeys {
+		if kid == signingKeyID {
+			continue
+		}
+		set.verificationKeys[kid] = publicKey
+	}
+
+	keys.Store(set)
+	return nil
+}
+
+func currentKeys() (*keySet, error) {
+	set := keys.Load()
+	if set == nil {
+		return nil, fmt.Errorf("jwt keys are not loaded")
+	}
+
+	return set, nil
+}
+
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	set, err := currentKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("kid header not found in token")
+	}
+
+	publicKey, ok := set.verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return publicKey, nil
+}
+
+// JWKS returns the public verification keys in JSON Web Key Set format.
+func JWKS() JSONWebKeySet {
+	jwks := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	set := keys.Load()
+	if set == nil {
+		return jwks
+	}
+
+	for kid, publicKey := range set.verificationKeys {
+		switch key := publicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JSONWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: jwt.SigningMethodRS256.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JSONWebKey{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: jwt.SigningMethodEdDSA.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(key),
+			})
+		}
+	}
+
+	return jwks
+}
+
+func parsePrivateKey(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("key must be PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// parsePublicKey also accepts private keys, so the key file of a previous
+// rotation can be kept around as is.
+func parsePublicKey(keyPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("key must be PEM encoded")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	signer, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Public(), nil
+}
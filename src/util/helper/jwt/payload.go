@@ -7,14 +7,32 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeMFA     = "mfa"
+
+	tokenIssuer = "user_login"
+)
+
+// tokenAudience keeps every token kind on its own audience, so a verifier that
+// only checks the audience still refuses the wrong kind of token.
+var tokenAudience = map[string]string{
+	TokenTypeAccess:  "shopifun-api",
+	TokenTypeRefresh: "shopifun-token-refresh",
+	TokenTypeMFA:     "shopifun-mfa",
+}
+
 type Payload struct {
-	Email  string
-	UserID string
-	Role   string
+	Email     string
+	UserID    string
+	Role      string
+	SessionID string
+	TokenType string
 	jwt.RegisteredClaims
 }
 
-func NewPayload(email string, userID string, role string, duration time.Duration) (*Payload, error) {
+func NewPayload(email string, userID string, role string, sessionID string, tokenType string, duration time.Duration) (*Payload, error) {
 	usrEmail, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -22,15 +40,18 @@ func NewPayload(email string, userID string, role string, duration time.Duration
 
 	timeNow := time.Now()
 	payload := &Payload{
-		Email:  email,
-		UserID: userID,
-		Role:   role,
+		Email:     email,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		TokenType: tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(timeNow.Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(timeNow),
 			NotBefore: jwt.NewNumericDate(timeNow),
-			Issuer:    "user_login",
+			Issuer:    tokenIssuer,
 			Subject:   "shopifun",
+			Audience:  jwt.ClaimStrings{tokenAudience[tokenType]},
 			ID:        usrEmail.String(),
 		},
 	}
@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host string, port int, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, message(m.from, to, subject, body)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
+// LogMailer is for local development: mails are written as .eml files to dir,
+// or to the log when no dir is configured.
+type LogMailer struct {
+	dir  string
+	from string
+}
+
+func NewLogMailer(dir string, from string) *LogMailer {
+	return &LogMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *LogMailer) Send(to string, subject string, body string) error {
+	msg := message(m.from, to, subject, body)
+	if m.dir == "" {
+		log.Printf("[MAILER] %s", msg)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mail dir: %w", err)
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(to, "@", "_at_"))
+	if err := os.WriteFile(filepath.Join(m.dir, name), msg, 0644); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+
+	return nil
+}
+
+func message(from string, to string, subject string, body string) []byte {
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+	}
+
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body)
+}
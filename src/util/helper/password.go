@@ -0,0 +1,76 @@
+package helper
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 72 // bcrypt ignores everything after 72 bytes
+)
+
+// dummyPasswordHash is compared against when the user does not exist, so a
+// failed login takes the same time whether or not the email is registered.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+func CheckPassword(hashedPassword, password string) error {
+	if hashedPassword == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+}
+
+// ValidatePasswordStrength requires 8-72 characters with at least one upper
+// case letter, one lower case letter, one digit and one symbol.
+func ValidatePasswordStrength(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
+		return false
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSymbol = true
+		}
+	}
+
+	return hasUpper && hasLower && hasDigit && hasSymbol
+}
+
+// RegisterValidations adds the custom rules and makes validation errors name
+// fields by their JSON name.
+func RegisterValidations(validate *validator.Validate) error {
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	return validate.RegisterValidation("password", ValidatePasswordStrength)
+}
@@ -1,6 +1,28 @@
 package helper
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBodySize caps the JSON bodies the handlers accept.
+const maxBodySize = 1 << 20
+
+var (
+	ErrUnsupportedMediaType = apperr.New(apperr.KindUnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/json")
+	ErrBodyTooLarge         = apperr.New(apperr.KindTooLarge, "body_too_large", "Request body is too large")
+)
 
 func TimeNow() (*time.Time, error) {
 	location, err := time.LoadLocation("Asia/Jakarta")
@@ -12,3 +34,114 @@ func TimeNow() (*time.Time, error) {
 
 	return &jakartaTime, nil
 }
+
+// DecodeAndValidate decodes the JSON body of r into dst and validates the
+// struct tags of dst. The returned error is ready for HandleError.
+func DecodeAndValidate(r *http.Request, validate *validator.Validate, dst interface{}) error {
+	if err := DecodeJSON(r, dst); err != nil {
+		return err
+	}
+
+	return Validate(validate, dst)
+}
+
+// DecodeJSON decodes the JSON body of r into dst. The body must be sent as
+// application/json, hold a single JSON value and only use fields dst knows.
+// Handlers that fill fields from the path or the token before validating
+// call DecodeJSON and Validate separately.
+func DecodeJSON(r *http.Request, dst interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return ErrUnsupportedMediaType
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return apperr.Validation("invalid_body", "Request body must hold a single JSON value")
+	}
+
+	return nil
+}
+
+// ReadBody reads the body of r and puts it back, so it can still be decoded
+// with DecodeJSON afterwards.
+func ReadBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, ErrBodyTooLarge
+		}
+		return nil, InvalidBody(err)
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// Validate checks the struct tags of v.
+func Validate(validate *validator.Validate, v interface{}) error {
+	if err := validate.Struct(v); err != nil {
+		return ValidationError(err)
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return apperr.Validation("invalid_body", "Request body is empty")
+	case errors.As(err, &maxBytesErr):
+		return ErrBodyTooLarge
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apperr.Validation("invalid_body", "Request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		return apperr.Validation("validation_failed", "Request validation failed", apperr.FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type),
+		})
+	}
+
+	// The decoder has no typed error for unknown fields, only the message
+	// `json: unknown field "name"`
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		return apperr.Validation("validation_failed", "Request validation failed", apperr.FieldError{
+			Field:   field,
+			Rule:    "unknown",
+			Message: field + " is not allowed",
+		})
+	}
+
+	return InvalidBody(err)
+}
+
+// ParsePositiveInt parses an optional query parameter, an empty value is 0.
+func ParsePositiveInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if number < 1 {
+		return 0, errors.New("must be positive")
+	}
+
+	return number, nil
+}
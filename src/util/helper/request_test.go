@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"user-service/src/util/apperr"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Qty   int    `json:"qty" validate:"min=1"`
+}
+
+func newJSONRequest(t *testing.T, contentType string, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/", strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	validate := validator.New()
+	assert.NoError(t, RegisterValidations(validate))
+
+	t.Run("valid body", func(t *testing.T) {
+		var bReq testRequest
+		req := newJSONRequest(t, "application/json; charset=utf-8", `{"email":"user@example.com","qty":2}`)
+
+		assert.NoError(t, DecodeAndValidate(req, validate, &bReq))
+		assert.Equal(t, testRequest{Email: "user@example.com", Qty: 2}, bReq)
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		field       string
+	}{
+		{"not json", "text/plain", `{"email":"user@example.com","qty":2}`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"missing content type", "", `{"email":"user@example.com","qty":2}`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"empty body", "application/json", ``, http.StatusBadRequest, "invalid_body", ""},
+		{"malformed body", "application/json", `{"email":`, http.StatusBadRequest, "invalid_body", ""},
+		{"several values", "application/json", `{"email":"user@example.com","qty":2}{}`, http.StatusBadRequest, "invalid_body", ""},
+		{"unknown field", "application/json", `{"email":"user@example.com","qty":2,"role":"Admin"}`, http.StatusBadRequest, "validation_failed", "role"},
+		{"wrong type", "application/json", `{"email":"user@example.com","qty":"two"}`, http.StatusBadRequest, "validation_failed", "qty"},
+		{"failed rule", "application/json", `{"email":"not-an-email","qty":2}`, http.StatusBadRequest, "validation_failed", "email"},
+		{"too large", "application/json", `{"email":"` + strings.Repeat("a", maxBodySize) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bReq testRequest
+			err := DecodeAndValidate(newJSONRequest(t, tt.contentType, tt.body), validate, &bReq)
+
+			appErr := apperr.From(err)
+			if assert.NotNil(t, appErr) {
+				assert.Equal(t, tt.status, appErr.StatusCode())
+				assert.Equal(t, tt.code, appErr.Code)
+				if tt.field != "" && assert.Len(t, appErr.Fields, 1) {
+					assert.Equal(t, tt.field, appErr.Fields[0].Field)
+				}
+			}
+		})
+	}
+}
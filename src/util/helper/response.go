@@ -1,8 +1,14 @@
 package helper
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"user-service/src/util/apperr"
 	"user-service/src/util/repository/model"
+	"user-service/src/util/repository/model/attempts"
 
 	"github.com/thedevsaddam/renderer"
 )
@@ -19,3 +25,29 @@ func HandleResponse(w http.ResponseWriter, render *renderer.Render, statusCode i
 
 	render.JSON(w, statusCode, response)
 }
+
+// HandleError answers with the status and code of an apperr.Error. Any other
+// error is unexpected, it is logged and answered with a generic 500 so
+// internals do not leak to the client.
+func HandleError(w http.ResponseWriter, render *renderer.Render, err error) {
+	appErr := apperr.From(err)
+	if appErr == nil {
+		log.Printf("[HTTP] internal error: %v", err)
+		render.JSON(w, http.StatusInternalServerError, model.BaseResponse{
+			Message: "Internal server error",
+			Error:   &apperr.Detail{Code: apperr.CodeInternal},
+		})
+		return
+	}
+
+	// Throttled sign ins tell the client when to retry
+	var lockoutErr *attempts.LockoutError
+	if errors.As(err, &lockoutErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds()))))
+	}
+
+	render.JSON(w, appErr.StatusCode(), model.BaseResponse{
+		Message: appErr.Message,
+		Error:   appErr.Detail(),
+	})
+}
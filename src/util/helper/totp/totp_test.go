@@ -0,0 +1,69 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestValidate_RFCVectors(t *testing.T) {
+	// The RFC lists 8 digit codes, the last 6 digits are the 6 digit code
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, code := range vectors {
+		step, ok := Validate(rfcSecret, code, time.Unix(unix, 0))
+		assert.True(t, ok, "code for %d", unix)
+		assert.Equal(t, unix/period, step)
+	}
+}
+
+func TestValidate_Skew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	_, ok := Validate(rfcSecret, "050471", now.Add(period*time.Second))
+	assert.True(t, ok, "previous step is accepted")
+
+	_, ok = Validate(rfcSecret, "050471", now.Add(3*period*time.Second))
+	assert.False(t, ok, "older steps are refused")
+
+	_, ok = Validate(rfcSecret, "000000", now)
+	assert.False(t, ok)
+
+	_, ok = Validate("not base32!", "050471", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := URI("Shopifun", "seller@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Shopifun:seller@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Len(t, code, 11)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"user-service/src/util/apperr"
 	"user-service/src/util/helper/jwt"
+	"user-service/src/util/repository/model"
 )
 
 const userKey = "UserID"
 const roleKey = "Role"
+const tokenKey = "Token"
 
 const (
 	RoleAdmin  = "Admin"
@@ -16,6 +19,20 @@ const (
 	RoleSeller = "Seller"
 )
 
+type tokenValidator interface {
+	ValidateAccessToken(payload *jwt.Payload) error
+}
+
+type Authenticator struct {
+	tokens tokenValidator
+}
+
+func NewAuthenticator(tokens tokenValidator) *Authenticator {
+	return &Authenticator{
+		tokens: tokens,
+	}
+}
+
 func SetUserID(ctx context.Context, userID string) context.Context {
 	ctx = context.WithValue(ctx, userKey, userID)
 	return ctx
@@ -26,6 +43,11 @@ func SetRole(ctx context.Context, role string) context.Context {
 	return ctx
 }
 
+func SetToken(ctx context.Context, payload *jwt.Payload) context.Context {
+	ctx = context.WithValue(ctx, tokenKey, payload)
+	return ctx
+}
+
 func GetUserID(ctx context.Context) string {
 	userID, ok := ctx.Value(userKey).(string)
 	if !ok {
@@ -44,35 +66,62 @@ func GetRole(ctx context.Context) string {
 	return role
 }
 
-func Authentication(next http.Handler) http.Handler {
+// GetToken returns the claims of the access token the request was
+// authenticated with.
+func GetToken(ctx context.Context) *jwt.Payload {
+	payload, ok := ctx.Value(tokenKey).(*jwt.Payload)
+	if !ok {
+		return nil
+	}
+
+	return payload
+}
+
+func (a *Authenticator) Authentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"Message": "Unauthorized",
-				"Data":    nil,
-			})
+		if len(tokenString) <= len("Bearer ") {
+			unauthorized(w)
 			return
 		}
 
 		tokenString = tokenString[len("Bearer "):]
-		payload, err := jwt.VerifyToken(tokenString)
+		payload, err := jwt.VerifyAccessToken(tokenString)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"Message": "Unauthorized",
-				"Data":    nil,
-			})
+			unauthorized(w)
+			return
+		}
+
+		if err := a.tokens.ValidateAccessToken(payload); err != nil {
+			unauthorized(w)
 			return
 		}
 
 		ctx = SetUserID(ctx, payload.UserID)
 		ctx = SetRole(ctx, payload.Role)
+		ctx = SetToken(ctx, payload)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+var (
+	errUnauthorized = apperr.Unauthorized("unauthorized", "Unauthorized")
+	errForbidden    = apperr.Forbidden("forbidden", "Forbidden")
+)
+
+func unauthorized(w http.ResponseWriter) {
+	writeError(w, errUnauthorized)
+}
+
+// writeError answers with the envelope of helper.HandleError, the middleware
+// has no renderer to call it with.
+func writeError(w http.ResponseWriter, err *apperr.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.StatusCode())
+	json.NewEncoder(w).Encode(model.BaseResponse{
+		Message: err.Message,
+		Error:   err.Detail(),
+	})
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// Permission is an action a role may perform. Routes ask for permissions
+// rather than roles, so granting an action to another role only touches
+// rolePermissions.
+type Permission string
+
+const (
+	PermissionUserList       Permission = "user:list"
+	PermissionUserManage     Permission = "user:manage"
+	PermissionProductWrite   Permission = "product:write"
+	PermissionShopWrite      Permission = "shop:write"
+	PermissionOrderCreate    Permission = "order:create"
+	PermissionOrderRead      Permission = "order:read"
+	PermissionOrderUpdate    Permission = "order:update"
+	PermissionOrderShip      Permission = "order:ship"
+	PermissionLockoutManage  Permission = "lockout:manage"
+	PermissionCheckoutManage Permission = "checkout:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermissionUserList,
+		PermissionUserManage,
+		PermissionProductWrite,
+		PermissionShopWrite,
+		PermissionOrderCreate,
+		PermissionOrderRead,
+		PermissionOrderUpdate,
+		PermissionLockoutManage,
+		PermissionCheckoutManage,
+	},
+	RoleSeller: {
+		PermissionProductWrite,
+		PermissionShopWrite,
+		PermissionOrderCreate,
+		PermissionOrderRead,
+		PermissionOrderUpdate,
+		PermissionOrderShip,
+	},
+	RoleUser: {
+		PermissionOrderCreate,
+		PermissionOrderRead,
+		PermissionOrderUpdate,
+	},
+}
+
+// HasPermission reports whether the role is granted the permission.
+func HasPermission(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Require only lets the request through when the role set by Authentication
+// has every one of the permissions, it answers 403 otherwise. It has to run
+// after Authentication.
+func Require(permissions ...Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := GetRole(r.Context())
+			for _, permission := range permissions {
+				if !HasPermission(role, permission) {
+					forbidden(w)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forbidden(w http.ResponseWriter) {
+	writeError(w, errForbidden)
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequire(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name        string
+		role        string
+		permissions []Permission
+		wantCode    int
+	}{
+		{"seller writes products", RoleSeller, []Permission{PermissionProductWrite}, http.StatusOK},
+		{"user cannot write products", RoleUser, []Permission{PermissionProductWrite}, http.StatusForbidden},
+		{"admin lists users", RoleAdmin, []Permission{PermissionUserList}, http.StatusOK},
+		{"seller cannot list users", RoleSeller, []Permission{PermissionUserList}, http.StatusForbidden},
+		{"only sellers ship orders", RoleAdmin, []Permission{PermissionOrderShip}, http.StatusForbidden},
+		{"every permission is required", RoleUser, []Permission{PermissionOrderCreate, PermissionOrderShip}, http.StatusForbidden},
+		{"unknown role", "Guest", []Permission{PermissionOrderRead}, http.StatusForbidden},
+		{"missing role", "", []Permission{PermissionOrderRead}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.role != "" {
+				req = req.WithContext(SetRole(req.Context(), tt.role))
+			}
+
+			rr := httptest.NewRecorder()
+			Require(tt.permissions...)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode == http.StatusForbidden {
+				assert.JSONEq(t, `{"message":"Forbidden","data":null,"error":{"code":"forbidden"}}`, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package policy decides whether an authenticated user may act on a given
+// resource. Route permissions (middleware.Require) only look at the role, the
+// checks here also look at who owns the resource.
+package policy
+
+import (
+	"context"
+	"user-service/src/util/apperr"
+	"user-service/src/util/middleware"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrForbidden  = apperr.Forbidden("forbidden", "you are not allowed to modify this resource")
+	ErrSelfAction = apperr.Forbidden("self_action", "admins cannot suspend or delete their own account")
+)
+
+// Actor is the user a request is made by.
+type Actor struct {
+	UserID uuid.UUID
+	Role   string
+}
+
+// ActorFromContext reads the actor set by middleware.Authentication.
+func ActorFromContext(ctx context.Context) (Actor, error) {
+	userID, err := uuid.Parse(middleware.GetUserID(ctx))
+	if err != nil {
+		return Actor{}, err
+	}
+
+	return Actor{
+		UserID: userID,
+		Role:   middleware.GetRole(ctx),
+	}, nil
+}
+
+// CanModifyUser lets users modify their own account and admins modify any
+// account.
+func CanModifyUser(actor Actor, userID uuid.UUID) error {
+	if actor.UserID == userID || middleware.HasPermission(actor.Role, middleware.PermissionUserManage) {
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// CanChangeRole only lets admins change roles, including their own.
+func CanChangeRole(actor Actor) error {
+	if middleware.HasPermission(actor.Role, middleware.PermissionUserManage) {
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// CanManageUser lets admins suspend and delete users. Admins cannot do it to
+// themselves, so they cannot lock themselves out by mistake.
+func CanManageUser(actor Actor, userID uuid.UUID) error {
+	if !middleware.HasPermission(actor.Role, middleware.PermissionUserManage) {
+		return ErrForbidden
+	}
+
+	if actor.UserID == userID {
+		return ErrSelfAction
+	}
+
+	return nil
+}
+
+// CanForceLogout lets admins sign out any user. Signing out does not lock the
+// account, so admins may also sign themselves out everywhere.
+func CanForceLogout(actor Actor) error {
+	if middleware.HasPermission(actor.Role, middleware.PermissionUserManage) {
+		return nil
+	}
+
+	return ErrForbidden
+}
@@ -0,0 +1,38 @@
+package policy
+
+import (
+	"testing"
+	"user-service/src/util/middleware"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanModifyUser(t *testing.T) {
+	self := uuid.New()
+	other := uuid.New()
+
+	assert.NoError(t, CanModifyUser(Actor{UserID: self, Role: middleware.RoleUser}, self))
+	assert.ErrorIs(t, CanModifyUser(Actor{UserID: self, Role: middleware.RoleUser}, other), ErrForbidden)
+	assert.ErrorIs(t, CanModifyUser(Actor{UserID: self, Role: middleware.RoleSeller}, other), ErrForbidden)
+	assert.NoError(t, CanModifyUser(Actor{UserID: self, Role: middleware.RoleAdmin}, other))
+}
+
+func TestCanChangeRole(t *testing.T) {
+	assert.NoError(t, CanChangeRole(Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}))
+	assert.ErrorIs(t, CanChangeRole(Actor{UserID: uuid.New(), Role: middleware.RoleSeller}), ErrForbidden)
+	assert.ErrorIs(t, CanChangeRole(Actor{UserID: uuid.New(), Role: middleware.RoleUser}), ErrForbidden)
+}
+
+func TestCanManageUser(t *testing.T) {
+	admin := Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}
+
+	assert.NoError(t, CanManageUser(admin, uuid.New()))
+	assert.ErrorIs(t, CanManageUser(admin, admin.UserID), ErrSelfAction)
+	assert.ErrorIs(t, CanManageUser(Actor{UserID: uuid.New(), Role: middleware.RoleSeller}, uuid.New()), ErrForbidden)
+}
+
+func TestCanForceLogout(t *testing.T) {
+	assert.NoError(t, CanForceLogout(Actor{UserID: uuid.New(), Role: middleware.RoleAdmin}))
+	assert.ErrorIs(t, CanForceLogout(Actor{UserID: uuid.New(), Role: middleware.RoleSeller}), ErrForbidden)
+}
@@ -0,0 +1,207 @@
+package attempts
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/attempts"
+	"user-service/src/util/repository/query"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+// GetAttempt returns an empty Attempt when there was no failure recorded.
+func (s *store) GetAttempt(scope string, identifier string) (*attempts.Attempt, error) {
+	querySelect := `
+		SELECT
+			scope,
+			identifier,
+			failures,
+			last_failure_at,
+			blocked_until,
+			locked_until
+		FROM
+			login_attempts
+		WHERE
+			scope = $1
+			AND identifier = $2
+	`
+
+	var response attempts.Attempt
+	if err := s.db.QueryRow(querySelect, scope, identifier).Scan(
+		&response.Scope,
+		&response.Identifier,
+		&response.Failures,
+		&response.LastFailureAt,
+		&response.BlockedUntil,
+		&response.LockedUntil,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return &response, nil
+		}
+		return nil, fmt.Errorf("failed to get login attempt: %w", err)
+	}
+
+	return &response, nil
+}
+
+// CountAttempt counts a sign in attempt before its credentials are checked.
+// The attempt is only counted when the key is not blocked or locked and has
+// fewer than limit failures in the window, a limit of 0 means no limit. The
+// check and the increment are one statement so parallel attempts can not all
+// pass the check before any of them is counted. ok is false when the attempt
+// was refused.
+func (s *store) CountAttempt(scope string, identifier string, window time.Duration, limit int) (int, bool, error) {
+	queryUpsert := `
+		INSERT INTO login_attempts(
+			scope,
+			identifier,
+			failures,
+			last_failure_at
+		) VALUES (
+			$1,
+			$2,
+			1,
+			$3
+		)
+		ON CONFLICT (scope, identifier) DO UPDATE
+		SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < $4 THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+		WHERE
+			(login_attempts.blocked_until IS NULL OR login_attempts.blocked_until <= $3)
+			AND (login_attempts.locked_until IS NULL OR login_attempts.locked_until <= $3)
+			AND ($5 = 0 OR login_attempts.last_failure_at < $4 OR login_attempts.failures < $5)
+		RETURNING failures
+	`
+
+	timeNow := time.Now().UTC()
+	var failures int
+	if err := s.db.QueryRow(queryUpsert, scope, identifier, timeNow, timeNow.Add(-window), limit).Scan(&failures); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to count login attempt: %w", err)
+	}
+
+	return failures, true, nil
+}
+
+// RefundAttempt takes back an attempt counted by CountAttempt whose
+// credentials turned out to be valid.
+func (s *store) RefundAttempt(scope string, identifier string) error {
+	queryUpdate := `
+		UPDATE login_attempts
+		SET
+			failures = GREATEST(failures - 1, 0)
+		WHERE
+			scope = $1
+			AND identifier = $2
+	`
+
+	if _, err := s.db.Exec(queryUpdate, scope, identifier); err != nil {
+		return fmt.Errorf("failed to refund login attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (s *store) Block(scope string, identifier string, blockedUntil *time.Time, lockedUntil *time.Time) error {
+	queryUpdate := `
+		UPDATE login_attempts
+		SET
+			blocked_until = $3,
+			locked_until = COALESCE($4, locked_until)
+		WHERE
+			scope = $1
+			AND identifier = $2
+	`
+
+	if _, err := s.db.Exec(queryUpdate, scope, identifier, utc(blockedUntil), utc(lockedUntil)); err != nil {
+		return fmt.Errorf("failed to block login attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (s *store) ClearAttempts(scope string, identifier string) error {
+	queryDelete := `
+		DELETE FROM login_attempts
+		WHERE
+			scope = $1
+			AND identifier = $2
+	`
+
+	if _, err := s.db.Exec(queryDelete, scope, identifier); err != nil {
+		return fmt.Errorf("failed to clear login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// GetLockouts lists the accounts and IP addresses that are blocked right now.
+func (s *store) GetLockouts() (*[]attempts.Attempt, error) {
+	now := time.Now().UTC()
+	querySelect, args := query.Select(`
+		SELECT
+			scope,
+			identifier,
+			failures,
+			last_failure_at,
+			blocked_until,
+			locked_until
+		FROM
+			login_attempts
+	`).
+		Where("blocked_until > ? OR locked_until > ?", now, now).
+		OrderBy("last_failure_at DESC").
+		Build()
+
+	rows, err := s.db.Query(querySelect, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	lockouts := []attempts.Attempt{}
+	for rows.Next() {
+		var attempt attempts.Attempt
+		if err := rows.Scan(
+			&attempt.Scope,
+			&attempt.Identifier,
+			&attempt.Failures,
+			&attempt.LastFailureAt,
+			&attempt.BlockedUntil,
+			&attempt.LockedUntil,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rows: %v", err)
+		}
+		lockouts = append(lockouts, attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	return &lockouts, nil
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	timeUTC := t.UTC()
+	return &timeUTC
+}
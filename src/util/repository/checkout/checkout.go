@@ -0,0 +1,324 @@
+package checkout
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/checkout"
+	"user-service/src/util/repository/query"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const sagaColumns = `
+	id,
+	user_id,
+	status,
+	step,
+	state,
+	error,
+	created_at,
+	updated_at
+`
+
+const queryUpdateSaga = `
+	UPDATE checkout_sagas
+	SET
+		status = $2,
+		step = $3,
+		state = $4,
+		error = $5,
+		updated_at = $6
+	WHERE
+		id = $1
+`
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) CreateSaga(saga *checkout.Saga) error {
+	state, err := json.Marshal(saga.State)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga state: %w", err)
+	}
+
+	queryInsert := `
+		INSERT INTO checkout_sagas(
+			id,
+			user_id,
+			status,
+			step,
+			state,
+			error,
+			created_at,
+			updated_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$7
+		)
+	`
+
+	if _, err := s.db.Exec(queryInsert, saga.Id, saga.UserID, saga.Status, saga.Step, state, saga.Error, saga.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to insert saga: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSaga stores the status, step and state of the saga.
+func (s *store) UpdateSaga(saga *checkout.Saga) error {
+	state, err := json.Marshal(saga.State)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga state: %w", err)
+	}
+
+	if _, err := s.db.Exec(queryUpdateSaga, saga.Id, saga.Status, saga.Step, state, saga.Error, saga.UpdatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to update saga: %w", err)
+	}
+
+	return nil
+}
+
+// SaveStep stores the status, step and state of the saga and appends entry to
+// its log, so a crash never loses a step that ran.
+func (s *store) SaveStep(saga *checkout.Saga, entry checkout.StepLog) error {
+	state, err := json.Marshal(saga.State)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga state: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(queryUpdateSaga, saga.Id, saga.Status, saga.Step, state, saga.Error, saga.UpdatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to update saga: %w", err)
+	}
+
+	if err := insertStep(tx, saga.Id, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertStep(tx *sql.Tx, sagaID uuid.UUID, entry checkout.StepLog) error {
+	queryInsert := `
+		INSERT INTO checkout_saga_steps(
+			saga_id,
+			step,
+			status,
+			error,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		)
+	`
+
+	if _, err := tx.Exec(queryInsert, sagaID, entry.Step, entry.Status, entry.Error, entry.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to insert saga step: %w", err)
+	}
+
+	return nil
+}
+
+// GetSaga returns the saga with its full step log.
+func (s *store) GetSaga(id uuid.UUID) (*checkout.Saga, error) {
+	querySelect := `SELECT` + sagaColumns + `
+		FROM
+			checkout_sagas
+		WHERE
+			id = $1
+	`
+
+	saga, err := scanSaga(s.db.QueryRow(querySelect, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, checkout.ErrSagaNotFound
+		}
+		return nil, fmt.Errorf("failed to get saga: %w", err)
+	}
+
+	querySteps, args := query.Select(`
+		SELECT
+			step,
+			status,
+			error,
+			created_at
+		FROM
+			checkout_saga_steps
+	`).
+		Where("saga_id = ?", id).
+		OrderBy("id").
+		Build()
+
+	rows, err := s.db.Query(querySteps, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saga steps: %w", err)
+	}
+	defer rows.Close()
+
+	saga.Steps = []checkout.StepLog{}
+	for rows.Next() {
+		var entry checkout.StepLog
+		if err := rows.Scan(&entry.Step, &entry.Status, &entry.Error, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saga steps: %w", err)
+		}
+		saga.Steps = append(saga.Steps, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate saga steps: %w", err)
+	}
+
+	return saga, nil
+}
+
+// GetSagas lists sagas newest first, without their step logs.
+func (s *store) GetSagas(bReq checkout.RequestSagas) (*[]checkout.Saga, int, error) {
+	querySelect, args := query.Select(`
+		SELECT`+sagaColumns+`,
+			COUNT(*) OVER() AS total_count
+		FROM
+			checkout_sagas
+	`).
+		WhereIf(bReq.Status != "", "status = ?", bReq.Status).
+		OrderBy("created_at DESC, id DESC").
+		Paginate(bReq.Page, bReq.Limit).
+		Build()
+
+	rows, err := s.db.Query(querySelect, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	sagas := []checkout.Saga{}
+	var totalData int
+	for rows.Next() {
+		saga, err := scanSaga(rows, &totalData)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan rows: %w", err)
+		}
+		sagas = append(sagas, *saga)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	return &sagas, totalData, nil
+}
+
+// ClaimStaleSagas takes over the running and compensating sagas that were not
+// updated since before. Claiming bumps updated_at in the same statement, so
+// two instances never resume the same saga.
+func (s *store) ClaimStaleSagas(before time.Time, now time.Time) ([]checkout.Saga, error) {
+	queryUpdate := `
+		UPDATE checkout_sagas
+		SET
+			updated_at = $3
+		WHERE
+			status = ANY($1)
+			AND updated_at < $2
+		RETURNING` + sagaColumns
+
+	rows, err := s.db.Query(queryUpdate, pq.Array([]string{checkout.StatusRunning, checkout.StatusCompensating}), before.UTC(), now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim stale sagas: %w", err)
+	}
+	defer rows.Close()
+
+	sagas := []checkout.Saga{}
+	for rows.Next() {
+		saga, err := scanSaga(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rows: %w", err)
+		}
+		sagas = append(sagas, *saga)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	return sagas, nil
+}
+
+// ClaimFailedSaga moves a failed saga back to compensating. It returns
+// ErrNotResumable when the saga is not failed, or another request claimed
+// it first.
+func (s *store) ClaimFailedSaga(id uuid.UUID, now time.Time) (*checkout.Saga, error) {
+	queryUpdate := `
+		UPDATE checkout_sagas
+		SET
+			status = $2,
+			updated_at = $3
+		WHERE
+			id = $1
+			AND status = $4
+		RETURNING` + sagaColumns
+
+	saga, err := scanSaga(s.db.QueryRow(queryUpdate, id, checkout.StatusCompensating, now.UTC(), checkout.StatusFailed))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if _, err := s.GetSaga(id); err != nil {
+				return nil, err
+			}
+			return nil, checkout.ErrNotResumable
+		}
+		return nil, fmt.Errorf("failed to claim saga: %w", err)
+	}
+
+	return saga, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSaga(row rowScanner, extra ...interface{}) (*checkout.Saga, error) {
+	var saga checkout.Saga
+	var state []byte
+	dest := append([]interface{}{
+		&saga.Id,
+		&saga.UserID,
+		&saga.Status,
+		&saga.Step,
+		&state,
+		&saga.Error,
+		&saga.CreatedAt,
+		&saga.UpdatedAt,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(state, &saga.State); err != nil {
+		return nil, fmt.Errorf("failed to decode saga state: %w", err)
+	}
+
+	return &saga, nil
+}
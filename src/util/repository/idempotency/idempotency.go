@@ -0,0 +1,197 @@
+package idempotency
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/idempotency"
+
+	"github.com/google/uuid"
+)
+
+const recordColumns = `
+	user_id,
+	key,
+	request_hash,
+	status_code,
+	response,
+	created_at,
+	completed_at,
+	locked_until,
+	saga_id
+`
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+// Begin claims key for the user until lockedUntil. It returns the new record
+// and true, or the record of the earlier request and false when the key is
+// already claimed. A record created before expiredBefore is replaced as if the
+// key were unused, the claim of an unfinished request for the same body is
+// taken over once its lock ran out and keeps the saga that request started.
+func (s *store) Begin(userID uuid.UUID, key string, requestHash string, now time.Time, expiredBefore time.Time, lockedUntil time.Time) (*idempotency.Record, bool, error) {
+	queryUpsert := `
+		INSERT INTO idempotency_keys(
+			user_id,
+			key,
+			request_hash,
+			created_at,
+			locked_until
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$6
+		)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response = NULL,
+			created_at = EXCLUDED.created_at,
+			completed_at = NULL,
+			locked_until = EXCLUDED.locked_until,
+			saga_id = CASE WHEN idempotency_keys.created_at < $5 THEN NULL ELSE idempotency_keys.saga_id END
+		WHERE
+			idempotency_keys.created_at < $5
+			OR (
+				idempotency_keys.completed_at IS NULL
+				AND idempotency_keys.locked_until < $4
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash
+			)
+		RETURNING` + recordColumns
+
+	record, err := scanRecord(s.db.QueryRow(queryUpsert, userID, key, requestHash, now.UTC(), expiredBefore.UTC(), lockedUntil.UTC()))
+	if err == nil {
+		return record, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	// The conflict was not updated, the key belongs to an earlier request
+	querySelect := `SELECT` + recordColumns + `
+		FROM
+			idempotency_keys
+		WHERE
+			user_id = $1
+			AND key = $2
+	`
+
+	record, err = scanRecord(s.db.QueryRow(querySelect, userID, key))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return record, false, nil
+}
+
+// SetSaga records the saga started by the request holding key.
+func (s *store) SetSaga(userID uuid.UUID, key string, sagaID uuid.UUID) error {
+	queryUpdate := `
+		UPDATE idempotency_keys
+		SET
+			saga_id = $3
+		WHERE
+			user_id = $1
+			AND key = $2
+			AND completed_at IS NULL
+	`
+
+	if _, err := s.db.Exec(queryUpdate, userID, key, sagaID); err != nil {
+		return fmt.Errorf("failed to set saga of idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Complete stores the final response of the request holding key.
+func (s *store) Complete(userID uuid.UUID, key string, statusCode int, response []byte, now time.Time) error {
+	queryUpdate := `
+		UPDATE idempotency_keys
+		SET
+			status_code = $3,
+			response = $4,
+			completed_at = $5
+		WHERE
+			user_id = $1
+			AND key = $2
+			AND completed_at IS NULL
+	`
+
+	if _, err := s.db.Exec(queryUpdate, userID, key, statusCode, response, now.UTC()); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Release frees a key whose request did not complete, so it can be retried.
+func (s *store) Release(userID uuid.UUID, key string) error {
+	queryDelete := `
+		DELETE FROM idempotency_keys
+		WHERE
+			user_id = $1
+			AND key = $2
+			AND completed_at IS NULL
+	`
+
+	if _, err := s.db.Exec(queryDelete, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the keys created before the given time.
+func (s *store) DeleteExpired(before time.Time) (int64, error) {
+	queryDelete := `
+		DELETE FROM idempotency_keys
+		WHERE
+			created_at < $1
+	`
+
+	result, err := s.db.Exec(queryDelete, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row rowScanner) (*idempotency.Record, error) {
+	var record idempotency.Record
+	var statusCode sql.NullInt64
+	if err := row.Scan(
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&record.Response,
+		&record.CreatedAt,
+		&record.CompletedAt,
+		&record.LockedUntil,
+		&record.SagaID,
+	); err != nil {
+		return nil, err
+	}
+
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		record.StatusCode = &code
+	}
+
+	return &record, nil
+}
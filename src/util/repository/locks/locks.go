@@ -0,0 +1,87 @@
+package locks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"user-service/src/util/repository/model/locks"
+
+	"github.com/lib/pq"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+// Lock takes a Postgres advisory lock for every key and returns a context
+// bound to the locks and the function releasing them. The locks live in a
+// transaction, so they are released by Postgres as well when the connection
+// is lost. After locks.HoldTimeout the context is done and the transaction is
+// rolled back, calls made with the context are cancelled with it. Keys are
+// taken in sorted order, two callers sharing keys cannot deadlock.
+func (s *store) Lock(keys []string) (context.Context, func(), error) {
+	keys = uniqueSorted(keys)
+
+	ctx, cancel := context.WithTimeout(context.Background(), locks.HoldTimeout)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", locks.Timeout.Milliseconds())); err != nil {
+		tx.Rollback()
+		cancel()
+		return nil, nil, err
+	}
+
+	for _, key := range keys {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended($1, 0))", key); err != nil {
+			tx.Rollback()
+			cancel()
+			if isLockTimeout(err) {
+				return nil, nil, locks.ErrLockTimeout
+			}
+			return nil, nil, err
+		}
+	}
+
+	unlock := func() {
+		// A transaction rolled back at the deadline is already released
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("[LOCKS] failed to release %v: %v", keys, err)
+		}
+		cancel()
+	}
+
+	return ctx, unlock, nil
+}
+
+func uniqueSorted(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, key)
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// isLockTimeout reports whether lock_timeout expired while waiting.
+func isLockTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "55P03"
+}
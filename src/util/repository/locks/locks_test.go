@@ -0,0 +1,60 @@
+package locks
+
+import (
+	"regexp"
+	"testing"
+	"time"
+	"user-service/src/util/repository/model/locks"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockStore(t *testing.T) (*store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	return NewStore(db), mock
+}
+
+func TestStore_Lock(t *testing.T) {
+	queryLock := regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtextextended($1, 0))")
+
+	t.Run("locks sorted unique keys until released", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("SET LOCAL lock_timeout = '10000ms'").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(queryLock).WithArgs("product:a").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(queryLock).WithArgs("product:b").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		ctx, unlock, err := s.Lock([]string{"product:b", "product:a", "product:b"})
+
+		assert.NoError(t, err)
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(locks.HoldTimeout), deadline, time.Second)
+
+		unlock()
+		assert.Error(t, ctx.Err(), "releasing the locks ends their context")
+	})
+
+	t.Run("lock timeout", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("SET LOCAL lock_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(queryLock).WithArgs("product:a").WillReturnError(&pq.Error{Code: "55P03"})
+		mock.ExpectRollback()
+
+		ctx, unlock, err := s.Lock([]string{"product:a"})
+
+		assert.Nil(t, ctx)
+		assert.Nil(t, unlock)
+		assert.ErrorIs(t, err, locks.ErrLockTimeout)
+	})
+}
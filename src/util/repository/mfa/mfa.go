@@ -0,0 +1,237 @@
+package mfa
+
+import (
+	"database/sql"
+	"fmt"
+	"user-service/src/util/repository/model/mfa"
+
+	"github.com/google/uuid"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+// GetMFA returns an empty MFA when the user never set up two-factor
+// authentication.
+func (s *store) GetMFA(userID uuid.UUID) (*mfa.MFA, error) {
+	querySelect := `
+		SELECT
+			user_id,
+			secret,
+			last_used_step,
+			enabled_at,
+			created_at
+		FROM
+			user_mfa
+		WHERE
+			user_id = $1
+	`
+
+	var response mfa.MFA
+	if err := s.db.QueryRow(querySelect, userID).Scan(
+		&response.UserId,
+		&response.Secret,
+		&response.LastUsedStep,
+		&response.EnabledAt,
+		&response.CreatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return &response, nil
+		}
+		return nil, fmt.Errorf("failed to get mfa: %w", err)
+	}
+
+	return &response, nil
+}
+
+// SetupMFA stores a new pending secret and recovery codes, replacing an
+// earlier setup that was never confirmed.
+func (s *store) SetupMFA(userID uuid.UUID, secret string, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	queryUpsert := `
+		INSERT INTO user_mfa(
+			user_id,
+			secret,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			now()
+		)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+			secret = EXCLUDED.secret,
+			last_used_step = NULL,
+			created_at = now()
+		WHERE
+			user_mfa.enabled_at IS NULL
+	`
+	result, err := tx.Exec(queryUpsert, userID, secret)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to setup mfa: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		tx.Rollback()
+		return mfa.ErrMFAAlreadyEnabled
+	}
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	queryCode := `
+		INSERT INTO mfa_recovery_codes(
+			user_id,
+			code_hash,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			now()
+		)
+	`
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(queryCode, userID, codeHash); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// EnableMFA turns a pending setup on, step is the TOTP step of the code that
+// confirmed it.
+func (s *store) EnableMFA(userID uuid.UUID, step int64) error {
+	queryUpdate := `
+		UPDATE user_mfa
+		SET
+			enabled_at = now(),
+			last_used_step = $2
+		WHERE
+			user_id = $1
+			AND enabled_at IS NULL
+	`
+
+	result, err := s.db.Exec(queryUpdate, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return mfa.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted code. A step that is not newer
+// than the last accepted one is refused, so every code works only once.
+func (s *store) UseTOTPStep(userID uuid.UUID, step int64) error {
+	queryUpdate := `
+		UPDATE user_mfa
+		SET
+			last_used_step = $2
+		WHERE
+			user_id = $1
+			AND (last_used_step IS NULL OR last_used_step < $2)
+	`
+
+	result, err := s.db.Exec(queryUpdate, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to use totp step: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return mfa.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *store) UseRecoveryCode(userID uuid.UUID, codeHash string) error {
+	queryUpdate := `
+		UPDATE mfa_recovery_codes
+		SET
+			used_at = now()
+		WHERE
+			user_id = $1
+			AND code_hash = $2
+			AND used_at IS NULL
+	`
+
+	result, err := s.db.Exec(queryUpdate, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return mfa.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *store) DeleteMFA(userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete mfa: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
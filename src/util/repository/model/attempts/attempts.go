@@ -0,0 +1,45 @@
+package attempts
+
+import (
+	"fmt"
+	"time"
+	"user-service/src/util/apperr"
+)
+
+const (
+	ScopeAccount = "account"
+	ScopeIP      = "ip"
+)
+
+var (
+	ErrTooManyAttempts = apperr.New(apperr.KindTooManyRequests, "too_many_attempts", "too many failed sign in attempts, try again later")
+	ErrAccountLocked   = apperr.New(apperr.KindLocked, "account_locked", "account is temporarily locked after too many failed sign in attempts")
+	ErrInvalidScope    = apperr.Validation("invalid_lockout_scope", "invalid lockout scope")
+)
+
+// Attempt counts the failed sign ins of an account or an IP address.
+// BlockedUntil is the backoff between attempts, LockedUntil the lockout of an
+// account.
+type Attempt struct {
+	Scope         string     `json:"scope"`
+	Identifier    string     `json:"identifier"`
+	Failures      int        `json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// LockoutError wraps ErrTooManyAttempts or ErrAccountLocked with the time the
+// client has to wait.
+type LockoutError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s (retry after %s)", e.Err.Error(), e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.Err
+}
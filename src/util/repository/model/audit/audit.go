@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TargetUser = "user"
+
+	ActionUserRoleChanged = "user.role_changed"
+	ActionUserSuspended   = "user.suspended"
+	ActionUserUnsuspended = "user.unsuspended"
+	ActionUserDeleted     = "user.deleted"
+	ActionUserRestored    = "user.restored"
+	ActionUserLoggedOut   = "user.logged_out"
+
+	ActionUserDeletionRequested = "user.deletion_requested"
+)
+
+// Entry records an administrative change, who made it and to what.
+type Entry struct {
+	Id         uuid.UUID       `json:"id"`
+	ActorId    uuid.UUID       `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetId   uuid.UUID       `json:"target_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+	CreatedAt  *time.Time      `json:"created_at"`
+}
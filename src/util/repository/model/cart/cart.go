@@ -8,8 +8,8 @@ import (
 type Cart struct {
 	ID        uuid.UUID  `json:"id"`
 	UserID    uuid.UUID  `json:"user_id"`
-	ProductID uuid.UUID  `json:"product_id"`
-	Qty       int        `json:"qty"`
+	ProductID uuid.UUID  `json:"product_id" validate:"required"`
+	Qty       int        `json:"qty" validate:"required,min=1"`
 	CreatedAt *time.Time `json:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
@@ -22,5 +22,5 @@ type GetCartRequest struct {
 
 type DeleteCartRequest struct {
 	UserID    uuid.UUID `json:"user_id"`
-	ProductID uuid.UUID `json:"product_id"`
+	ProductID uuid.UUID `json:"product_id" validate:"required"`
 }
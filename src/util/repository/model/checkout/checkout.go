@@ -0,0 +1,111 @@
+package checkout
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/payment"
+	"user-service/src/util/repository/model/reservations"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a saga. A running saga moves forward step by step, a failed
+// step makes it compensate the steps done so far in reverse order.
+// StatusFailed means a compensation failed or a step was interrupted with an
+// unknown outcome, it needs an admin to look at it.
+const (
+	StatusRunning      = "running"
+	StatusCompleted    = "completed"
+	StatusCompensating = "compensating"
+	StatusCompensated  = "compensated"
+	StatusFailed       = "failed"
+)
+
+// Forward steps of a checkout, in order.
+const (
+	StepPriceOrder    = "price_order"
+	StepCreateOrder   = "create_order"
+	StepReserveStock  = "reserve_stock"
+	StepCreatePayment = "create_payment"
+)
+
+// Compensating steps, undoing StepReserveStock and StepCreateOrder.
+const (
+	StepReleaseStock = "release_stock"
+	StepCancelOrder  = "cancel_order"
+)
+
+// Outcomes of a step in the step log.
+const (
+	StepStarted   = "started"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+)
+
+// OrderCancelled is the order status a compensated checkout leaves behind.
+const OrderCancelled = "Cancelled"
+
+var (
+	ErrSagaNotFound    = apperr.NotFound("checkout_not_found", "checkout not found")
+	ErrNotResumable    = apperr.Conflict("checkout_not_resumable", "only failed checkouts can be resumed")
+	ErrInvalidStatus   = apperr.Validation("invalid_status", "Invalid status")
+	ErrProductNotFound = apperr.Validation("product_not_found", "Product not found")
+	ErrOutOfStock      = reservations.ErrOutOfStock
+)
+
+// Statuses lists every saga status, for validating filters.
+var Statuses = []string{StatusRunning, StatusCompleted, StatusCompensating, StatusCompensated, StatusFailed}
+
+// Saga is one checkout. Step is the step running, or the last one that ran.
+type Saga struct {
+	Id        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Status    string     `json:"status"`
+	Step      string     `json:"step"`
+	State     State      `json:"state"`
+	Error     *string    `json:"error"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+	Steps     []StepLog  `json:"steps,omitempty"`
+}
+
+// State is what the steps produced so far. It is persisted with every step so
+// a resumed saga knows what it has to compensate. Compensated lists the
+// compensating steps that already succeeded.
+type State struct {
+	Request     order.CreateOrderRequest       `json:"request"`
+	OrderID     string                         `json:"order_id,omitempty"`
+	Stock       []reservations.Item            `json:"stock,omitempty"`
+	Payment     *payment.CreatePaymentResponse `json:"payment,omitempty"`
+	Compensated []string                       `json:"compensated,omitempty"`
+}
+
+func (s *State) IsCompensated(step string) bool {
+	for _, done := range s.Compensated {
+		if done == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StepLog is one entry of the history of a saga.
+type StepLog struct {
+	Step      string     `json:"step"`
+	Status    string     `json:"status"`
+	Error     *string    `json:"error"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type RequestSagas struct {
+	Status string
+	Page   int
+	Limit  int
+}
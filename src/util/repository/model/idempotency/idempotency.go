@@ -0,0 +1,42 @@
+package idempotency
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/google/uuid"
+)
+
+// Header is the request header carrying the key chosen by the client.
+const Header = "Idempotency-Key"
+
+// MaxKeyLength is the longest key the idempotency_keys table can hold.
+const MaxKeyLength = 255
+
+var (
+	ErrInvalidKey        = apperr.Validation("invalid_idempotency_key", "Idempotency-Key must be 1-255 characters")
+	ErrKeyReused         = apperr.Conflict("idempotency_key_reused", "Idempotency-Key was already used with a different request")
+	ErrRequestInProgress = apperr.Conflict("idempotency_request_in_progress", "a request with this Idempotency-Key is still in progress")
+)
+
+// Record is a request made with an Idempotency-Key. StatusCode and Response
+// are set once the request completed, a record without them is still in
+// progress until LockedUntil. After that its request is taken to be lost and
+// a retry may claim the key again. SagaID is the checkout the request
+// started, a retry that takes the key over answers with it instead of
+// starting another one.
+type Record struct {
+	UserID      uuid.UUID
+	Key         string
+	RequestHash string
+	StatusCode  *int
+	Response    []byte
+	CreatedAt   *time.Time
+	CompletedAt *time.Time
+	LockedUntil *time.Time
+	SagaID      *uuid.UUID
+}
+
+func (r *Record) Completed() bool {
+	return r.CompletedAt != nil
+}
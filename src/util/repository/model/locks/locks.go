@@ -0,0 +1,23 @@
+package locks
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+)
+
+// Timeout is how long a request waits for the locks held by other requests
+// before giving up.
+const Timeout = 10 * time.Second
+
+// HoldTimeout is how long locks are held at most, waiting for them included.
+// Remote calls made under a lock use its context, a slow service cannot keep
+// the lock and its connection any longer.
+const HoldTimeout = 30 * time.Second
+
+var ErrLockTimeout = apperr.New(apperr.KindUnavailable, "resource_busy", "the requested items are busy, try again")
+
+// ProductKey is the lock key of a product, every lock on product stock uses it
+// so checkouts and stock jobs on any instance exclude each other.
+func ProductKey(productID string) string {
+	return "product:" + productID
+}
@@ -0,0 +1,51 @@
+package mfa
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMFAAlreadyEnabled = apperr.Conflict("mfa_already_enabled", "two-factor authentication is already enabled")
+	ErrMFANotEnabled     = apperr.Validation("mfa_not_enabled", "two-factor authentication is not enabled")
+	ErrMFANotSetup       = apperr.Validation("mfa_not_set_up", "two-factor authentication has not been set up")
+	ErrInvalidMFACode    = apperr.Validation("invalid_mfa_code", "invalid two-factor authentication code")
+	ErrInvalidMFAToken   = apperr.Unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+)
+
+// MFA is the TOTP enrollment of a user. It only guards the login once
+// EnabledAt is set, that is after the user confirmed a first code.
+type MFA struct {
+	UserId       uuid.UUID  `json:"user_id"`
+	Secret       string     `json:"-"`
+	LastUsedStep *int64     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	CreatedAt    *time.Time `json:"created_at"`
+}
+
+type SetupResponse struct {
+	Secret        string   `json:"secret"`
+	OtpauthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ChallengeResponse is returned by the login instead of the tokens while the
+// second factor is still missing.
+type ChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type CodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type VerifyRequest struct {
+	MFAToken  string `json:"mfa_token" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+	UserAgent string `json:"-"`
+	IpAddress string `json:"-"`
+}
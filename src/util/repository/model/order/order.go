@@ -1,11 +1,23 @@
 package order
 
 import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
 	"time"
+	"user-service/src/util/apperr"
 
 	"github.com/google/uuid"
 )
 
+// Errors of the order service, as passed on to the client.
+var (
+	ErrOrderNotFound           = apperr.NotFound("order_not_found", "order not found")
+	ErrOrderForbidden          = apperr.Forbidden("order_forbidden", "the order belongs to another user")
+	ErrOrderRejected           = apperr.Validation("order_rejected", "the order service rejected the request")
+	ErrOrderServiceUnavailable = apperr.New(apperr.KindUnavailable, "order_service_unavailable", "order service is unavailable")
+)
+
 type CreateOrderRequest struct {
 	UserID uuid.UUID `json:"user_id" validate:"required"`
 
@@ -19,8 +31,8 @@ type CreateOrderRequest struct {
 	// User
 	PaymentTypeID uuid.UUID      `json:"payment_type_id" validate:"required"`
 	OrderNumber   string         `json:"order_number" validate:"required"`
-	TotalPrice    float64        `json:"total_price" validate:"required"`
-	ProductOrder  []ProductOrder `json:"product_order"`
+	TotalPrice    float64        `json:"total_price"`
+	ProductOrder  []ProductOrder `json:"product_order" validate:"required,min=1,dive"`
 	Status        string         `json:"status" validate:"required"`
 	IsPaid        bool           `json:"is_paid"`
 	RefCode       string         `json:"ref_code"`
@@ -31,6 +43,14 @@ type CreateOrderRequest struct {
 	PaymentType        string             `json:"payment_type"`
 	TransactionDetails TransactionDetails `json:"transaction_details"`
 	BankTransfer       BankTransfer       `json:"bank_transfer"`
+	CustomExpiry       *CustomExpiry      `json:"custom_expiry,omitempty"`
+}
+
+// CustomExpiry is how long the payment stays payable, counted by Midtrans
+// from when it is created.
+type CustomExpiry struct {
+	ExpiryDuration int    `json:"expiry_duration"`
+	Unit           string `json:"unit"`
 }
 
 type BankTransfer struct {
@@ -48,10 +68,10 @@ type UpdateQtyRequest struct {
 }
 
 type ProductOrder struct {
-	ProductID     string  `json:"product_id"`
+	ProductID     string  `json:"product_id" validate:"required,uuid"`
 	ProductName   string  `json:"product_name"`
 	Price         float64 `json:"price"`
-	Qty           int     `json:"qty"`
+	Qty           int     `json:"qty" validate:"required,min=1"`
 	SubtotalPrice float64 `json:"subtotal_price"`
 }
 
@@ -77,6 +97,19 @@ type RequestFromMidtrans struct {
 	ApprovalCode           string `json:"approval_code"`
 }
 
+// ValidSignature reports whether the notification was signed by Midtrans
+// with the server key: signature_key is the hex SHA512 of order_id,
+// status_code, gross_amount and the server key.
+func (n *RequestFromMidtrans) ValidSignature(serverKey string) bool {
+	if serverKey == "" || n.SignatureKey == "" {
+		return false
+	}
+
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + serverKey))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(n.SignatureKey)) == 1
+}
+
 type RequestCallback struct {
 	OrderId   string     `json:"order_id"`
 	Status    string     `json:"status"`
@@ -103,9 +136,9 @@ type Order struct {
 }
 
 type UpdateStatus struct {
-	UserID  uuid.UUID `json:"user_id"`
-	OrderID uuid.UUID `json:"order_id"`
-	Status  string    `json:"status"`
+	UserID  uuid.UUID `json:"user_id" validate:"required"`
+	OrderID uuid.UUID `json:"order_id" validate:"required"`
+	Status  string    `json:"status" validate:"required"`
 }
 
 type RequestUpdateShipping struct {
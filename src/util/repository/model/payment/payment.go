@@ -1,5 +1,23 @@
 package payment
 
+import "user-service/src/util/apperr"
+
+var ErrInvalidSignature = apperr.Forbidden("invalid_signature", "invalid notification signature")
+
+// Transaction statuses sent by Midtrans. Capture and settlement mean the
+// payment succeeded, unless a capture is challenged by fraud detection.
+const (
+	TxStatusCapture    = "capture"
+	TxStatusSettlement = "settlement"
+	TxStatusPending    = "pending"
+	TxStatusDeny       = "deny"
+	TxStatusCancel     = "cancel"
+	TxStatusExpire     = "expire"
+	TxStatusFailure    = "failure"
+
+	FraudStatusChallenge = "challenge"
+)
+
 type CreatePaymentResponse struct {
 	StatusCode    string     `json:"status_code"`
 	StatusMessage string     `json:"status_message"`
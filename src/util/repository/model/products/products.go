@@ -16,7 +16,7 @@ type Response struct {
 
 // SHOPS SECTION
 type CreateShopRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=255"`
 }
 
 type GetShopsRequest struct {
@@ -0,0 +1,56 @@
+package reservations
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a reservation. A reserved quantity is held back from other
+// checkouts until the payment settles (StatusConfirmed, the stock is taken
+// from the product service) or fails or expires (StatusReleased).
+// StatusConfirming is written with the stock before and after the take,
+// before the product service is called, so a confirmation interrupted after
+// the call is not applied twice.
+const (
+	StatusReserved   = "reserved"
+	StatusConfirming = "confirming"
+	StatusConfirmed  = "confirmed"
+	StatusReleased   = "released"
+)
+
+// TTL is how long a reservation waits for its payment. The payment is
+// created after the reservation and expires PaymentExpiry later, the margin
+// keeps the reservation held until no payment can settle anymore.
+const (
+	TTL           = 24 * time.Hour
+	PaymentExpiry = TTL - time.Hour
+)
+
+var (
+	ErrOutOfStock       = apperr.Validation("product_out_of_stock", "Product out of stock")
+	ErrStockUnavailable = apperr.Conflict("reserved_stock_unavailable", "the stock of a paid order is no longer available")
+	ErrStockChanged     = apperr.Conflict("stock_changed", "the stock changed while taking it for a paid order")
+	ErrNotConfirmable   = apperr.Conflict("reservation_changed", "the reservation changed while confirming it")
+)
+
+// Item is the quantity of a product a checkout reserves.
+type Item struct {
+	ProductID string `json:"product_id"`
+	Qty       int    `json:"qty"`
+}
+
+type Reservation struct {
+	Id          uuid.UUID  `json:"id"`
+	SagaID      uuid.UUID  `json:"saga_id"`
+	OrderID     string     `json:"order_id"`
+	ProductID   string     `json:"product_id"`
+	Qty         int        `json:"qty"`
+	Status      string     `json:"status"`
+	StockBefore *int       `json:"stock_before"`
+	StockAfter  *int       `json:"stock_after"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
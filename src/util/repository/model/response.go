@@ -1,8 +1,11 @@
 package model
 
+import "user-service/src/util/apperr"
+
 type BaseResponse struct {
-	Message interface{} `json:"message"`
-	Data    interface{} `json:"data"`
+	Message interface{}    `json:"message"`
+	Data    interface{}    `json:"data"`
+	Error   *apperr.Detail `json:"error,omitempty"`
 }
 
 type BaseModel struct {
@@ -11,4 +14,5 @@ type BaseModel struct {
 	TotalPage    int         `json:"total_page"`
 	FilteredItem int         `json:"filtered_item"`
 	FilteredPage int         `json:"filtered_page"`
+	NextCursor   string      `json:"next_cursor,omitempty"`
 }
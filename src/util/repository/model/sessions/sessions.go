@@ -0,0 +1,30 @@
+package sessions
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSessionNotFound = apperr.NotFound("session_not_found", "session not found")
+)
+
+// Session is a device the user is logged in from. Its id is also the family
+// id of the refresh tokens issued for it.
+type Session struct {
+	Id         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IpAddress  string     `json:"ip_address"`
+	CreatedAt  *time.Time `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+type ClientInfo struct {
+	UserAgent string
+	IpAddress string
+}
@@ -0,0 +1,28 @@
+package tokens
+
+import (
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = apperr.Unauthorized("invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenReused  = apperr.Unauthorized("refresh_token_reused", "refresh token already used, all sessions of this token were revoked")
+	ErrTokenRevoked        = apperr.Unauthorized("token_revoked", "token has been revoked")
+)
+
+type RefreshToken struct {
+	Id        uuid.UUID  `json:"id"`
+	FamilyId  uuid.UUID  `json:"family_id"`
+	UserId    uuid.UUID  `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
@@ -1,11 +1,30 @@
 package users
 
 import (
+	"strings"
 	"time"
+	"user-service/src/util/apperr"
+	"user-service/src/util/repository/model/order"
+	"user-service/src/util/repository/model/sessions"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrUserAlreadyRegistered = apperr.Conflict("user_already_registered", "user already registered")
+	ErrInvalidCredentials    = apperr.Unauthorized("invalid_credentials", "invalid email or password")
+	ErrEmailNotVerified      = apperr.Forbidden("email_not_verified", "email address is not verified yet")
+	ErrInvalidVerification   = apperr.Validation("invalid_verification_token", "invalid or expired verification token")
+	ErrInvalidPasswordReset  = apperr.Validation("invalid_password_reset_token", "invalid or expired password reset token")
+	ErrUserNotFound          = apperr.NotFound("user_not_found", "user not found")
+	ErrUserSuspended         = apperr.Forbidden("user_suspended", "account is suspended")
+	ErrUserNotRegistered     = apperr.Conflict("user_not_registered", "user not yet registered")
+	ErrInvalidOAuthState     = apperr.Unauthorized("invalid_oauth_state", "invalid user state")
+	ErrOAuthExchange         = apperr.Unauthorized("oauth_exchange_failed", "cannot retrieve token")
+	ErrInvalidOAuthToken     = apperr.Unauthorized("invalid_oauth_token", "invalid token signature")
+	ErrOAuthUnavailable      = apperr.New(apperr.KindUnavailable, "oauth_unavailable", "cannot retrieve response")
+)
+
 type Users struct {
 	Id                  uuid.UUID  `json:"id"`
 	Email               string     `json:"email"`
@@ -16,12 +35,28 @@ type Users struct {
 	CreatedAt           *time.Time `json:"created_at"`
 	UpdatedAt           *time.Time `json:"updated_at"`
 	DeletedAt           *time.Time `json:"deleted_at"`
+	Password            string     `json:"-"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at"`
+	SuspendedAt         *time.Time `json:"suspended_at"`
+	AnonymizeAfter      *time.Time `json:"anonymize_after"`
+	AnonymizedAt        *time.Time `json:"anonymized_at"`
+}
+
+type RegisterRequest struct {
+	Email               string   `json:"email" validate:"required,email,max=255"`
+	Username            string   `json:"username" validate:"required,min=3,max=100"`
+	Password            string   `json:"password" validate:"required,password"`
+	Role                string   `json:"role" validate:"omitempty,oneof=User Seller"`
+	Address             string   `json:"address"`
+	CategoryPreferences []string `json:"category_preferences"`
 }
 
 type UsersLogin struct {
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Email     string `json:"email" validate:"required,email"`
+	Username  string `json:"username"`
+	Password  string `json:"password" validate:"required"`
+	UserAgent string `json:"-"`
+	IpAddress string `json:"-"`
 }
 
 type OauthUserData struct {
@@ -45,11 +80,77 @@ type LoginResponse struct {
 	*Users
 }
 
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
 type RequestUsers struct {
-	Search string    `json:"search"`
-	UserId uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
-	Page   int       `json:"page"`
-	Limit  int       `json:"limit"`
-	Role   string    `json:"role"`
+	Search         string    `json:"search"`
+	UserId         uuid.UUID `json:"user_id"`
+	Email          string    `json:"email"`
+	Page           int       `json:"page"`
+	Limit          int       `json:"limit"`
+	Role           string    `json:"role"`
+	IncludeDeleted bool      `json:"include_deleted"`
+	Sort           string    `json:"sort"`
+	Cursor         string    `json:"cursor"`
+
+	// CreatedFrom and CreatedTo bound created_at, both inclusive
+	CreatedFrom *time.Time `json:"created_from"`
+	CreatedTo   *time.Time `json:"created_to"`
+
+	// Categories matches users preferring any of the categories
+	Categories []string `json:"categories"`
+}
+
+// Ranked reports whether the users are ordered by search relevance instead
+// of the keyset order (created_at, id) that cursors follow.
+func (r RequestUsers) Ranked() bool {
+	return r.Search != "" && r.Sort == "" && r.Cursor == ""
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// UpdateProfileRequest holds the fields users may change on their own
+// profile. The role is changed through the admin endpoint.
+type UpdateProfileRequest struct {
+	Email               string   `json:"email" validate:"required,email,max=255"`
+	Address             string   `json:"address"`
+	CategoryPreferences []string `json:"category_preferences"`
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=Admin Seller User"`
+}
+
+// DeletionResponse tells the user when the account will be anonymized, until
+// then an admin can still restore it.
+type DeletionResponse struct {
+	AnonymizeAfter time.Time `json:"anonymize_after"`
+}
+
+// Export is the archive of everything stored about a user.
+type Export struct {
+	Profile    *Users             `json:"profile"`
+	Sessions   []sessions.Session `json:"sessions"`
+	Orders     []order.Order      `json:"orders"`
+	ExportedAt time.Time          `json:"exported_at"`
+}
+
+// NormalizeEmail returns the form emails are stored in. Lookups compare
+// emails case-insensitively as well, for accounts stored before.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -0,0 +1,224 @@
+// Package query builds SQL statements whose values are always passed as
+// positional $n arguments, never written into the statement itself. Every
+// list query goes through Builder; single row lookups and writes keep their
+// static statements, which take $n arguments as well.
+package query
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/src/util/apperr"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidSort   = apperr.Validation("invalid_sort", "invalid sort field")
+	ErrInvalidCursor = apperr.Validation("invalid_cursor", "invalid cursor")
+)
+
+// Builder builds a SELECT statement with dynamic filters, sorting and
+// pagination. Conditions use ? for their values, Build numbers them.
+type Builder struct {
+	base       string
+	conditions []string
+	args       []interface{}
+	groupBy    string
+	orderBy    string
+	limit      int
+	offset     int
+}
+
+// Select starts a statement from everything up to, but not including, the
+// WHERE clause. A base that already uses placeholders, e.g. a statement built
+// earlier and wrapped as a subquery, passes their values as args.
+func Select(base string, args ...interface{}) *Builder {
+	return &Builder{base: strings.TrimSpace(base), args: args}
+}
+
+// Where adds a condition, all conditions are joined with AND. Every ? in the
+// condition takes the next value of args. It panics when the number of ? and
+// args differ, which is always a bug in the caller.
+func (b *Builder) Where(condition string, args ...interface{}) *Builder {
+	if n := strings.Count(condition, "?"); n != len(args) {
+		panic(fmt.Sprintf("query: condition %q has %d placeholders but %d args", condition, n, len(args)))
+	}
+
+	var sb strings.Builder
+	next := 0
+	for _, r := range condition {
+		if r == '?' {
+			sb.WriteString(b.Arg(args[next]))
+			next++
+			continue
+		}
+		sb.WriteRune(r)
+	}
+
+	b.conditions = append(b.conditions, "("+sb.String()+")")
+	return b
+}
+
+// WhereIf adds the condition only when ok is true, for optional filters.
+func (b *Builder) WhereIf(ok bool, condition string, args ...interface{}) *Builder {
+	if !ok {
+		return b
+	}
+
+	return b.Where(condition, args...)
+}
+
+// Arg adds a value and returns its placeholder, for the rare parts of a
+// statement that Where does not cover.
+func (b *Builder) Arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// GroupBy sets the GROUP BY clause.
+func (b *Builder) GroupBy(clause string) *Builder {
+	b.groupBy = clause
+	return b
+}
+
+// OrderBy sets the ORDER BY clause, usually the result of Sorting.Parse.
+func (b *Builder) OrderBy(clause string) *Builder {
+	b.orderBy = clause
+	return b
+}
+
+// Limit caps the number of rows, a limit of 0 means no limit.
+func (b *Builder) Limit(limit int) *Builder {
+	b.limit = limit
+	return b
+}
+
+func (b *Builder) Offset(offset int) *Builder {
+	b.offset = offset
+	return b
+}
+
+// Paginate sets limit and offset for a 1-based page.
+func (b *Builder) Paginate(page int, limit int) *Builder {
+	if page < 1 {
+		page = 1
+	}
+
+	return b.Limit(limit).Offset((page - 1) * limit)
+}
+
+// Build returns the statement and its arguments.
+func (b *Builder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(b.base)
+
+	if len(b.conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(b.conditions, " AND "))
+	}
+
+	if b.groupBy != "" {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(b.groupBy)
+	}
+
+	if b.orderBy != "" {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(b.orderBy)
+	}
+
+	args := b.args
+	if b.limit > 0 {
+		args = append(args, b.limit)
+		sb.WriteString(" LIMIT $" + strconv.Itoa(len(args)))
+	}
+
+	if b.offset > 0 {
+		args = append(args, b.offset)
+		sb.WriteString(" OFFSET $" + strconv.Itoa(len(args)))
+	}
+
+	return sb.String(), args
+}
+
+// Sorting whitelists the sort keys clients may use, mapped to the column
+// they sort by. Column names never come from the request.
+type Sorting struct {
+	Columns map[string]string
+	Default string
+}
+
+// Parse turns a comma separated list of keys, each optionally prefixed with
+// - for descending order, into an ORDER BY clause. An empty sort falls back
+// to the default.
+func (s Sorting) Parse(sort string) (string, error) {
+	if strings.TrimSpace(sort) == "" {
+		return s.Default, nil
+	}
+
+	var clauses []string
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			key = key[1:]
+			direction = "DESC"
+		}
+
+		column, ok := s.Columns[key]
+		if !ok {
+			return "", ErrInvalidSort
+		}
+
+		clauses = append(clauses, column+" "+direction)
+	}
+
+	return strings.Join(clauses, ", "), nil
+}
+
+// Contains returns a LIKE pattern matching value anywhere, with the LIKE
+// wildcards in value escaped.
+func Contains(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Cursor points at the last row of a page for keyset pagination on
+// (created_at, id), clients get it as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time
+	Id        uuid.UUID
+}
+
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.Id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(value string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if cursor.Id, err = uuid.Parse(id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	cursor.CreatedAt = cursor.CreatedAt.UTC()
+	return cursor, nil
+}
@@ -0,0 +1,132 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Run("without conditions", func(t *testing.T) {
+		statement, args := Select("SELECT id FROM users").Build()
+
+		assert.Equal(t, "SELECT id FROM users", statement)
+		assert.Empty(t, args)
+	})
+
+	t.Run("numbers placeholders across conditions and pagination", func(t *testing.T) {
+		statement, args := Select("SELECT id FROM users").
+			Where("deleted_at IS NULL").
+			Where("email = ?", "a@example.com").
+			WhereIf(false, "role = ?", "Admin").
+			Where("(email ILIKE ? OR username ILIKE ?)", "%a%", "%a%").
+			OrderBy("created_at DESC").
+			Paginate(3, 10).
+			Build()
+
+		assert.Equal(t, "SELECT id FROM users WHERE (deleted_at IS NULL) AND (email = $1) AND ((email ILIKE $2 OR username ILIKE $3)) ORDER BY created_at DESC LIMIT $4 OFFSET $5", statement)
+		assert.Equal(t, []interface{}{"a@example.com", "%a%", "%a%", 10, 20}, args)
+	})
+
+	t.Run("keeps injection attempts out of the statement", func(t *testing.T) {
+		statement, args := Select("SELECT id FROM users").
+			Where("email = ?", "' OR '1'='1").
+			Build()
+
+		assert.Equal(t, "SELECT id FROM users WHERE (email = $1)", statement)
+		assert.Equal(t, []interface{}{"' OR '1'='1"}, args)
+	})
+
+	t.Run("groups before ordering", func(t *testing.T) {
+		statement, args := Select("SELECT product_id, SUM(qty) FROM stock_reservations").
+			Where("status = ?", "held").
+			GroupBy("product_id").
+			OrderBy("product_id").
+			Build()
+
+		assert.Equal(t, "SELECT product_id, SUM(qty) FROM stock_reservations WHERE (status = $1) GROUP BY product_id ORDER BY product_id", statement)
+		assert.Equal(t, []interface{}{"held"}, args)
+	})
+
+	t.Run("panics when placeholders and args differ", func(t *testing.T) {
+		assert.Panics(t, func() { Select("SELECT id FROM users").Where("email = ? OR username = ?", "a") })
+		assert.Panics(t, func() { Select("SELECT id FROM users").Where("email = ?", "a", "b") })
+	})
+
+	t.Run("first page has no offset", func(t *testing.T) {
+		statement, args := Select("SELECT id FROM users").Paginate(1, 5).Build()
+
+		assert.Equal(t, "SELECT id FROM users LIMIT $1", statement)
+		assert.Equal(t, []interface{}{5}, args)
+	})
+}
+
+func TestBuilder_Subquery(t *testing.T) {
+	inner, args := Select("SELECT *, COUNT(*) OVER() AS total_count FROM users").
+		Where("role = ?", "Seller").
+		Build()
+
+	statement, args := Select("SELECT * FROM ("+inner+") AS filtered", args...).
+		Where("id < ?", 5).
+		Limit(10).
+		Build()
+
+	assert.Equal(t, "SELECT * FROM (SELECT *, COUNT(*) OVER() AS total_count FROM users WHERE (role = $1)) AS filtered WHERE (id < $2) LIMIT $3", statement)
+	assert.Equal(t, []interface{}{"Seller", 5, 10}, args)
+}
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{
+		CreatedAt: time.Date(2024, 7, 1, 10, 30, 0, 123456000, time.UTC),
+		Id:        uuid.New(),
+	}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.Id, decoded.Id)
+
+	for _, value := range []string{"", "not base64!", "bm8tc2VwYXJhdG9y", Cursor{}.Encode()[:10]} {
+		_, err := DecodeCursor(value)
+		assert.ErrorIs(t, err, ErrInvalidCursor, value)
+	}
+}
+
+func TestSorting_Parse(t *testing.T) {
+	sorting := Sorting{
+		Columns: map[string]string{
+			"created_at": "created_at",
+			"email":      "email",
+		},
+		Default: "created_at DESC",
+	}
+
+	tests := []struct {
+		name    string
+		sort    string
+		want    string
+		wantErr error
+	}{
+		{name: "default", sort: "", want: "created_at DESC"},
+		{name: "ascending", sort: "email", want: "email ASC"},
+		{name: "descending", sort: "-created_at", want: "created_at DESC"},
+		{name: "several keys", sort: "email, -created_at", want: "email ASC, created_at DESC"},
+		{name: "unknown key", sort: "password", wantErr: ErrInvalidSort},
+		{name: "injection", sort: "email; DROP TABLE users", wantErr: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sorting.Parse(tt.sort)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	assert.Equal(t, "%ann%", Contains("ann"))
+	assert.Equal(t, `%50\%\_off\\%`, Contains(`50%_off\`))
+}
@@ -0,0 +1,323 @@
+package reservations
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/reservations"
+	"user-service/src/util/repository/query"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const reservationColumns = `
+	id,
+	saga_id,
+	order_id,
+	product_id,
+	qty,
+	status,
+	stock_before,
+	stock_after,
+	expires_at,
+	created_at,
+	updated_at
+`
+
+// heldStatuses are the statuses whose quantity is not available to other
+// checkouts. A confirming reservation may already be taken from the stock,
+// counting it again only turns buyers away until it is resolved.
+var heldStatuses = []string{reservations.StatusReserved, reservations.StatusConfirming}
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+// Reserve stores the reservations if stock, minus what is already held,
+// covers them, or none of them with ErrOutOfStock. Callers hold the locks of
+// the products so stock stays current until the reservations are stored.
+func (s *store) Reserve(items []reservations.Reservation, stock map[string]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	querySum := `
+		SELECT
+			COALESCE(SUM(qty), 0)
+		FROM
+			stock_reservations
+		WHERE
+			product_id = $1
+			AND status = ANY($2)
+	`
+
+	var productIDs []string
+	wanted := map[string]int{}
+	for _, item := range items {
+		if _, ok := wanted[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		wanted[item.ProductID] += item.Qty
+	}
+
+	for _, productID := range productIDs {
+		var held int
+		if err := tx.QueryRow(querySum, productID, pq.Array(heldStatuses)).Scan(&held); err != nil {
+			return fmt.Errorf("failed to sum reservations: %w", err)
+		}
+
+		if held+wanted[productID] > stock[productID] {
+			return reservations.ErrOutOfStock
+		}
+	}
+
+	queryInsert := `
+		INSERT INTO stock_reservations(
+			id,
+			saga_id,
+			order_id,
+			product_id,
+			qty,
+			status,
+			expires_at,
+			created_at,
+			updated_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$8
+		)
+	`
+
+	for _, item := range items {
+		if _, err := tx.Exec(queryInsert, item.Id, item.SagaID, item.OrderID, item.ProductID, item.Qty, item.Status, item.ExpiresAt.UTC(), item.CreatedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to insert reservation: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOrder returns every reservation of the order.
+func (s *store) GetOrder(orderID string) ([]reservations.Reservation, error) {
+	querySelect, args := query.Select(`
+		SELECT`+reservationColumns+`
+		FROM
+			stock_reservations
+	`).
+		Where("order_id = ?", orderID).
+		OrderBy("product_id").
+		Build()
+
+	return s.query(querySelect, args...)
+}
+
+// GetHeld returns the quantity of each product other checkouts cannot
+// reserve.
+func (s *store) GetHeld(productIDs []string) (map[string]int, error) {
+	querySelect, args := query.Select(`
+		SELECT
+			product_id,
+			SUM(qty)
+		FROM
+			stock_reservations
+	`).
+		Where("product_id = ANY(?)", pq.Array(productIDs)).
+		Where("status = ANY(?)", pq.Array(heldStatuses)).
+		GroupBy("product_id").
+		Build()
+
+	rows, err := s.db.Query(querySelect, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum reservations: %w", err)
+	}
+	defer rows.Close()
+
+	held := map[string]int{}
+	for rows.Next() {
+		var productID string
+		var qty int
+		if err := rows.Scan(&productID, &qty); err != nil {
+			return nil, fmt.Errorf("failed to scan reservation sum: %w", err)
+		}
+		held[productID] = qty
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to sum reservations: %w", err)
+	}
+
+	return held, nil
+}
+
+// GetConfirming returns the reservations of the products whose confirmation
+// was interrupted.
+func (s *store) GetConfirming(productIDs []string) ([]reservations.Reservation, error) {
+	querySelect, args := query.Select(`
+		SELECT`+reservationColumns+`
+		FROM
+			stock_reservations
+	`).
+		Where("product_id = ANY(?)", pq.Array(productIDs)).
+		Where("status = ?", reservations.StatusConfirming).
+		OrderBy("product_id").
+		Build()
+
+	return s.query(querySelect, args...)
+}
+
+// MarkConfirming records the stock before and after taking each reservation.
+// It fails with ErrNotConfirmable, storing nothing, when one of them is no
+// longer in the status it was read with.
+func (s *store) MarkConfirming(items []reservations.Reservation, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queryUpdate := `
+		UPDATE stock_reservations
+		SET
+			status = $3,
+			stock_before = $4,
+			stock_after = $5,
+			updated_at = $6
+		WHERE
+			id = $1
+			AND status = $2
+	`
+
+	for _, item := range items {
+		result, err := tx.Exec(queryUpdate, item.Id, item.Status, reservations.StatusConfirming, item.StockBefore, item.StockAfter, now.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to mark reservation confirming: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return reservations.ErrNotConfirmable
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MarkConfirmed completes the confirming reservations, once their stock is
+// taken.
+func (s *store) MarkConfirmed(ids []uuid.UUID, now time.Time) error {
+	queryUpdate := `
+		UPDATE stock_reservations
+		SET
+			status = $3,
+			updated_at = $4
+		WHERE
+			id = ANY($1)
+			AND status = $2
+	`
+
+	if _, err := s.db.Exec(queryUpdate, pq.Array(ids), reservations.StatusConfirming, reservations.StatusConfirmed, now.UTC()); err != nil {
+		return fmt.Errorf("failed to confirm reservations: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseOrder releases the open reservations of the order.
+func (s *store) ReleaseOrder(orderID string, now time.Time) (int64, error) {
+	return s.release("order_id = $4", now, orderID)
+}
+
+// ReleaseSaga releases the open reservations of the checkout.
+func (s *store) ReleaseSaga(sagaID uuid.UUID, now time.Time) (int64, error) {
+	return s.release("saga_id = $4", now, sagaID)
+}
+
+// ReleaseExpired releases the open reservations that expired before now.
+func (s *store) ReleaseExpired(now time.Time) (int64, error) {
+	return s.release("expires_at < $1", now)
+}
+
+// release only touches reserved rows, a confirming reservation belongs to a
+// paid order.
+func (s *store) release(condition string, now time.Time, args ...interface{}) (int64, error) {
+	queryUpdate := `
+		UPDATE stock_reservations
+		SET
+			status = $2,
+			updated_at = $1
+		WHERE
+			status = $3
+			AND ` + condition
+
+	result, err := s.db.Exec(queryUpdate, append([]interface{}{now.UTC(), reservations.StatusReleased, reservations.StatusReserved}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release reservations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *store) query(querySelect string, args ...interface{}) ([]reservations.Reservation, error) {
+	rows, err := s.db.Query(querySelect, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var result []reservations.Reservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *reservation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get reservations: %w", err)
+	}
+
+	return result, nil
+}
+
+func scanReservation(rows *sql.Rows) (*reservations.Reservation, error) {
+	var reservation reservations.Reservation
+	err := rows.Scan(
+		&reservation.Id,
+		&reservation.SagaID,
+		&reservation.OrderID,
+		&reservation.ProductID,
+		&reservation.Qty,
+		&reservation.Status,
+		&reservation.StockBefore,
+		&reservation.StockAfter,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan reservation: %w", err)
+	}
+
+	return &reservation, nil
+}
@@ -0,0 +1,194 @@
+package reservations
+
+import (
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+	"user-service/src/util/repository/model/reservations"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var reservationColumnNames = []string{
+	"id", "saga_id", "order_id", "product_id", "qty", "status", "stock_before", "stock_after", "expires_at", "created_at", "updated_at",
+}
+
+func newMockStore(t *testing.T) (*store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	return NewStore(db), mock
+}
+
+func newReservation(productID string, qty int) reservations.Reservation {
+	timeNow := time.Date(2024, 8, 1, 9, 0, 0, 0, time.UTC)
+	expiresAt := timeNow.Add(reservations.TTL)
+	return reservations.Reservation{
+		Id:        uuid.New(),
+		SagaID:    uuid.New(),
+		OrderID:   "order-1",
+		ProductID: productID,
+		Qty:       qty,
+		Status:    reservations.StatusReserved,
+		ExpiresAt: &expiresAt,
+		CreatedAt: &timeNow,
+		UpdatedAt: &timeNow,
+	}
+}
+
+func reservationRow(item reservations.Reservation) []driver.Value {
+	var before, after driver.Value
+	if item.StockBefore != nil {
+		before, after = int64(*item.StockBefore), int64(*item.StockAfter)
+	}
+
+	return []driver.Value{item.Id, item.SagaID, item.OrderID, item.ProductID, int64(item.Qty), item.Status, before, after, *item.ExpiresAt, *item.CreatedAt, *item.UpdatedAt}
+}
+
+func TestStore_Reserve(t *testing.T) {
+	querySum := regexp.QuoteMeta("SELECT COALESCE(SUM(qty), 0) FROM stock_reservations WHERE product_id = $1 AND status = ANY($2)")
+	held := pq.Array(heldStatuses)
+	items := []reservations.Reservation{newReservation("p1", 2), newReservation("p2", 1)}
+
+	t.Run("stock covers the held and wanted quantity", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(querySum).WithArgs("p1", held).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(3))
+		mock.ExpectQuery(querySum).WithArgs("p2", held).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+		for _, item := range items {
+			mock.ExpectExec("INSERT INTO stock_reservations").
+				WithArgs(item.Id, item.SagaID, item.OrderID, item.ProductID, item.Qty, reservations.StatusReserved, *item.ExpiresAt, *item.CreatedAt).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		assert.NoError(t, s.Reserve(items, map[string]int{"p1": 5, "p2": 1}))
+	})
+
+	t.Run("out of stock stores nothing", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(querySum).WithArgs("p1", held).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(4))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, s.Reserve(items, map[string]int{"p1": 5, "p2": 1}), reservations.ErrOutOfStock)
+	})
+}
+
+func TestStore_GetOrder(t *testing.T) {
+	s, mock := newMockStore(t)
+	item := newReservation("p1", 2)
+	before, after := 5, 3
+	item.Status, item.StockBefore, item.StockAfter = reservations.StatusConfirming, &before, &after
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM stock_reservations WHERE (order_id = $1) ORDER BY product_id")).
+		WithArgs("order-1").
+		WillReturnRows(sqlmock.NewRows(reservationColumnNames).AddRow(reservationRow(item)...))
+
+	result, err := s.GetOrder("order-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []reservations.Reservation{item}, result)
+}
+
+func TestStore_MarkConfirming(t *testing.T) {
+	queryUpdate := regexp.QuoteMeta("UPDATE stock_reservations SET status = $3, stock_before = $4, stock_after = $5, updated_at = $6 WHERE id = $1 AND status = $2")
+	now := time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+	before, after := 5, 3
+	item := newReservation("p1", 2)
+	item.StockBefore, item.StockAfter = &before, &after
+
+	t.Run("records the take", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(queryUpdate).
+			WithArgs(item.Id, reservations.StatusReserved, reservations.StatusConfirming, &before, &after, now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, s.MarkConfirming([]reservations.Reservation{item}, now))
+	})
+
+	t.Run("reservation changed meanwhile", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(queryUpdate).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, s.MarkConfirming([]reservations.Reservation{item}, now), reservations.ErrNotConfirmable)
+	})
+}
+
+func TestStore_MarkConfirmed(t *testing.T) {
+	s, mock := newMockStore(t)
+	now := time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+	ids := []uuid.UUID{uuid.New()}
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock_reservations SET status = $3, updated_at = $4 WHERE id = ANY($1) AND status = $2")).
+		WithArgs(pq.Array(ids), reservations.StatusConfirming, reservations.StatusConfirmed, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, s.MarkConfirmed(ids, now))
+}
+
+func TestStore_Release(t *testing.T) {
+	now := time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+	queryRelease := "UPDATE stock_reservations SET status = \\$2, updated_at = \\$1 WHERE status = \\$3 AND "
+	sagaID := uuid.New()
+
+	tests := []struct {
+		name    string
+		release func(s *store) (int64, error)
+		where   string
+		args    []driver.Value
+	}{
+		{
+			name:    "order",
+			release: func(s *store) (int64, error) { return s.ReleaseOrder("order-1", now) },
+			where:   regexp.QuoteMeta("order_id = $4"),
+			args:    []driver.Value{now, reservations.StatusReleased, reservations.StatusReserved, "order-1"},
+		},
+		{
+			name:    "saga",
+			release: func(s *store) (int64, error) { return s.ReleaseSaga(sagaID, now) },
+			where:   regexp.QuoteMeta("saga_id = $4"),
+			args:    []driver.Value{now, reservations.StatusReleased, reservations.StatusReserved, sagaID},
+		},
+		{
+			name:    "expired",
+			release: func(s *store) (int64, error) { return s.ReleaseExpired(now) },
+			where:   regexp.QuoteMeta("expires_at < $1"),
+			args:    []driver.Value{now, reservations.StatusReleased, reservations.StatusReserved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockStore(t)
+			mock.ExpectExec(queryRelease + tt.where).WithArgs(tt.args...).WillReturnResult(sqlmock.NewResult(0, 2))
+
+			affected, err := tt.release(s)
+
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), affected)
+		})
+	}
+
+	t.Run("database error", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		mock.ExpectExec(queryRelease).WillReturnError(errors.New("connection reset"))
+
+		_, err := s.ReleaseExpired(now)
+
+		assert.ErrorContains(t, err, "connection reset")
+	})
+}
@@ -0,0 +1,197 @@
+package sessions
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/sessions"
+	"user-service/src/util/repository/query"
+
+	"github.com/google/uuid"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) CreateSession(bReq sessions.Session) error {
+	queryCreate := `
+		INSERT INTO sessions(
+			id,
+			user_id,
+			user_agent,
+			ip_address,
+			created_at,
+			last_seen_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			now(),
+			now()
+		)
+	`
+
+	if _, err := s.db.Exec(
+		queryCreate,
+		bReq.Id,
+		bReq.UserId,
+		bReq.UserAgent,
+		bReq.IpAddress,
+	); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *store) GetSessions(userID uuid.UUID) (*[]sessions.Session, error) {
+	querySelect, args := query.Select(`
+		SELECT
+			id,
+			user_id,
+			user_agent,
+			ip_address,
+			created_at,
+			last_seen_at,
+			revoked_at
+		FROM
+			sessions
+	`).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		OrderBy("last_seen_at DESC").
+		Build()
+
+	rows, err := s.db.Query(querySelect, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	sessionsData := []sessions.Session{}
+	for rows.Next() {
+		var session sessions.Session
+		if err := rows.Scan(
+			&session.Id,
+			&session.UserId,
+			&session.UserAgent,
+			&session.IpAddress,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.RevokedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rows: %v", err)
+		}
+		sessionsData = append(sessionsData, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %v", err)
+	}
+
+	return &sessionsData, nil
+}
+
+func (s *store) GetSession(id uuid.UUID) (*sessions.Session, error) {
+	querySelect := `
+		SELECT
+			id,
+			user_id,
+			user_agent,
+			ip_address,
+			created_at,
+			last_seen_at,
+			revoked_at
+		FROM
+			sessions
+		WHERE
+			id = $1
+	`
+
+	var session sessions.Session
+	if err := s.db.QueryRow(querySelect, id).Scan(
+		&session.Id,
+		&session.UserId,
+		&session.UserAgent,
+		&session.IpAddress,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sessions.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch session: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (s *store) RevokeSession(id uuid.UUID, userID uuid.UUID) error {
+	queryUpdate := `
+		UPDATE sessions
+		SET
+			revoked_at = now()
+		WHERE
+			id = $1
+			AND user_id = $2
+			AND revoked_at IS NULL
+	`
+
+	result, err := s.db.Exec(queryUpdate, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sessions.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (s *store) RevokeUserSessions(userID uuid.UUID) error {
+	queryUpdate := `
+		UPDATE sessions
+		SET
+			revoked_at = now()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+
+	if _, err := s.db.Exec(queryUpdate, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *store) TouchSession(id uuid.UUID, lastSeenAt time.Time) error {
+	queryUpdate := `
+		UPDATE sessions
+		SET
+			last_seen_at = $1
+		WHERE
+			id = $2
+	`
+
+	if _, err := s.db.Exec(queryUpdate, lastSeenAt.UTC(), id); err != nil {
+		return fmt.Errorf("failed to update session last seen: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package tokens
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/tokens"
+
+	"github.com/google/uuid"
+)
+
+type store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *store {
+	return &store{
+		db: db,
+	}
+}
+
+func (s *store) CreateRefreshToken(bReq tokens.RefreshToken) error {
+	queryCreate := `
+		INSERT INTO refresh_tokens(
+			id,
+			family_id,
+			user_id,
+			expires_at,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			now()
+		)
+	`
+
+	if _, err := s.db.Exec(
+		queryCreate,
+		bReq.Id,
+		bReq.FamilyId,
+		bReq.UserId,
+		bReq.ExpiresAt.UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// UseRefreshToken marks the refresh token as used and returns it as it was
+// before this call, so a non-nil UsedAt means the token is being replayed.
+func (s *store) UseRefreshToken(id uuid.UUID) (*tokens.RefreshToken, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	querySelect := `
+		SELECT
+			id,
+			family_id,
+			user_id,
+			expires_at,
+			used_at,
+			revoked_at,
+			created_at
+		FROM
+			refresh_tokens
+		WHERE
+			id = $1
+		FOR UPDATE
+	`
+
+	var response tokens.RefreshToken
+	if err := tx.QueryRow(querySelect, id).Scan(
+		&response.Id,
+		&response.FamilyId,
+		&response.UserId,
+		&response.ExpiresAt,
+		&response.UsedAt,
+		&response.RevokedAt,
+		&response.CreatedAt,
+	); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, tokens.ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to fetch refresh token: %w", err)
+	}
+
+	if response.UsedAt == nil && response.RevokedAt == nil {
+		queryUpdate := `
+			UPDATE refresh_tokens
+			SET
+				used_at = now()
+			WHERE
+				id = $1
+		`
+		if _, err := tx.Exec(queryUpdate, id); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to mark refresh token as used: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (s *store) RevokeFamily(familyID uuid.UUID) error {
+	queryUpdate := `
+		UPDATE refresh_tokens
+		SET
+			revoked_at = now()
+		WHERE
+			family_id = $1
+			AND revoked_at IS NULL
+	`
+
+	if _, err := s.db.Exec(queryUpdate, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+func (s *store) RevokeToken(jti uuid.UUID, userID uuid.UUID, expiresAt time.Time) error {
+	queryCreate := `
+		INSERT INTO revoked_tokens(
+			jti,
+			user_id,
+			expires_at,
+			revoked_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			now()
+		) ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := s.db.Exec(queryCreate, jti, userID, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// UseToken marks a single-use token as used by adding it to the denylist. It
+// returns false when the token was used or revoked before.
+func (s *store) UseToken(jti uuid.UUID, userID uuid.UUID, expiresAt time.Time) (bool, error) {
+	queryCreate := `
+		INSERT INTO revoked_tokens(
+			jti,
+			user_id,
+			expires_at,
+			revoked_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			now()
+		) ON CONFLICT (jti) DO NOTHING
+	`
+
+	result, err := s.db.Exec(queryCreate, jti, userID, expiresAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to use token: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use token: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+func (s *store) IsTokenRevoked(jti uuid.UUID) (bool, error) {
+	querySelect := `
+		SELECT EXISTS (
+			SELECT 1 FROM revoked_tokens WHERE jti = $1
+		)
+	`
+
+	var revoked bool
+	if err := s.db.QueryRow(querySelect, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredTokens removes the revoked tokens that expired before the given
+// time, they are rejected by their exp claim anyway.
+func (s *store) DeleteExpiredTokens(before time.Time) (int64, error) {
+	queryDelete := `
+		DELETE FROM revoked_tokens
+		WHERE
+			expires_at < $1
+	`
+
+	result, err := s.db.Exec(queryDelete, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// RevokeUserTokens invalidates every token issued to the user until now: access
+// tokens through the revoked_before cut-off and refresh tokens directly.
+func (s *store) RevokeUserTokens(userID uuid.UUID) (*time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	queryUpsert := `
+		INSERT INTO user_token_revocations(
+			user_id,
+			revoked_before
+		) VALUES (
+			$1,
+			$2
+		) ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before
+	`
+
+	// Token timestamps are UTC, store the cut-off the same way regardless of
+	// the database time zone. The iat claim only has whole seconds, so the
+	// cut-off is truncated too or a token issued in the same second right
+	// after the revocation would count as issued before it.
+	revokedBefore := time.Now().UTC().Truncate(time.Second)
+	if _, err := tx.Exec(queryUpsert, userID, revokedBefore); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	queryUpdate := `
+		UPDATE refresh_tokens
+		SET
+			revoked_at = now()
+		WHERE
+			user_id = $1
+			AND revoked_at IS NULL
+	`
+	if _, err := tx.Exec(queryUpdate, userID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &revokedBefore, nil
+}
+
+func (s *store) GetRevokedBefore(userID uuid.UUID) (*time.Time, error) {
+	querySelect := `
+		SELECT
+			revoked_before
+		FROM
+			user_token_revocations
+		WHERE
+			user_id = $1
+	`
+
+	var revokedBefore time.Time
+	if err := s.db.QueryRow(querySelect, userID).Scan(&revokedBefore); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch token revocation: %w", err)
+	}
+
+	return &revokedBefore, nil
+}
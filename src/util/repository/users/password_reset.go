@@ -0,0 +1,110 @@
+package users
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/users"
+
+	"github.com/google/uuid"
+)
+
+func (s *store) CreatePasswordReset(userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	queryCreate := `
+		INSERT INTO password_resets(
+			user_id,
+			token_hash,
+			expires_at,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			now()
+		)
+	`
+
+	if _, err := s.db.Exec(queryCreate, userID, tokenHash, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword consumes the reset token and replaces the password of its
+// user. Every other outstanding reset token of the user is used up as well.
+func (s *store) ResetPassword(tokenHash string, password string) (*uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	queryUse := `
+		UPDATE password_resets
+		SET
+			used_at = now()
+		WHERE
+			token_hash = $1
+			AND used_at IS NULL
+			AND expires_at > $2
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+	if err := tx.QueryRow(queryUse, tokenHash, time.Now().UTC()).Scan(&userID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, users.ErrInvalidPasswordReset
+		}
+		return nil, fmt.Errorf("failed to use password reset: %w", err)
+	}
+
+	queryUseOthers := `
+		UPDATE password_resets
+		SET
+			used_at = now()
+		WHERE
+			user_id = $1
+			AND used_at IS NULL
+	`
+	if _, err := tx.Exec(queryUseOthers, userID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to use password resets: %w", err)
+	}
+
+	// Opening the reset link proves the ownership of the address too
+	queryUpdate := `
+		UPDATE users
+		SET
+			password = $2,
+			email_verified_at = COALESCE(email_verified_at, now()),
+			updated_at = now()
+		WHERE
+			id = $1
+			AND deleted_at IS NULL
+	`
+	result, err := tx.Exec(queryUpdate, userID, password)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	// The account was deleted after the reset was requested
+	if affected == 0 {
+		tx.Rollback()
+		return nil, users.ErrInvalidPasswordReset
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &userID, nil
+}
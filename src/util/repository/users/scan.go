@@ -0,0 +1,64 @@
+package users
+
+import (
+	"database/sql"
+	"user-service/src/util/repository/model/users"
+
+	"github.com/lib/pq"
+)
+
+// userColumns are the columns scanUser reads, in its order. Queries list them
+// instead of using SELECT *, so new columns do not break the scan.
+const userColumns = `
+	id,
+	email,
+	username,
+	role,
+	address,
+	category_preferences,
+	created_at,
+	updated_at,
+	deleted_at,
+	password,
+	email_verified_at,
+	suspended_at,
+	anonymize_after,
+	anonymized_at
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans the userColumns of a row, extra receives the columns a
+// query selects after them.
+func scanUser(row rowScanner, extra ...interface{}) (*users.Users, error) {
+	var user users.Users
+	var address, password sql.NullString
+
+	dest := []interface{}{
+		&user.Id,
+		&user.Email,
+		&user.Username,
+		&user.Role,
+		&address,
+		pq.Array(&user.CategoryPreferences),
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletedAt,
+		&password,
+		&user.EmailVerifiedAt,
+		&user.SuspendedAt,
+		&user.AnonymizeAfter,
+		&user.AnonymizedAt,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	user.Address = address.String
+	user.Password = password.String
+
+	return &user, nil
+}
@@ -2,15 +2,36 @@ package users
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 	"user-service/src/util/helper"
+	"user-service/src/util/repository/model/attempts"
+	"user-service/src/util/repository/model/audit"
 	"user-service/src/util/repository/model/users"
+	"user-service/src/util/repository/query"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+// usersSorting lists the fields GET /users can be sorted by.
+var usersSorting = query.Sorting{
+	Columns: map[string]string{
+		"created_at": "created_at",
+		"email":      "email",
+		"username":   "username",
+		"role":       "role",
+	},
+	Default: "created_at DESC, id DESC",
+}
+
+// searchDocument is the text search document of a user, it is indexed by
+// idx_users_search_document.
+const searchDocument = "to_tsvector('simple', email || ' ' || username || ' ' || role)"
+
 type store struct {
 	db *sql.DB
 }
@@ -35,6 +56,8 @@ func (s *store) RegisterUser(bReq users.Users) (*uuid.UUID, error) {
 			role,
  			address,
  			category_preferences,
+			password,
+			email_verified_at,
 			created_at
 		) VALUES (
 			$1,
@@ -42,6 +65,8 @@ func (s *store) RegisterUser(bReq users.Users) (*uuid.UUID, error) {
 			$3,
 			$4,
 			$5,
+			NULLIF($6, ''),
+			$7,
 			now()
 		) RETURNING id
 	`
@@ -53,7 +78,13 @@ func (s *store) RegisterUser(bReq users.Users) (*uuid.UUID, error) {
 		bReq.Role,
 		bReq.Address,
 		pq.Array(bReq.CategoryPreferences),
+		bReq.Password,
+		bReq.EmailVerifiedAt,
 	).Scan(&userID); err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return nil, users.ErrUserAlreadyRegistered
+		}
 		return nil, err
 	}
 
@@ -64,172 +95,217 @@ func (s *store) RegisterUser(bReq users.Users) (*uuid.UUID, error) {
 	return &userID, nil
 }
 
+// GetUserDetails finds an active user by email or id, it returns
+// users.ErrUserNotFound when there is none.
 func (s *store) GetUserDetails(bReq users.Users) (*users.Users, error) {
-	querySelect := `
+	querySelect, args := query.Select(`
 		SELECT
-			*
+			`+userColumns+`
 		FROM
 		    users
-	`
-
-	var queryConditions []string
-	if bReq.Email != "" {
-		queryConditions = append(queryConditions, fmt.Sprintf("email = '%s'", bReq.Email))
-	}
-
-	if bReq.Id != uuid.Nil {
-		queryConditions = append(queryConditions, fmt.Sprintf("id = '%v'", bReq.Id))
-	}
-
-	if len(queryConditions) > 0 {
-		querySelect += " WHERE " + strings.Join(queryConditions, " AND ")
+	`).
+		Where("deleted_at IS NULL").
+		WhereIf(bReq.Email != "", "LOWER(email) = LOWER(?)", bReq.Email).
+		WhereIf(bReq.Id != uuid.Nil, "id = ?", bReq.Id).
+		OrderBy("created_at DESC").
+		Limit(1).
+		Build()
+
+	user, err := scanUser(s.db.QueryRow(querySelect, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, users.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
 
-	querySelect += `
-		ORDER BY created_at DESC limit 1
-	`
+	return user, nil
+}
 
-	var response users.Users
-	rows, err := s.db.Query(querySelect)
+// GetUsers returns a page of users and the number of users matching the
+// filters across all pages. With a cursor the page starts after the cursor
+// row in the keyset order (created_at, id), otherwise at the page offset.
+func (s *store) GetUsers(bReq users.RequestUsers) (*[]users.Users, int, error) {
+	orderBy, err := usersSorting.Parse(bReq.Sort)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %v", err)
+		return nil, 0, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		if err := rows.Scan(
-			&response.Id,
-			&response.Email,
-			&response.Username,
-			&response.Role,
-			&response.Address,
-			pq.Array(&response.CategoryPreferences),
-			&response.CreatedAt,
-			&response.UpdatedAt,
-			&response.DeletedAt,
-		); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("no partner found")
-			}
-			return nil, fmt.Errorf("failed to fetch user data")
+	if bReq.Cursor != "" {
+		if bReq.Sort != "" {
+			return nil, 0, query.ErrInvalidCursor
 		}
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed iterate over user: %v", err)
-	}
+		cursor, err := query.DecodeCursor(bReq.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
 
-	return &response, nil
-}
+		return s.getUsersAfter(bReq, cursor, orderBy)
+	}
 
-func (s *store) GetUsers(bReq users.RequestUsers) (*[]users.Users, int, error) {
-	querySelect := `
+	// The total is counted by a window over the filtered rows, before the
+	// page is cut out of them
+	queryFiltered, filterArgs := filterUsers(`
 		SELECT
-			*
+			`+userColumns+`,
+			COUNT(*) OVER() AS total_count
 		FROM
 		    users
-	`
+	`, bReq).Build()
 
-	var queryConditions []string
-	if bReq.UserId != uuid.Nil {
-		queryConditions = append(queryConditions, fmt.Sprintf("id = '%v'", bReq.UserId))
-	}
+	querySelect := query.Select("SELECT "+userColumns+", total_count FROM ("+queryFiltered+") AS filtered", filterArgs...)
+	orderUsers(querySelect, bReq, orderBy).Paginate(bReq.Page, bReq.Limit)
 
-	if bReq.Email != "" {
-		queryConditions = append(queryConditions, fmt.Sprintf("email = '%s'", bReq.Email))
+	statement, args := querySelect.Build()
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to execute query: %w", err)
 	}
+	defer rows.Close()
 
-	if bReq.Search != "" {
-		searchTerm := fmt.Sprintf("%%%s%%", bReq.Search)
-		queryConditions = append(queryConditions, fmt.Sprintf("(email LIKE '%s' OR username LIKE '%s' OR role LIKE '%s')", searchTerm, searchTerm, searchTerm))
+	usersData := []users.Users{}
+	var totalData int
+	for rows.Next() {
+		user, err := scanUser(rows, &totalData)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan rows: %w", err)
+		}
+		usersData = append(usersData, *user)
 	}
 
-	if bReq.Role != "" {
-		queryConditions = append(queryConditions, fmt.Sprintf("role = '%s'", bReq.Role))
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate rows: %w", err)
 	}
 
-	if len(queryConditions) > 0 {
-		querySelect += "WHERE " + strings.Join(queryConditions, " AND ")
+	// A page past the end has no row to carry the total
+	if len(usersData) == 0 && bReq.Page > 1 {
+		if totalData, err = s.countUsers(bReq); err != nil {
+			return nil, 0, err
+		}
 	}
 
-	querySelect += `
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+	return &usersData, totalData, nil
+}
 
-	offset := (bReq.Page - 1) * bReq.Limit
-	rows, err := s.db.Query(querySelect, bReq.Limit, offset)
+// getUsersAfter reads the page after cursor. The cursor condition sits next to
+// the filters so the (created_at, id) index can seek to it, and the total is
+// counted by its own query since a window would have to run over every row
+// before the cursor.
+func (s *store) getUsersAfter(bReq users.RequestUsers, cursor query.Cursor, orderBy string) (*[]users.Users, int, error) {
+	querySelect := filterUsers("SELECT "+userColumns+" FROM users", bReq).
+		Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.Id)
+	orderUsers(querySelect, bReq, orderBy).Limit(bReq.Limit)
+
+	statement, args := querySelect.Build()
+	rows, err := s.db.Query(statement, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to execute query: %v", err)
+		return nil, 0, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
-	var usersData []users.Users
+	usersData := []users.Users{}
 	for rows.Next() {
-		var user users.Users
-		if err := rows.Scan(
-			&user.Id,
-			&user.Email,
-			&user.Username,
-			&user.Role,
-			&user.Address,
-			pq.Array(&user.CategoryPreferences),
-			&user.CreatedAt,
-			&user.UpdatedAt,
-			&user.DeletedAt,
-		); err != nil {
-			return nil, 0, fmt.Errorf("failed to scan rows: %v", err)
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan rows: %w", err)
 		}
-		usersData = append(usersData, user)
+		usersData = append(usersData, *user)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("failed to iterate rows: %v", err)
+		return nil, 0, fmt.Errorf("failed to iterate rows: %w", err)
 	}
 
-	totalData := len(usersData)
+	totalData, err := s.countUsers(bReq)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	return &usersData, totalData, nil
 }
-func (s *store) UpdateUser(id uuid.UUID, bReq users.Users) error {
+
+func (s *store) countUsers(bReq users.RequestUsers) (int, error) {
+	queryCount, args := filterUsers("SELECT COUNT(*) FROM users", bReq).Build()
+
+	var totalData int
+	if err := s.db.QueryRow(queryCount, args...).Scan(&totalData); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return totalData, nil
+}
+
+// filterUsers adds the filters of GET /users to the select in base.
+func filterUsers(base string, bReq users.RequestUsers) *query.Builder {
+	searchPattern := query.Contains(bReq.Search)
+	return query.Select(base).
+		WhereIf(!bReq.IncludeDeleted, "deleted_at IS NULL").
+		WhereIf(bReq.UserId != uuid.Nil, "id = ?", bReq.UserId).
+		WhereIf(bReq.Email != "", "LOWER(email) = LOWER(?)", bReq.Email).
+		WhereIf(bReq.Search != "", searchDocument+" @@ plainto_tsquery('simple', ?) OR email ILIKE ? OR username ILIKE ? OR username % ?", bReq.Search, searchPattern, searchPattern, bReq.Search).
+		WhereIf(bReq.Role != "", "role = ?", bReq.Role).
+		WhereIf(bReq.CreatedFrom != nil, "created_at >= ?", utc(bReq.CreatedFrom)).
+		WhereIf(bReq.CreatedTo != nil, "created_at <= ?", utc(bReq.CreatedTo)).
+		WhereIf(len(bReq.Categories) > 0, "category_preferences && ?", pq.Array(bReq.Categories))
+}
+
+// orderUsers ranks search results by relevance first.
+func orderUsers(b *query.Builder, bReq users.RequestUsers, orderBy string) *query.Builder {
+	if !bReq.Ranked() {
+		return b.OrderBy(orderBy)
+	}
+
+	search := b.Arg(bReq.Search)
+	return b.OrderBy("ts_rank(" + searchDocument + ", plainto_tsquery('simple', " + search + ")) + " +
+		"similarity(username, " + search + ") + similarity(email, " + search + ") DESC, " + orderBy)
+}
+
+// UpdateUser changes the profile of the user and reports whether the email
+// changed. A changed email is unverified until the new address is confirmed.
+func (s *store) UpdateUser(id uuid.UUID, bReq users.Users) (bool, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	queryLock := `
-        SELECT 1
+        SELECT email
         FROM users
         WHERE id = $1
         FOR UPDATE
     `
-	if _, err := tx.Exec(queryLock, id); err != nil {
+	var previousEmail string
+	if err := tx.QueryRow(queryLock, id).Scan(&previousEmail); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to lock user row: %w", err)
+		if err == sql.ErrNoRows {
+			return false, users.ErrUserNotFound
+		}
+		return false, fmt.Errorf("failed to lock user row: %w", err)
 	}
 
 	queryUpdate := `
         UPDATE users
         SET
             email = $1,
-            role = $2,
-            address = $3,
-            category_preferences = $4,
-            updated_at = $5
+            address = $2,
+            category_preferences = $3,
+            updated_at = $4,
+            email_verified_at = CASE WHEN LOWER(email) = LOWER($1) THEN email_verified_at END
         WHERE
-            id = $6
+            id = $5
+            AND deleted_at IS NULL
     `
 
 	timeNow, err := helper.TimeNow()
 	if err != nil {
 		tx.Rollback()
-		return err
+		return false, err
 	}
 
-	_, err = tx.Exec(
+	result, err := tx.Exec(
 		queryUpdate,
 		bReq.Email,
-		bReq.Role,
 		bReq.Address,
 		pq.Array(bReq.CategoryPreferences),
 		&timeNow,
@@ -237,13 +313,408 @@ func (s *store) UpdateUser(id uuid.UUID, bReq users.Users) error {
 	)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to execute update query: %w", err)
+		if isUniqueViolation(err) {
+			return false, users.ErrUserAlreadyRegistered
+		}
+		return false, fmt.Errorf("failed to execute update query: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		tx.Rollback()
+		return false, users.ErrUserNotFound
 	}
 
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return !strings.EqualFold(previousEmail, bReq.Email), nil
+}
+
+// UpdateRole changes the role of the user and returns the role it had before.
+// An actual change is audited with entry in the same transaction.
+func (s *store) UpdateRole(id uuid.UUID, role string, entry audit.Entry) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queryUpdate := `
+		UPDATE users AS u
+		SET
+			role = $2,
+			updated_at = now()
+		FROM (
+			SELECT id, role FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+		) AS previous
+		WHERE
+			u.id = previous.id
+		RETURNING previous.role
+	`
+
+	var previousRole string
+	if err := tx.QueryRow(queryUpdate, id, role).Scan(&previousRole); err != nil {
+		if err == sql.ErrNoRows {
+			return "", users.ErrUserNotFound
+		}
+		return "", fmt.Errorf("failed to update role: %w", err)
+	}
+
+	if previousRole == role {
+		return previousRole, nil
+	}
+
+	metadata, err := json.Marshal(map[string]string{
+		"from": previousRole,
+		"to":   role,
+	})
+	if err != nil {
+		return "", err
+	}
+	entry.Metadata = metadata
+
+	if err := insertAuditEntry(tx, entry); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return previousRole, nil
+}
+
+// SuspendUser blocks the sign in of the user until UnsuspendUser. Suspending
+// a suspended user keeps the original suspension time.
+func (s *store) SuspendUser(id uuid.UUID, entry audit.Entry) error {
+	queryUpdate := `
+		UPDATE users
+		SET
+			suspended_at = COALESCE(suspended_at, now()),
+			updated_at = now()
+		WHERE
+			id = $1
+			AND deleted_at IS NULL
+	`
+
+	return s.updateUserState(queryUpdate, id, "suspend user", entry)
+}
+
+func (s *store) UnsuspendUser(id uuid.UUID, entry audit.Entry) error {
+	queryUpdate := `
+		UPDATE users
+		SET
+			suspended_at = NULL,
+			updated_at = now()
+		WHERE
+			id = $1
+			AND deleted_at IS NULL
+	`
+
+	return s.updateUserState(queryUpdate, id, "unsuspend user", entry)
+}
+
+// DeleteUser soft deletes the user through deleted_at, RestoreUser undoes it.
+func (s *store) DeleteUser(id uuid.UUID, entry audit.Entry) error {
+	queryUpdate := `
+		UPDATE users
+		SET
+			deleted_at = COALESCE(deleted_at, now()),
+			updated_at = now()
+		WHERE
+			id = $1
+	`
+
+	return s.updateUserState(queryUpdate, id, "delete user", entry)
+}
+
+// RestoreUser also cancels a pending anonymization, users that were already
+// anonymized cannot be restored.
+func (s *store) RestoreUser(id uuid.UUID, entry audit.Entry) error {
+	queryUpdate := `
+		UPDATE users
+		SET
+			deleted_at = NULL,
+			anonymize_after = NULL,
+			updated_at = now()
+		WHERE
+			id = $1
+			AND anonymized_at IS NULL
+	`
+
+	if err := s.updateUserState(queryUpdate, id, "restore user", entry); err != nil {
+		if isUniqueViolation(err) {
+			return users.ErrUserAlreadyRegistered
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ScheduleAnonymization deletes the user right away and marks the row for
+// AnonymizeUsers once the grace period is over.
+func (s *store) ScheduleAnonymization(id uuid.UUID, after time.Time, entry audit.Entry) error {
+	queryUpdate := `
+		UPDATE users
+		SET
+			deleted_at = COALESCE(deleted_at, now()),
+			anonymize_after = $2,
+			updated_at = now()
+		WHERE
+			id = $1
+			AND deleted_at IS NULL
+	`
+
+	return s.updateUserState(queryUpdate, id, "schedule anonymization", entry, after.UTC())
+}
+
+// AuditUser records an action on the user that changes nothing in the users
+// table, like signing the user out.
+func (s *store) AuditUser(id uuid.UUID, entry audit.Entry) error {
+	queryCreate := `
+		INSERT INTO audit_logs(
+			actor_id,
+			action,
+			target_type,
+			target_id,
+			metadata,
+			created_at
+		)
+		SELECT
+			$1,
+			$2,
+			$3,
+			id,
+			$5,
+			now()
+		FROM
+			users
+		WHERE
+			id = $4
+	`
+
+	result, err := s.db.Exec(queryCreate, entry.ActorId, entry.Action, entry.TargetType, id, auditMetadata(entry))
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return users.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// anonymizeQueries remove what else identifies an anonymized user by id:
+// sessions keep IP addresses and user agents, and the credentials, pending
+// links and audit metadata are of no use anymore. Sign in attempts are kept
+// by email, queryDeleteAttempts removes them.
+var anonymizeQueries = []string{
+	`DELETE FROM sessions WHERE user_id = ANY($1)`,
+	`DELETE FROM refresh_tokens WHERE user_id = ANY($1)`,
+	`DELETE FROM user_mfa WHERE user_id = ANY($1)`,
+	`DELETE FROM mfa_recovery_codes WHERE user_id = ANY($1)`,
+	`DELETE FROM email_verifications WHERE user_id = ANY($1)`,
+	`DELETE FROM password_resets WHERE user_id = ANY($1)`,
+	`UPDATE audit_logs SET metadata = '{}' WHERE target_type = '` + audit.TargetUser + `' AND target_id = ANY($1)`,
+}
+
+const queryDeleteAttempts = `DELETE FROM login_attempts WHERE scope = '` + attempts.ScopeAccount + `' AND identifier = ANY($1)`
+
+// AnonymizeUsers scrubs the personal data of deleted users whose grace period
+// ended before now, in one transaction with everything else stored about
+// them. The row itself is kept so orders still reference a user.
+func (s *store) AnonymizeUsers(now time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	querySelect := `
+		SELECT
+			id,
+			LOWER(email)
+		FROM
+			users
+		WHERE
+			anonymize_after <= $1
+			AND deleted_at IS NOT NULL
+			AND anonymized_at IS NULL
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(querySelect, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users to anonymize: %w", err)
+	}
+
+	var ids []uuid.UUID
+	var emails []string
+	for rows.Next() {
+		var id uuid.UUID
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		ids = append(ids, id)
+		emails = append(emails, email)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get users to anonymize: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, query := range anonymizeQueries {
+		if _, err := tx.Exec(query, pq.Array(ids)); err != nil {
+			return 0, fmt.Errorf("failed to anonymize users: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(queryDeleteAttempts, pq.Array(emails)); err != nil {
+		return 0, fmt.Errorf("failed to anonymize users: %w", err)
+	}
+
+	queryUpdate := `
+		UPDATE users
+		SET
+			email = 'deleted-' || id || '@anonymized.invalid',
+			username = 'deleted-user',
+			address = '',
+			category_preferences = '{}',
+			password = NULL,
+			email_verified_at = NULL,
+			anonymize_after = NULL,
+			anonymized_at = $1,
+			updated_at = $1
+		WHERE
+			id = ANY($2)
+	`
+
+	result, err := tx.Exec(queryUpdate, now.UTC(), pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize users: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to anonymize users: %w", err)
+	}
+
+	return affected, nil
+}
+
+// updateUserState runs an admin action on the user and writes its audit entry
+// in one transaction, so neither is stored without the other. The id is $1,
+// args follow it.
+func (s *store) updateUserState(query string, id uuid.UUID, action string, entry audit.Entry, args ...interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return users.ErrUserNotFound
+	}
+
+	if err := insertAuditEntry(tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
+
+func insertAuditEntry(tx *sql.Tx, entry audit.Entry) error {
+	queryCreate := `
+		INSERT INTO audit_logs(
+			actor_id,
+			action,
+			target_type,
+			target_id,
+			metadata,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			now()
+		)
+	`
+
+	if _, err := tx.Exec(
+		queryCreate,
+		entry.ActorId,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetId,
+		auditMetadata(entry),
+	); err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func auditMetadata(entry audit.Entry) string {
+	if len(entry.Metadata) == 0 {
+		return "{}"
+	}
+
+	return string(entry.Metadata)
+}
+
+// isUniqueViolation reports whether the email is already used by an account
+// that is not deleted.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	timeUTC := t.UTC()
+	return &timeUTC
+}
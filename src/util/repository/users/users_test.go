@@ -0,0 +1,416 @@
+package users
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+	"user-service/src/util/repository/model/audit"
+	"user-service/src/util/repository/model/users"
+	"user-service/src/util/repository/query"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var userColumnNames = []string{
+	"id", "email", "username", "role", "address", "category_preferences", "created_at", "updated_at",
+	"deleted_at", "password", "email_verified_at", "suspended_at", "anonymize_after", "anonymized_at",
+}
+
+func newMockStore(t *testing.T) (*store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	return NewStore(db), mock
+}
+
+func userRow(id uuid.UUID, email string) []driver.Value {
+	createdAt := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	return []driver.Value{id, email, "tester", "User", nil, "{Buku,Baju}", createdAt, nil, nil, "hash", createdAt, nil, nil, nil}
+}
+
+func TestStore_RegisterUser(t *testing.T) {
+	bReq := users.Users{
+		Email:               "test@example.com",
+		Username:            "tester",
+		Role:                "User",
+		Address:             "Jakarta",
+		CategoryPreferences: []string{"Buku"},
+		Password:            "hash",
+	}
+
+	t.Run("success", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		id := uuid.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO users").
+			WithArgs(bReq.Email, bReq.Username, bReq.Role, bReq.Address, pq.Array(bReq.CategoryPreferences), bReq.Password, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		mock.ExpectCommit()
+
+		result, err := s.RegisterUser(bReq)
+		assert.NoError(t, err)
+		assert.Equal(t, id, *result)
+	})
+
+	t.Run("email already used", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO users").WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		_, err := s.RegisterUser(bReq)
+		assert.ErrorIs(t, err, users.ErrUserAlreadyRegistered)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO users").WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		_, err := s.RegisterUser(bReq)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, users.ErrUserAlreadyRegistered)
+	})
+}
+
+func TestStore_GetUserDetails(t *testing.T) {
+	querySelect := regexp.QuoteMeta("FROM\n\t\t    users\n\tWHERE (deleted_at IS NULL) AND (LOWER(email) = LOWER($1)) ORDER BY created_at DESC LIMIT $2")
+
+	t.Run("found", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		id := uuid.New()
+
+		mock.ExpectQuery(querySelect).
+			WithArgs("test@example.com", 1).
+			WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(userRow(id, "test@example.com")...))
+
+		result, err := s.GetUserDetails(users.Users{Email: "test@example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, id, result.Id)
+		assert.Equal(t, "hash", result.Password)
+		assert.Equal(t, "", result.Address)
+		assert.Equal(t, []string{"Buku", "Baju"}, result.CategoryPreferences)
+		assert.NotNil(t, result.EmailVerifiedAt)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectQuery(querySelect).
+			WithArgs("missing@example.com", 1).
+			WillReturnRows(sqlmock.NewRows(userColumnNames))
+
+		result, err := s.GetUserDetails(users.Users{Email: "missing@example.com"})
+		assert.ErrorIs(t, err, users.ErrUserNotFound)
+		assert.Nil(t, result)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectQuery(querySelect).WillReturnError(sql.ErrConnDone)
+
+		_, err := s.GetUserDetails(users.Users{Email: "test@example.com"})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestStore_GetUsers(t *testing.T) {
+	columns := append(append([]string{}, userColumnNames...), "total_count")
+
+	t.Run("page with total", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		rows := sqlmock.NewRows(columns).
+			AddRow(append(userRow(uuid.New(), "a@example.com"), 12)...).
+			AddRow(append(userRow(uuid.New(), "b@example.com"), 12)...)
+		mock.ExpectQuery(regexp.QuoteMeta("WHERE (deleted_at IS NULL) AND (role = $1)) AS filtered ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3")).
+			WithArgs("User", 2, 2).
+			WillReturnRows(rows)
+
+		result, total, err := s.GetUsers(users.RequestUsers{Role: "User", Page: 2, Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, *result, 2)
+		assert.Equal(t, 12, total)
+	})
+
+	t.Run("page past the end counts separately", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectQuery("LIMIT").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM users WHERE (deleted_at IS NULL)")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		result, total, err := s.GetUsers(users.RequestUsers{Page: 5, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, *result)
+		assert.Equal(t, 3, total)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		s, mock := newMockStore(t)
+		cursor := query.Cursor{CreatedAt: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), Id: uuid.New()}
+
+		mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE (role = $1) AND ((created_at, id) < ($2, $3)) ORDER BY created_at DESC, id DESC LIMIT $4")).
+			WithArgs("User", cursor.CreatedAt, cursor.Id, 10).
+			WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(userRow(uuid.New(), "a@example.com")...))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM users WHERE (role = $1)")).
+			WithArgs("User").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+
+		result, total, err := s.GetUsers(users.RequestUsers{Cursor: cursor.Encode(), Limit: 10, Role: "User", IncludeDeleted: true})
+		assert.NoError(t, err)
+		assert.Len(t, *result, 1)
+		assert.Equal(t, 11, total)
+	})
+
+	t.Run("invalid sort and cursor", func(t *testing.T) {
+		s, _ := newMockStore(t)
+
+		_, _, err := s.GetUsers(users.RequestUsers{Sort: "password"})
+		assert.ErrorIs(t, err, query.ErrInvalidSort)
+
+		_, _, err = s.GetUsers(users.RequestUsers{Cursor: "invalid"})
+		assert.ErrorIs(t, err, query.ErrInvalidCursor)
+
+		_, _, err = s.GetUsers(users.RequestUsers{Cursor: query.Cursor{Id: uuid.New()}.Encode(), Sort: "email"})
+		assert.ErrorIs(t, err, query.ErrInvalidCursor)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectQuery("LIMIT").WillReturnError(sql.ErrConnDone)
+
+		_, _, err := s.GetUsers(users.RequestUsers{Page: 1, Limit: 10})
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestStore_UpdateUser(t *testing.T) {
+	id := uuid.New()
+	bReq := users.Users{Email: "new@example.com", Address: "Bandung", CategoryPreferences: []string{"Buku"}}
+
+	t.Run("success", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("New@Example.com"))
+		mock.ExpectExec("UPDATE users").
+			WithArgs(bReq.Email, bReq.Address, pq.Array(bReq.CategoryPreferences), sqlmock.AnyArg(), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		emailChanged, err := s.UpdateUser(id, bReq)
+		assert.NoError(t, err)
+		assert.False(t, emailChanged)
+	})
+
+	t.Run("email changed", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("old@example.com"))
+		mock.ExpectExec("email_verified_at = CASE").
+			WithArgs(bReq.Email, bReq.Address, pq.Array(bReq.CategoryPreferences), sqlmock.AnyArg(), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		emailChanged, err := s.UpdateUser(id, bReq)
+		assert.NoError(t, err)
+		assert.True(t, emailChanged)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := s.UpdateUser(id, bReq)
+		assert.ErrorIs(t, err, users.ErrUserNotFound)
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("old@example.com"))
+		mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, err := s.UpdateUser(id, bReq)
+		assert.ErrorIs(t, err, users.ErrUserNotFound)
+	})
+
+	t.Run("email already used", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("old@example.com"))
+		mock.ExpectExec("UPDATE users").WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		_, err := s.UpdateUser(id, bReq)
+		assert.ErrorIs(t, err, users.ErrUserAlreadyRegistered)
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := s.UpdateUser(id, bReq)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestStore_UpdateRole(t *testing.T) {
+	id := uuid.New()
+	entry := audit.Entry{ActorId: uuid.New(), Action: audit.ActionUserRoleChanged, TargetType: audit.TargetUser, TargetId: id}
+
+	t.Run("audits the change in the same transaction", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE users AS u").WithArgs(id, "Seller").
+			WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("User"))
+		mock.ExpectExec("INSERT INTO audit_logs").
+			WithArgs(entry.ActorId, entry.Action, entry.TargetType, id, `{"from":"User","to":"Seller"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		previousRole, err := s.UpdateRole(id, "Seller", entry)
+		assert.NoError(t, err)
+		assert.Equal(t, "User", previousRole)
+	})
+
+	t.Run("unchanged role is not audited", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE users AS u").WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("Seller"))
+		mock.ExpectRollback()
+
+		_, err := s.UpdateRole(id, "Seller", entry)
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed audit rolls the change back", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE users AS u").WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("User"))
+		mock.ExpectExec("INSERT INTO audit_logs").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := s.UpdateRole(id, "Seller", entry)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+	})
+}
+
+func TestStore_SuspendUser(t *testing.T) {
+	id := uuid.New()
+	entry := audit.Entry{ActorId: uuid.New(), Action: audit.ActionUserSuspended, TargetType: audit.TargetUser, TargetId: id}
+
+	t.Run("audits the suspension in the same transaction", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SET suspended_at").WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_logs").
+			WithArgs(entry.ActorId, entry.Action, entry.TargetType, id, "{}").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, s.SuspendUser(id, entry))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("SET suspended_at").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, s.SuspendUser(id, entry), users.ErrUserNotFound)
+	})
+}
+
+func TestStore_AuditUser(t *testing.T) {
+	id := uuid.New()
+	entry := audit.Entry{ActorId: id, Action: audit.ActionUserLoggedOut, TargetType: audit.TargetUser, TargetId: id}
+
+	s, mock := newMockStore(t)
+	mock.ExpectExec("INSERT INTO audit_logs").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.ErrorIs(t, s.AuditUser(id, entry), users.ErrUserNotFound)
+}
+
+func TestStore_AnonymizeUsers(t *testing.T) {
+	now := time.Date(2024, 7, 20, 0, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	t.Run("scrubs the user and everything about them", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, LOWER\\(email\\) FROM users .* FOR UPDATE").
+			WithArgs(now).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(id, "test@example.com"))
+		for _, query := range anonymizeQueries {
+			mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(pq.Array([]uuid.UUID{id})).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectExec(regexp.QuoteMeta(queryDeleteAttempts)).WithArgs(pq.Array([]string{"test@example.com"})).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE users SET email = 'deleted-'").WithArgs(now, pq.Array([]uuid.UUID{id})).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		affected, err := s.AnonymizeUsers(now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), affected)
+	})
+
+	t.Run("nothing to anonymize", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, LOWER\\(email\\) FROM users").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}))
+		mock.ExpectRollback()
+
+		affected, err := s.AnonymizeUsers(now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), affected)
+	})
+
+	t.Run("failure keeps the user untouched", func(t *testing.T) {
+		s, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, LOWER\\(email\\) FROM users").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(id, "test@example.com"))
+		mock.ExpectExec("DELETE FROM sessions").WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		_, err := s.AnonymizeUsers(now)
+		assert.ErrorContains(t, err, "connection reset")
+	})
+}
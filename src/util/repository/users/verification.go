@@ -0,0 +1,82 @@
+package users
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"user-service/src/util/repository/model/users"
+
+	"github.com/google/uuid"
+)
+
+func (s *store) CreateEmailVerification(userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	queryCreate := `
+		INSERT INTO email_verifications(
+			user_id,
+			token_hash,
+			expires_at,
+			created_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			now()
+		)
+	`
+
+	if _, err := s.db.Exec(queryCreate, userID, tokenHash, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to create email verification: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail consumes the verification token and marks the email address of
+// its user as verified.
+func (s *store) VerifyEmail(tokenHash string) (*uuid.UUID, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	queryUse := `
+		UPDATE email_verifications
+		SET
+			used_at = now()
+		WHERE
+			token_hash = $1
+			AND used_at IS NULL
+			AND expires_at > $2
+		RETURNING user_id
+	`
+
+	var userID uuid.UUID
+	if err := tx.QueryRow(queryUse, tokenHash, time.Now().UTC()).Scan(&userID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, users.ErrInvalidVerification
+		}
+		return nil, fmt.Errorf("failed to use email verification: %w", err)
+	}
+
+	queryUpdate := `
+		UPDATE users
+		SET
+			email_verified_at = now()
+		WHERE
+			id = $1
+			AND email_verified_at IS NULL
+			AND deleted_at IS NULL
+	`
+	if _, err := tx.Exec(queryUpdate, userID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &userID, nil
+}
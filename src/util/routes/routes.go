@@ -11,22 +11,27 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/spf13/viper"
 
+	admin "user-service/src/handlers/admin"
 	cart "user-service/src/handlers/cart"
 	order "user-service/src/handlers/order"
 	product "user-service/src/handlers/products"
 	shop "user-service/src/handlers/shop"
 	user "user-service/src/handlers/users"
 	integration "user-service/src/handlers/users/integrations"
+	wellknown "user-service/src/handlers/wellknown"
 )
 
 type Routes struct {
 	Router      *mux.Router
+	Auth        *middleware.Authenticator
+	Admin       *admin.Handler
 	Integration *integration.Handler
 	User        *user.Handler
 	Product     *product.Handler
 	Shop        *shop.Handler
 	Cart        *cart.Handler
 	Order       *order.Handler
+	WellKnown   *wellknown.Handler
 }
 
 func (r *Routes) Run(port string) {
@@ -48,12 +53,14 @@ func (r *Routes) SetupRouter() {
 	r.Router.Use(helper.EnabledCors, helper.LoggerMiddleware())
 
 	r.SetupBaseURL()
+	r.SetupWellKnown()
 	r.SetupIntegration()
 	r.SetupUser()
 	r.SetupProduct()
 	r.SetupShop()
 	r.SetupCart()
 	r.setupOrder()
+	r.SetupAdmin()
 }
 
 func (r *Routes) SetupBaseURL() {
@@ -63,6 +70,10 @@ func (r *Routes) SetupBaseURL() {
 	}
 }
 
+func (r *Routes) SetupWellKnown() {
+	r.Router.HandleFunc("/.well-known/jwks.json", r.WellKnown.JWKS).Methods(http.MethodGet, http.MethodOptions)
+}
+
 func (r *Routes) SetupIntegration() {
 	path := r.Router.PathPrefix("/users").Subrouter()
 	path.HandleFunc("/signup", r.Integration.SignUp).Methods(http.MethodGet, http.MethodOptions)
@@ -75,10 +86,25 @@ func (r *Routes) SetupUser() {
 	userRoutes := r.Router.PathPrefix("/users").Subrouter()
 	userRoutes.HandleFunc("/signup/email", r.User.SignUpByEmail).Methods(http.MethodPost, http.MethodOptions)
 	userRoutes.HandleFunc("/signin/email", r.User.SignInByEmail).Methods(http.MethodPost, http.MethodOptions)
+	userRoutes.HandleFunc("/signin/mfa", r.User.SignInMFA).Methods(http.MethodPost, http.MethodOptions)
+	userRoutes.HandleFunc("/token/refresh", r.User.RefreshToken).Methods(http.MethodPost, http.MethodOptions)
+	userRoutes.HandleFunc("/verify", r.User.VerifyEmail).Methods(http.MethodGet, http.MethodOptions)
+	userRoutes.HandleFunc("/verify/resend", r.User.ResendVerification).Methods(http.MethodPost, http.MethodOptions)
+	userRoutes.HandleFunc("/password/forgot", r.User.ForgotPassword).Methods(http.MethodPost, http.MethodOptions)
+	userRoutes.HandleFunc("/password/reset", r.User.ResetPassword).Methods(http.MethodPost, http.MethodOptions)
 
 	authenticatedRoutes := userRoutes.PathPrefix("").Subrouter()
-	authenticatedRoutes.Use(middleware.Authentication)
-	authenticatedRoutes.HandleFunc("", r.User.GetUsers).Methods(http.MethodGet, http.MethodOptions)
+	authenticatedRoutes.Use(r.Auth.Authentication)
+	authenticatedRoutes.Handle("", protect(r.User.GetUsers, middleware.PermissionUserList)).Methods(http.MethodGet, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/logout", r.User.Logout).Methods(http.MethodPost, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/logout-all", r.User.LogoutAll).Methods(http.MethodPost, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me", r.User.DeleteAccount).Methods(http.MethodDelete, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me/export", r.User.ExportAccount).Methods(http.MethodGet, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me/sessions", r.User.GetSessions).Methods(http.MethodGet, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me/sessions/{session_id}", r.User.DeleteSession).Methods(http.MethodDelete, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me/2fa/setup", r.User.SetupMFA).Methods(http.MethodPost, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me/2fa/confirm", r.User.ConfirmMFA).Methods(http.MethodPost, http.MethodOptions)
+	authenticatedRoutes.HandleFunc("/me/2fa/disable", r.User.DisableMFA).Methods(http.MethodPost, http.MethodOptions)
 	authenticatedRoutes.HandleFunc("/{user_id}/update", r.User.UpdateProfile).Methods(http.MethodPut, http.MethodOptions)
 }
 
@@ -87,11 +113,11 @@ func (r *Routes) SetupProduct() {
 	productRoutes.HandleFunc("", r.Product.GetProducts).Methods(http.MethodGet, http.MethodOptions)
 
 	authenticatedProductRoutes := productRoutes.PathPrefix("").Subrouter()
-	authenticatedProductRoutes.Use(middleware.Authentication)
+	authenticatedProductRoutes.Use(r.Auth.Authentication)
 	authenticatedProductRoutes.HandleFunc("", r.Product.GetProducts).Methods(http.MethodGet, http.MethodOptions)
-	authenticatedProductRoutes.HandleFunc("/{product_id}", r.Product.UpdateProduct).Methods(http.MethodPut, http.MethodOptions)
-	authenticatedProductRoutes.HandleFunc("/create", r.Product.CreateProduct).Methods(http.MethodPost, http.MethodOptions)
-	authenticatedProductRoutes.HandleFunc("/{product_id}/delete", r.Product.DeleteProduct).Methods(http.MethodDelete, http.MethodOptions)
+	authenticatedProductRoutes.Handle("/{product_id}", protect(r.Product.UpdateProduct, middleware.PermissionProductWrite)).Methods(http.MethodPut, http.MethodOptions)
+	authenticatedProductRoutes.Handle("/create", protect(r.Product.CreateProduct, middleware.PermissionProductWrite)).Methods(http.MethodPost, http.MethodOptions)
+	authenticatedProductRoutes.Handle("/{product_id}/delete", protect(r.Product.DeleteProduct, middleware.PermissionProductWrite)).Methods(http.MethodDelete, http.MethodOptions)
 }
 
 func (r *Routes) SetupShop() {
@@ -99,13 +125,13 @@ func (r *Routes) SetupShop() {
 	shopRoutes.HandleFunc("", r.Shop.GetShops).Methods(http.MethodGet, http.MethodOptions)
 
 	authenticatedShopRoutes := shopRoutes.PathPrefix("").Subrouter()
-	authenticatedShopRoutes.Use(middleware.Authentication)
-	authenticatedShopRoutes.HandleFunc("/create", r.Shop.CreateShop).Methods(http.MethodPost, http.MethodOptions)
+	authenticatedShopRoutes.Use(r.Auth.Authentication)
+	authenticatedShopRoutes.Handle("/create", protect(r.Shop.CreateShop, middleware.PermissionShopWrite)).Methods(http.MethodPost, http.MethodOptions)
 }
 
 func (r *Routes) SetupCart() {
 	cartRoutes := r.Router.PathPrefix("/cart").Subrouter()
-	cartRoutes.Use(middleware.Authentication)
+	cartRoutes.Use(r.Auth.Authentication)
 	cartRoutes.HandleFunc("/details", r.Cart.GetCartByUserID).Methods(http.MethodGet, http.MethodOptions)
 	cartRoutes.HandleFunc("/update", r.Cart.UpdateCart).Methods(http.MethodPut, http.MethodOptions)
 	cartRoutes.HandleFunc("/add", r.Cart.AddCart).Methods(http.MethodPost, http.MethodOptions)
@@ -114,12 +140,35 @@ func (r *Routes) SetupCart() {
 
 func (r *Routes) setupOrder() {
 	orderRoutes := r.Router.PathPrefix("/order").Subrouter()
-	orderRoutes.Use(middleware.Authentication)
-	orderRoutes.HandleFunc("/create", r.Order.CreateOrder).Methods(http.MethodPost, http.MethodOptions)
-	orderRoutes.HandleFunc("/status/{order_id}", r.Order.CheckStatusPayment).Methods(http.MethodGet, http.MethodOptions)
-	orderRoutes.HandleFunc("/status/{order_id}/update", r.Order.UpdateStatus).Methods(http.MethodPut, http.MethodOptions)
-	orderRoutes.HandleFunc("/status/{order_id}/shipping/update", r.Order.SellerUpdateStatus).Methods(http.MethodPut, http.MethodOptions)
+	orderRoutes.Use(r.Auth.Authentication)
+	orderRoutes.Handle("/create", protect(r.Order.CreateOrder, middleware.PermissionOrderCreate)).Methods(http.MethodPost, http.MethodOptions)
+	orderRoutes.Handle("/status/{order_id}", protect(r.Order.CheckStatusPayment, middleware.PermissionOrderRead)).Methods(http.MethodGet, http.MethodOptions)
+	orderRoutes.Handle("/status/{order_id}/update", protect(r.Order.UpdateStatus, middleware.PermissionOrderUpdate)).Methods(http.MethodPut, http.MethodOptions)
+	orderRoutes.Handle("/status/{order_id}/shipping/update", protect(r.Order.SellerUpdateStatus, middleware.PermissionOrderShip)).Methods(http.MethodPut, http.MethodOptions)
 
 	callbackRoutes := r.Router.PathPrefix("/order/callback").Subrouter()
 	callbackRoutes.HandleFunc("", r.Order.CallbackPayment).Methods(http.MethodPost, http.MethodOptions)
 }
+
+func (r *Routes) SetupAdmin() {
+	adminRoutes := r.Router.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(r.Auth.Authentication)
+	adminRoutes.Handle("/lockouts", protect(r.Admin.GetLockouts, middleware.PermissionLockoutManage)).Methods(http.MethodGet, http.MethodOptions)
+	adminRoutes.Handle("/lockouts/{scope}/{identifier}", protect(r.Admin.ClearLockout, middleware.PermissionLockoutManage)).Methods(http.MethodDelete, http.MethodOptions)
+	adminRoutes.Handle("/checkouts", protect(r.Admin.GetCheckouts, middleware.PermissionCheckoutManage)).Methods(http.MethodGet, http.MethodOptions)
+	adminRoutes.Handle("/checkouts/{checkout_id}", protect(r.Admin.GetCheckout, middleware.PermissionCheckoutManage)).Methods(http.MethodGet, http.MethodOptions)
+	adminRoutes.Handle("/checkouts/{checkout_id}/resume", protect(r.Admin.ResumeCheckout, middleware.PermissionCheckoutManage)).Methods(http.MethodPost, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}", protect(r.Admin.GetUser, middleware.PermissionUserManage)).Methods(http.MethodGet, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}", protect(r.Admin.DeleteUser, middleware.PermissionUserManage)).Methods(http.MethodDelete, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}/role", protect(r.Admin.ChangeUserRole, middleware.PermissionUserManage)).Methods(http.MethodPut, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}/suspend", protect(r.Admin.SuspendUser, middleware.PermissionUserManage)).Methods(http.MethodPost, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}/unsuspend", protect(r.Admin.UnsuspendUser, middleware.PermissionUserManage)).Methods(http.MethodPost, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}/restore", protect(r.Admin.RestoreUser, middleware.PermissionUserManage)).Methods(http.MethodPost, http.MethodOptions)
+	adminRoutes.Handle("/users/{user_id}/logout", protect(r.Admin.ForceLogout, middleware.PermissionUserManage)).Methods(http.MethodPost, http.MethodOptions)
+}
+
+// protect guards a single route with middleware.Require, the route group has
+// to be authenticated already.
+func protect(handler http.HandlerFunc, permissions ...middleware.Permission) http.Handler {
+	return middleware.Require(permissions...)(handler)
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Run("runs jobs right away and at their interval", func(t *testing.T) {
+		var runs atomic.Int32
+		s := NewScheduler()
+		s.Every("count", 10*time.Millisecond, func() error {
+			runs.Add(1)
+			return nil
+		})
+
+		s.Start()
+		assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+		s.Stop()
+	})
+
+	t.Run("keeps running a failing job", func(t *testing.T) {
+		var runs atomic.Int32
+		s := NewScheduler()
+		s.Every("fail", 10*time.Millisecond, func() error {
+			runs.Add(1)
+			return errors.New("failed")
+		})
+
+		s.Start()
+		assert.Eventually(t, func() bool { return runs.Load() >= 2 }, time.Second, time.Millisecond)
+		s.Stop()
+	})
+
+	t.Run("stop waits for the running job", func(t *testing.T) {
+		started := make(chan struct{})
+		var finished atomic.Bool
+		s := NewScheduler()
+		s.Every("slow", time.Hour, func() error {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			finished.Store(true)
+			return nil
+		})
+
+		s.Start()
+		<-started
+		s.Stop()
+		assert.True(t, finished.Load())
+	})
+}